@@ -5,15 +5,16 @@ import (
 	"log"
 	"strings"
 
-	"github.com/google/go-github/v28/github"
-
 	"errors"
 
 	"fmt"
 
+	"github.com/voyagegroup/popuko/forge"
 	"github.com/voyagegroup/popuko/input"
+	"github.com/voyagegroup/popuko/notify"
 	"github.com/voyagegroup/popuko/operation"
 	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
 	"github.com/voyagegroup/popuko/setting"
 )
 
@@ -21,40 +22,44 @@ type AcceptCommand struct {
 	Owner string
 	Name  string
 
-	Client  *github.Client
+	Client  forge.Client
 	BotName string
 	Info    *setting.RepositoryInfo
 
 	AutoMergeRepo *queue.AutoMergeQRepo
+	Notifier      *notify.Notifier
+	Reporter      *report.Reporter
 }
 
-func (c *AcceptCommand) AcceptChangesetByOthers(ctx context.Context, ev *github.IssueCommentEvent, cmd *input.AcceptChangeByOthersCommand) (bool, error) {
-	log.Printf("info: Start: merge the pull request by %v\n", *ev.Comment.ID)
-	defer log.Printf("info: End: merge the pull request by %v\n", *ev.Comment.ID)
+func (c *AcceptCommand) AcceptChangesetByOthers(ctx context.Context, ev *forge.CommentEvent, cmd *input.AcceptChangeByOthersCommand) (bool, error) {
+	log.Printf("info: Start: merge the pull request by %v\n", ev.CommentID)
+	defer log.Printf("info: End: merge the pull request by %v\n", ev.CommentID)
 
 	if c.BotName != cmd.BotName() {
 		log.Printf("info: this command works only if target user is actual our bot.")
 		return false, nil
 	}
 
-	sender := *ev.Sender.Login
+	sender := ev.Sender
 	log.Printf("debug: command is sent from %v\n", sender)
 
 	if c.Info.IsReviewer(sender) {
-		log.Printf("info: this bot try to merge #%v by the reviewer (`%v`)\n", ev.Issue.GetID(), sender)
+		log.Printf("info: this bot try to merge #%v by the reviewer (`%v`)\n", ev.Number, sender)
 		return c.acceptChangeset(ctx, ev, cmd)
 	}
 
 	if c.Info.IsInMergeableUserList(sender) {
-		opener := ev.Issue.User.GetName()
+		opener := ev.IssueUser
 		if isMergeableByMergeableUser(sender, opener, cmd.Reviewer) {
-			log.Printf("info: this bot try to merge #%v (opened by `%v`) by the mergeable user (`%v`) with reviewer (%v)\n", ev.Issue.GetID(), opener, sender, cmd.Reviewer)
+			log.Printf("info: this bot try to merge #%v (opened by `%v`) by the mergeable user (`%v`) with reviewer (%v)\n", ev.Number, opener, sender, cmd.Reviewer)
 			return c.acceptChangeset(ctx, ev, cmd)
 		}
 	}
 
-	log.Printf("info: %v cannnot merge the pull request #%v\n", sender, ev.Issue.GetID())
-	return false, nil
+	log.Printf("info: %v cannnot merge the pull request #%v\n", sender, ev.Number)
+	return rejectCommand(fmt.Sprintf("`%v` does not have the privilege to approve this pull request", sender),
+		"Reviewers can approve any pull request. "+
+			"Mergeable users can approve only their own pull requests with other reviewers (`r=<reviewer>`).")
 }
 
 func isMergeableByMergeableUser(commander, opener string, reviewer []string) bool {
@@ -73,60 +78,93 @@ func isMergeableByMergeableUser(commander, opener string, reviewer []string) boo
 	return true
 }
 
-func (c *AcceptCommand) AcceptChangesetByReviewer(ctx context.Context, ev *github.IssueCommentEvent, cmd *input.AcceptChangeByReviewerCommand) (bool, error) {
-	log.Printf("info: Start: merge the pull request by %v\n", *ev.Comment.ID)
-	defer log.Printf("info: End: merge the pull request by %v\n", *ev.Comment.ID)
+func (c *AcceptCommand) AcceptChangesetByReviewer(ctx context.Context, ev *forge.CommentEvent, cmd *input.AcceptChangeByReviewerCommand) (bool, error) {
+	log.Printf("info: Start: merge the pull request by %v\n", ev.CommentID)
+	defer log.Printf("info: End: merge the pull request by %v\n", ev.CommentID)
 
 	if c.BotName != cmd.BotName() {
 		log.Printf("info: this command works only if target user is actual our bot.")
 		return false, nil
 	}
 
-	sender := *ev.Sender.Login
+	sender := ev.Sender
 	log.Printf("debug: command is sent from %v\n", sender)
 
 	if !c.Info.IsReviewer(sender) {
 		log.Printf("info: %v is not an reviewer registred to this bot.\n", sender)
-		return false, nil
+		return rejectReviewerOnly(sender, c.Info)
 	}
 
 	return c.acceptChangeset(ctx, ev, cmd)
 }
 
-func (c *AcceptCommand) acceptChangeset(ctx context.Context, ev *github.IssueCommentEvent, cmd input.AcceptChangesetCommand) (bool, error) {
-	sender := *ev.Sender.Login
+func (c *AcceptCommand) acceptChangeset(ctx context.Context, ev *forge.CommentEvent, cmd input.AcceptChangesetCommand) (bool, error) {
+	sender := ev.Sender
 
 	client := c.Client
-	issueSvc := client.Issues
 
 	repoOwner := c.Owner
 	repoName := c.Name
-	issue := *ev.Issue.Number
+	issue := ev.Number
 	log.Printf("debug: issue number is %v\n", issue)
 
-	currentLabels := operation.GetLabelsByIssue(ctx, issueSvc, repoOwner, repoName, issue)
-	if currentLabels == nil {
-		return false, nil
+	if !ev.IsPullRequest {
+		return rejectCommand(fmt.Sprintf("#%v is not a pull request", issue), "")
 	}
 
-	labels := operation.AddAwaitingMergeLabel(currentLabels)
+	// The priority to bypass the closed tree is allowed only for reviewers.
+	if priority := cmd.Priority(); c.Info.CanBypassClosedTree(priority) && !c.Info.IsReviewer(sender) {
+		return rejectCommand(fmt.Sprintf("`%v` does not have the privilege to give the priority %v", sender, priority),
+			"Only reviewers can give the priority which bypasses the merge windows and freezes (`merge.bypass_priority` in `OWNERS.json`).")
+	}
 
-	// https://github.com/nekoya/popuko/blob/master/web.py
-	_, _, err := issueSvc.ReplaceLabelsForIssue(ctx, repoOwner, repoName, issue, labels)
+	if c.Info.EnableAutoMerge && !client.SupportsTryBranch() {
+		return rejectCommand("Auto-Merging is not supported on this forge",
+			"This bot cannot create the branch to test the pull request merged into its base branch. "+
+				"Please disable `auto_merge.enabled` in `OWNERS.json` to approve pull requests without Auto-Merging.")
+	}
+
+	pr, err := client.GetPullRequest(ctx, repoOwner, repoName, issue)
 	if err != nil {
-		log.Println("info: could not change labels by the issue")
+		log.Println("info: could not fetch the pull request information.")
 		return false, err
 	}
 
-	prSvc := client.PullRequests
-	pr, _, err := prSvc.Get(ctx, repoOwner, repoName, issue)
+	if pr.State != "open" {
+		return rejectCommand(fmt.Sprintf("#%v is already closed", issue), "Please reopen it before approving.")
+	}
+
+	if reason := c.Info.BlockingReason(pr.Draft, pr.Title, pr.Labels); reason != "" {
+		return rejectCommand(fmt.Sprintf("#%v %v", issue, reason), blockingDetail)
+	}
+
+	headSha := pr.HeadSHA
+
+	ok, satisfied := c.collectApproval(ctx, issue, headSha, sender)
+	if !ok {
+		return false, errors.New("error: could not record the approval")
+	}
+
+	if !satisfied {
+		log.Printf("info: #%v does not have enough approvals yet\n", issue)
+		return true, nil
+	}
+
+	currentLabels := operation.GetLabelsByIssue(ctx, client, repoOwner, repoName, issue)
+	if currentLabels == nil {
+		return false, nil
+	}
+
+	labels := operation.AddAwaitingMergeLabel(c.Info.Labels, currentLabels)
+
+	// https://github.com/nekoya/popuko/blob/master/web.py
+	err = client.ReplaceLabels(ctx, repoOwner, repoName, issue, labels)
 	if err != nil {
-		log.Println("info: could not fetch the pull request information.")
+		log.Println("info: could not change labels by the issue")
 		return false, err
 	}
 
-	headSha := *pr.Head.SHA
-	if ok := commentApprovedSha(ctx, cmd, issueSvc, repoOwner, repoName, issue, headSha, sender); !ok {
+	if ok := commentApprovedSha(ctx, cmd, client, c.Reporter, repoOwner, repoName, issue, headSha, sender); !ok {
 		log.Println("info: could not create the comment to declare the head is approved.")
 		return false, err
 	}
@@ -146,6 +184,8 @@ func (c *AcceptCommand) acceptChangeset(ctx context.Context, ev *github.IssueCom
 		item := &queue.AutoMergeQueueItem{
 			PullRequest: issue,
 			PrHead:      headSha,
+			Priority:    cmd.Priority(),
+			DependsOn:   resolveDependencies(repoOwner, repoName, issue, pr.Body, q.GetDependencies(issue)),
 		}
 		ok, mutated := queuePullReq(q, item)
 		if !ok {
@@ -156,26 +196,103 @@ func (c *AcceptCommand) acceptChangeset(ctx context.Context, ev *github.IssueCom
 			q.Save()
 		}
 
+		if cycle := findCycle(q.Awaiting(), repoOwner, repoName, issue); cycle != nil {
+			dropCycle(ctx, client, c.Reporter, c.Info.Labels, repoOwner, repoName, q, cycle)
+			q.Save()
+			if !q.HasActive() && q.Front() != nil {
+				tryNextItem(ctx, client, c.Notifier, c.Reporter, repoOwner, repoName, q, c.Info)
+			}
+			return true, nil
+		}
+
 		if q.HasActive() {
-			commentAsPostponed(ctx, issueSvc, repoOwner, repoName, issue)
+			commentAsPostponed(ctx, client, c.Reporter, repoOwner, repoName, q, issue)
 			return true, nil
 		}
 
 		if next := q.Front(); next != item {
-			commentAsPostponed(ctx, issueSvc, repoOwner, repoName, issue)
+			commentAsPostponed(ctx, client, c.Reporter, repoOwner, repoName, q, issue)
 		}
 
-		tryNextItem(ctx, client, repoOwner, repoName, q, c.Info.AutoBranchName)
+		tryNextItem(ctx, client, c.Notifier, c.Reporter, repoOwner, repoName, q, c.Info)
 	}
 
 	log.Printf("info: complete merge the pull request %v\n", issue)
 	return true, nil
 }
 
+const blockingDetail = "Please mark it as ready for review, or remove the blocking label or the title prefix before approving. " +
+	"They are configured by `merge.allow_draft`, `merge.blocking_labels` and `merge.blocking_title_patterns` in `OWNERS.json`."
+
+// collectApproval records the approval by `sender` and returns whether
+// the pull request has been approved by the required number of reviewers.
+func (c *AcceptCommand) collectApproval(ctx context.Context, number int, headSha string, sender string) (ok bool, satisfied bool) {
+	var files []string
+	if c.Info.HasApprovalRules() {
+		ok, files = operation.GetChangedFiles(ctx, c.Client, c.Owner, c.Name, number)
+		if !ok {
+			return false, false
+		}
+	}
+
+	required := c.Info.RequiredApprovals(files)
+	log.Printf("debug: #%v requires %v approvals\n", number, required)
+	if required <= 1 {
+		return true, true
+	}
+
+	qHandle := c.AutoMergeRepo.Get(c.Owner, c.Name)
+	if qHandle == nil {
+		log.Println("error: cannot get the queue handle")
+		return false, false
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+
+	// Reviewers named by `r=<reviewer>` are only for the attribution.
+	// Each of them must approve by themselves to be counted.
+	if !c.Info.IsReviewer(sender) {
+		log.Printf("info: `%v` is not a reviewer. The approval of #%v is not counted\n", sender, number)
+		return true, false
+	}
+
+	approval := q.AddApproval(number, headSha, sender)
+	q.Save()
+
+	rest := required - len(approval.Reviewers)
+	if rest <= 0 {
+		return true, true
+	}
+
+	comment := fmt.Sprintf(":bookmark: Commit %v has been approved by %v. This requires %v more approval(s) from other reviewers before queueing.", headSha, quoteNames(approval.Reviewers), rest)
+	t := &report.Transition{
+		Kind:    report.KindPartiallyApproved,
+		Message: comment,
+		SHA:     headSha,
+	}
+	if ok := c.Reporter.Report(ctx, c.Client, c.Owner, c.Name, number, t); !ok {
+		log.Println("info: could not create the comment to declare the rest of approvals.")
+	}
+
+	return true, false
+}
+
+func quoteNames(names []string) string {
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		list = append(list, fmt.Sprintf("`%v`", name))
+	}
+	return strings.Join(list, ", ")
+}
+
 func commentApprovedSha(
 	ctx context.Context,
 	cmd input.AcceptChangesetCommand,
-	issues *github.IssuesService,
+	client forge.Client,
+	reporter *report.Reporter,
 	owner,
 	name string,
 	number int,
@@ -185,13 +302,7 @@ func commentApprovedSha(
 	var reviewers string
 	switch cmd := cmd.(type) {
 	case *input.AcceptChangeByOthersCommand:
-		{
-			list := make([]string, 0, len(cmd.Reviewer))
-			for _, name := range cmd.Reviewer {
-				list = append(list, fmt.Sprintf("`%v`", name))
-			}
-			reviewers = strings.Join(list, ", ")
-		}
+		reviewers = quoteNames(cmd.Reviewer)
 	case *input.AcceptChangeByReviewerCommand:
 		reviewers = fmt.Sprintf("`%v`", sender)
 	default:
@@ -200,7 +311,15 @@ func commentApprovedSha(
 	}
 
 	comment := fmt.Sprintf(":pushpin: Commit %v has been approved by %v", sha, reviewers)
-	if ok := operation.AddComment(ctx, issues, owner, name, number, comment); !ok {
+	if p := cmd.Priority(); p != 0 {
+		comment += fmt.Sprintf(" with the priority %v", p)
+	}
+	t := &report.Transition{
+		Kind:    report.KindApproved,
+		Message: comment,
+		SHA:     sha,
+	}
+	if ok := reporter.Report(ctx, client, owner, name, number, t); !ok {
 		log.Println("info: could not create the comment to declare the head is approved.")
 		return false
 	}
@@ -229,7 +348,13 @@ func queuePullReq(queue *queue.AutoMergeQueue, item *queue.AutoMergeQueueItem) (
 	has, awaiting := queue.IsAwaiting(item.PullRequest)
 	if has {
 		if sameHead := (awaiting.PrHead == item.PrHead); sameHead {
-			return true, false
+			if awaiting.Priority == item.Priority {
+				return true, false
+			}
+
+			// Keep its position in the queue. The priority matters only to bypass the closed tree.
+			awaiting.Priority = item.Priority
+			return true, true
 		}
 
 		if ok := queue.RemoveAwaiting(item.PullRequest); !ok {
@@ -247,12 +372,28 @@ func queuePullReq(queue *queue.AutoMergeQueue, item *queue.AutoMergeQueueItem) (
 	return true, true
 }
 
-func commentAsPostponed(ctx context.Context, issueSvc *github.IssuesService, owner, name string, issue int) {
+func commentAsPostponed(ctx context.Context, client forge.Client, reporter *report.Reporter, owner, name string, q *queue.AutoMergeQueue, issue int) {
 	log.Printf("info: pull request (%v) has been queued but other is active.\n", issue)
 	{
 		comment := ":postbox: This pull request is queued. Please await the time."
-		if ok := operation.AddComment(ctx, issueSvc, owner, name, issue, comment); !ok {
+		t := &report.Transition{
+			Kind:     report.KindQueued,
+			Message:  comment,
+			Position: queuePosition(q, issue),
+		}
+		if ok := reporter.Report(ctx, client, owner, name, issue, t); !ok {
 			log.Println("info: could not create the comment to declare to merge this.")
 		}
 	}
 }
+
+// queuePosition returns the 1-origin position of the pull request in the awaiting items.
+// This returns 0 if it's not awaiting.
+func queuePosition(q *queue.AutoMergeQueue, number int) int {
+	for i, item := range q.Awaiting() {
+		if item.PullRequest == number {
+			return i + 1
+		}
+	}
+	return 0
+}
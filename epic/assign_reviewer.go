@@ -2,49 +2,104 @@ package epic
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
 	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/setting"
 )
 
-func AssignReviewer(ctx context.Context, client *github.Client, ev *github.IssueCommentEvent, assignees []string) (bool, error) {
-	log.Printf("info: Start: assign the reviewer by %v\n", *ev.Comment.ID)
-	defer log.Printf("info: End: assign the reviewer by %v\n", *ev.Comment.ID)
+func AssignReviewer(ctx context.Context, client forge.Client, ev *forge.CommentEvent, assignees []string, info *setting.RepositoryInfo) (bool, error) {
+	log.Printf("info: Start: assign the reviewer by %v\n", ev.CommentID)
+	defer log.Printf("info: End: assign the reviewer by %v\n", ev.CommentID)
 
-	issueSvc := client.Issues
-
-	repoOwner := *ev.Repo.Owner.Login
+	repoOwner := ev.Owner
 	log.Printf("debug: repository owner is %v\n", repoOwner)
-	repo := *ev.Repo.Name
+	repo := ev.Name
 	log.Printf("debug: repository name is %v\n", repo)
 
-	issue := *ev.Issue
-	issueNum := *ev.Issue.Number
+	issueNum := ev.Number
 	log.Printf("debug: issue number is %v\n", issueNum)
 
-	// https://godoc.org/github.com/google/go-github/github#Issue
-	// 	> If PullRequestLinks is nil, this is an issue, and if PullRequestLinks is not nil, this is a pull request.
-	if issue.PullRequestLinks == nil {
+	if !ev.IsPullRequest {
 		log.Println("info: the issue is pull request")
-		return false, nil
+		return rejectCommand(fmt.Sprintf("#%v is not a pull request", issueNum), "")
 	}
 
-	currentLabels := operation.GetLabelsByIssue(ctx, issueSvc, repoOwner, repo, issueNum)
+	currentLabels := operation.GetLabelsByIssue(ctx, client, repoOwner, repo, issueNum)
 	if currentLabels == nil {
 		return false, nil
 	}
 
 	log.Printf("debug: assignees is %v\n", assignees)
 
-	_, _, err := issueSvc.AddAssignees(ctx, repoOwner, repo, issueNum, assignees)
-	if err != nil {
-		log.Println("info: could not change assignees.")
-		return false, err
+	opener := ev.IssueUser
+	availability := newReviewerAvailability(ctx, client, repoOwner, repo, info)
+	users := make([]string, 0, len(assignees))
+	notes := make([]string, 0)
+	for _, name := range assignees {
+		ok, org, slug := setting.SplitTeamName(name)
+		if !ok {
+			if ok, reason := availability.check(name); !ok {
+				notes = append(notes, reason)
+
+				// Choose someone else who can review instead of the unavailable reviewer.
+				excluded := append(append([]string{}, assignees...), users...)
+				ok, substitute := pickReviewerRandomly(availability.filter(excludeNames(info.Reviewers(), excluded)), opener)
+				if !ok {
+					notes = append(notes, "There is no other reviewer who can review this now.")
+					continue
+				}
+
+				notes = append(notes, fmt.Sprintf("Assign `%v` instead.", substitute))
+				users = append(users, substitute)
+				continue
+			}
+
+			users = append(users, name)
+			continue
+		}
+
+		if requestReviewToTeam(ctx, client, repoOwner, repo, issueNum, org, slug) {
+			continue
+		}
+
+		// Fallback to pick a member if we cannot request a review to the team
+		// (e.g. the team belongs to other organization).
+		ok, members := fetchTeamMembers(ctx, client, org, slug)
+		if !ok {
+			continue
+		}
+
+		ok, member := pickReviewerRandomly(availability.filter(members), opener)
+		if !ok {
+			log.Printf("info: there is no member who can review #%v in `%v`\n", issueNum, name)
+			continue
+		}
+
+		log.Printf("info: pick `%v` from `%v`\n", member, name)
+		users = append(users, member)
+	}
+
+	if len(notes) > 0 {
+		comment := strings.Join(notes, "\n")
+		if ok := operation.AddComment(ctx, client, repoOwner, repo, issueNum, comment); !ok {
+			log.Println("info: could not create the comment about unavailable reviewers.")
+		}
 	}
 
-	labels := operation.AddAwaitingReviewLabel(currentLabels)
-	_, _, err = issueSvc.ReplaceLabelsForIssue(ctx, repoOwner, repo, issueNum, labels)
+	if len(users) > 0 {
+		err := client.AddAssignees(ctx, repoOwner, repo, issueNum, users)
+		if err != nil {
+			log.Println("info: could not change assignees.")
+			return false, err
+		}
+	}
+
+	labels := operation.AddAwaitingReviewLabel(info.Labels, currentLabels)
+	err := client.ReplaceLabels(ctx, repoOwner, repo, issueNum, labels)
 	if err != nil {
 		log.Println("info: could not change labels.")
 		return false, err
@@ -54,3 +109,37 @@ func AssignReviewer(ctx context.Context, client *github.Client, ev *github.Issue
 
 	return true, nil
 }
+
+func requestReviewToTeam(ctx context.Context, client forge.Client, owner, name string, number int, org, slug string) bool {
+	// A forge accepts only a team which belongs to the owner organization of the repository.
+	if org != owner {
+		log.Printf("info: `%v/%v` does not belong to `%v`\n", org, slug, owner)
+		return false
+	}
+
+	err := client.RequestTeamReview(ctx, owner, name, number, slug)
+	if err != nil {
+		log.Printf("info: could not request a review to `%v/%v`: %v\n", org, slug, err)
+		return false
+	}
+
+	return true
+}
+
+func excludeNames(list []string, excluded []string) []string {
+	result := make([]string, 0, len(list))
+	for _, name := range list {
+		found := false
+		for _, e := range excluded {
+			if name == e {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,160 @@
+package epic
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"strings"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/setting"
+	"github.com/voyagegroup/popuko/store"
+)
+
+type AutoAssignCommand struct {
+	Owner string
+	Name  string
+
+	Client forge.Client
+	Info   *setting.RepositoryInfo
+
+	AssignStore *store.FileStore
+}
+
+type autoAssignState struct {
+	// The reviewer who has been assigned by "round_robin" strategy at the last.
+	LastAssigned string `json:"last_assigned"`
+}
+
+func (c *AutoAssignCommand) AssignReviewerAutomatically(ctx context.Context, pr *forge.PullRequest) bool {
+	number := pr.Number
+	log.Printf("info: Start: assign the reviewer automatically to #%v\n", number)
+	defer log.Printf("info: End: assign the reviewer automatically to #%v\n", number)
+
+	if !c.Info.EnableAutoAssign {
+		log.Println("info: this repository does not enable to assign a reviewer automatically.")
+		return false
+	}
+
+	if pr.Draft {
+		log.Printf("info: #%v is a draft\n", number)
+		return false
+	}
+
+	// Respect the author's choice.
+	if len(pr.Assignees) > 0 || len(pr.RequestedReviewers) > 0 || len(pr.RequestedTeams) > 0 {
+		log.Printf("info: #%v has been already assigned to someone\n", number)
+		return false
+	}
+
+	author := pr.User
+	availability := newReviewerAvailability(ctx, c.Client, c.Owner, c.Name, c.Info)
+	candidates := availability.filter(excludeNames(c.Info.Reviewers(), []string{author}))
+	if len(candidates) == 0 {
+		log.Printf("info: there is no reviewer who can review #%v\n", number)
+		return false
+	}
+
+	ok, reviewer := c.pickReviewer(ctx, candidates, availability.load)
+	if !ok {
+		return false
+	}
+	log.Printf("info: pick `%v` as the reviewer of #%v by `%v`\n", reviewer, number, c.Info.AutoAssignStrategy)
+
+	client := c.Client
+	if err := client.AddAssignees(ctx, c.Owner, c.Name, number, []string{reviewer}); err != nil {
+		log.Printf("info: could not assign `%v` to #%v: %v\n", reviewer, number, err)
+		return false
+	}
+
+	currentLabels := operation.GetLabelsByIssue(ctx, client, c.Owner, c.Name, number)
+	if currentLabels != nil {
+		labels := operation.AddAwaitingReviewLabel(c.Info.Labels, currentLabels)
+		if err := client.ReplaceLabels(ctx, c.Owner, c.Name, number, labels); err != nil {
+			log.Printf("info: could not change labels of #%v: %v\n", number, err)
+		}
+	}
+
+	comment := strings.NewReplacer("{author}", author, "{reviewer}", reviewer).Replace(c.Info.WelcomeMessage)
+	if ok := operation.AddComment(ctx, client, c.Owner, c.Name, number, comment); !ok {
+		log.Println("info: could not create the welcome comment.")
+	}
+
+	return true
+}
+
+func (c *AutoAssignCommand) pickReviewer(ctx context.Context, candidates []string, load map[string]int) (bool, string) {
+	switch c.Info.AutoAssignStrategy {
+	case setting.AssignStrategyRoundRobin:
+		h := c.AssignStore.Get(c.Owner, c.Name)
+		if h == nil {
+			log.Println("error: cannot get the handle of the assignment state")
+			return false, ""
+		}
+
+		h.Lock()
+		defer h.Unlock()
+
+		var state autoAssignState
+		h.Load(&state)
+
+		reviewer := pickRoundRobin(candidates, state.LastAssigned)
+		state.LastAssigned = reviewer
+		if ok := h.Save(&state); !ok {
+			log.Println("warn: could not save the assignment state")
+		}
+		return true, reviewer
+	case setting.AssignStrategyLeastLoaded:
+		if load == nil {
+			var ok bool
+			ok, load = countAssignedPullRequests(ctx, c.Client, c.Owner, c.Name)
+			if !ok {
+				return false, ""
+			}
+		}
+		return true, pickLeastLoaded(candidates, load)
+	default:
+		return true, candidates[rand.Intn(len(candidates))]
+	}
+}
+
+// pickRoundRobin returns the next of `last` in the sorted `candidates`.
+func pickRoundRobin(candidates []string, last string) string {
+	for _, c := range candidates {
+		if c > last {
+			return c
+		}
+	}
+
+	return candidates[0]
+}
+
+// pickLeastLoaded returns the candidate who is assigned to the fewest pull requests.
+func pickLeastLoaded(candidates []string, load map[string]int) string {
+	picked := candidates[0]
+	for _, c := range candidates[1:] {
+		if load[c] < load[picked] {
+			picked = c
+		}
+	}
+
+	return picked
+}
+
+func countAssignedPullRequests(ctx context.Context, client forge.Client, owner, name string) (bool, map[string]int) {
+	list, err := client.ListOpenPullRequests(ctx, owner, name, "")
+	if err != nil {
+		log.Printf("warn: could not fetch opened pull requests: %v\n", err)
+		return false, nil
+	}
+
+	load := make(map[string]int)
+	for _, pr := range list {
+		for _, u := range pr.Assignees {
+			load[u]++
+		}
+	}
+
+	return true, load
+}
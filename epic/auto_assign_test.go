@@ -0,0 +1,49 @@
+package epic
+
+import "testing"
+
+func Test_pickRoundRobin(t *testing.T) {
+	candidates := []string{"alice", "bob", "carol"}
+
+	type Testcase struct {
+		last     string
+		expected string
+	}
+	list := []Testcase{
+		Testcase{
+			last:     "",
+			expected: "alice",
+		},
+		Testcase{
+			last:     "alice",
+			expected: "bob",
+		},
+		Testcase{
+			last:     "carol",
+			expected: "alice",
+		},
+		Testcase{
+			// The last one has been removed from the candidates.
+			last:     "bobby",
+			expected: "carol",
+		},
+	}
+
+	for _, test := range list {
+		if actual := pickRoundRobin(candidates, test.last); actual != test.expected {
+			t.Errorf("%+v should be `%v` but `%v`", test, test.expected, actual)
+		}
+	}
+}
+
+func Test_pickLeastLoaded(t *testing.T) {
+	candidates := []string{"alice", "bob", "carol"}
+	load := map[string]int{
+		"alice": 2,
+		"bob":   1,
+	}
+
+	if actual := pickLeastLoaded(candidates, load); actual != "carol" {
+		t.Errorf("should pick carol but %v", actual)
+	}
+}
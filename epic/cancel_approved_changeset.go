@@ -5,27 +5,28 @@ import (
 	"errors"
 	"log"
 
-	"github.com/google/go-github/v28/github"
-
+	"github.com/voyagegroup/popuko/forge"
 	"github.com/voyagegroup/popuko/input"
 	"github.com/voyagegroup/popuko/operation"
 	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
 	"github.com/voyagegroup/popuko/setting"
 )
 
 type CancelApprovedCommand struct {
 	BotName       string
-	Client        *github.Client
+	Client        forge.Client
 	Owner         string
 	Name          string
 	Number        int
 	Cmd           *input.CancelApprovedByReviewerCommand
 	Info          *setting.RepositoryInfo
 	AutoMergeRepo *queue.AutoMergeQRepo
+	Reporter      *report.Reporter
 }
 
-func (c *CancelApprovedCommand) CancelApprovedChangeSet(ctx context.Context, ev *github.IssueCommentEvent) (ok bool, err error) {
-	id := *ev.Comment.ID
+func (c *CancelApprovedCommand) CancelApprovedChangeSet(ctx context.Context, ev *forge.CommentEvent) (ok bool, err error) {
+	id := ev.CommentID
 	log.Printf("info: Start: merge the pull request by %v\n", id)
 	defer log.Printf("info: End: merge the pull request by %v\n", id)
 
@@ -34,12 +35,12 @@ func (c *CancelApprovedCommand) CancelApprovedChangeSet(ctx context.Context, ev
 		return false, nil
 	}
 
-	sender := *ev.Sender.Login
+	sender := ev.Sender
 	log.Printf("debug: command is sent from %v\n", sender)
 
 	if !c.Info.IsReviewer(sender) {
 		log.Printf("info: %v is not an reviewer registred to this bot.\n", sender)
-		return false, nil
+		return rejectReviewerOnly(sender, c.Info)
 	}
 
 	owner := c.Owner
@@ -47,12 +48,12 @@ func (c *CancelApprovedCommand) CancelApprovedChangeSet(ctx context.Context, ev
 	number := c.Number
 	log.Printf("debug: issue number is %v\n", number)
 
-	currentLabels := operation.GetLabelsByIssue(ctx, c.Client.Issues, owner, name, number)
+	currentLabels := operation.GetLabelsByIssue(ctx, c.Client, owner, name, number)
 	if currentLabels != nil {
-		labels := operation.AddAwaitingReviewLabel(currentLabels)
+		labels := operation.AddAwaitingReviewLabel(c.Info.Labels, currentLabels)
 
 		// https://github.com/nekoya/popuko/blob/master/web.py
-		_, _, err = c.Client.Issues.ReplaceLabelsForIssue(ctx, owner, name, number, labels)
+		err = c.Client.ReplaceLabels(ctx, owner, name, number, labels)
 		if err != nil {
 			log.Printf("info: could not change labels by the issue: %v\n", err)
 		}
@@ -60,12 +61,16 @@ func (c *CancelApprovedCommand) CancelApprovedChangeSet(ctx context.Context, ev
 
 	{
 		comment := ":outbox_tray: This has been cancelled from the approved queue by `" + sender + "`"
-		if ok := operation.AddComment(ctx, c.Client.Issues, owner, name, number, comment); !ok {
+		t := &report.Transition{
+			Kind:    report.KindCancelled,
+			Message: comment,
+		}
+		if ok := c.Reporter.Report(ctx, c.Client, owner, name, number, t); !ok {
 			log.Println("info: could not create the comment about what this pull request rejected.")
 		}
 	}
 
-	if c.Info.EnableAutoMerge {
+	{
 		qHandle := c.AutoMergeRepo.Get(owner, name)
 		if qHandle == nil {
 			log.Println("error: cannot get the queue handle")
@@ -76,7 +81,16 @@ func (c *CancelApprovedCommand) CancelApprovedChangeSet(ctx context.Context, ev
 		defer qHandle.Unlock()
 
 		q := qHandle.Load()
-		if found := q.RemoveAwaiting(number); found {
+
+		// All partial approvals are also cancelled.
+		mutated := q.RemoveApproval(number)
+		if c.Info.EnableAutoMerge {
+			if found := q.RemoveAwaiting(number); found {
+				mutated = true
+			}
+		}
+
+		if mutated {
 			q.Save()
 		}
 	}
@@ -0,0 +1,138 @@
+package epic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// isSamePatch returns true if `head` has the same changes as `accepted` against `base`
+// (e.g. the author only rebased the pull request onto the latest upstream).
+// This returns false if we cannot compare them.
+func isSamePatch(ctx context.Context, client forge.Client, owner, name, base, accepted, head string) bool {
+	if accepted == head {
+		return true
+	}
+
+	before, err := client.CompareCommits(ctx, owner, name, base, accepted)
+	if err == forge.ErrUnsupportedCompare {
+		log.Printf("debug: cannot compare %v with %v on this forge\n", accepted, head)
+		return false
+	}
+	if err != nil {
+		log.Printf("info: could not compare %v with %v: %v\n", base, accepted, err)
+		return false
+	}
+
+	after, err := client.CompareCommits(ctx, owner, name, base, head)
+	if err != nil {
+		log.Printf("info: could not compare %v with %v: %v\n", base, head, err)
+		return false
+	}
+
+	return isSameFileDiffs(before, after)
+}
+
+func isSameFileDiffs(a, b []*forge.FileDiff) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = sortFileDiffs(a)
+	b = sortFileDiffs(b)
+	for i := range a {
+		x := a[i]
+		y := b[i]
+		if x.Filename != y.Filename || x.Status != y.Status {
+			return false
+		}
+
+		// We cannot compare a binary file by its patch. The blob is same only if the file is same.
+		if x.Patch == "" || y.Patch == "" {
+			if x.SHA != y.SHA {
+				return false
+			}
+			continue
+		}
+
+		if normalizePatch(x.Patch) != normalizePatch(y.Patch) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortFileDiffs(list []*forge.FileDiff) []*forge.FileDiff {
+	sorted := make([]*forge.FileDiff, len(list))
+	copy(sorted, list)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Filename < sorted[j].Filename
+	})
+	return sorted
+}
+
+// normalizePatch removes hunk headers from the patch
+// because line numbers in them are shifted by changes of the upstream.
+func normalizePatch(patch string) string {
+	lines := strings.Split(patch, "\n")
+	result := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if strings.HasPrefix(l, "@@") {
+			continue
+		}
+		result = append(result, l)
+	}
+	return strings.Join(result, "\n")
+}
+
+// carryOverApproval moves the approval and the awaiting item of the pull request from `accepted` to `head`
+// without changing its position in the queue, and posts the note about it.
+func carryOverApproval(ctx context.Context, client forge.Client, reporter *report.Reporter, owner, name string, q *queue.AutoMergeQueue, number int, accepted, head string) {
+	log.Printf("info: keep the approval of #%v because %v has the same changes as %v\n", number, head, accepted)
+
+	if approval := q.GetApproval(number); approval != nil && approval.PrHead == accepted {
+		approval.PrHead = head
+	}
+
+	if has, item := q.IsAwaiting(number); has && item.PrHead == accepted {
+		item.PrHead = head
+	}
+
+	comment := fmt.Sprintf(":arrows_counterclockwise: The head has been changed from %v to %v, "+
+		"but it has the same changes as the approved one. The approval is kept.", accepted, head)
+	t := &report.Transition{
+		Kind:     report.KindRebased,
+		Message:  comment,
+		SHA:      head,
+		Position: queuePosition(q, number),
+	}
+	if ok := reporter.Report(ctx, client, owner, name, number, t); !ok {
+		log.Println("info: could not create the comment about the kept approval.")
+	}
+}
+
+// rebuildActiveItem recreates the auto branch for the new head of the active item
+// which has the same changes as the approved one. This returns false if we cannot create it.
+func rebuildActiveItem(ctx context.Context, client forge.Client, reporter *report.Reporter, owner, name string, repoInfo *setting.RepositoryInfo, active *queue.AutoMergeQueueItem, pr *forge.PullRequest) bool {
+	ok, commit := operation.TryWithDefaultBranch(ctx, client, reporter, owner, name, pr, repoInfo.AutoBranchName)
+	if !ok {
+		log.Printf("info: we cannot rebuild the auto branch for #%v\n", pr.Number)
+		return false
+	}
+
+	now := time.Now()
+	active.PrHead = pr.HeadSHA
+	active.AutoBranchHead = &commit
+	active.StartedAt = &now
+	log.Printf("info: rebuild the auto branch for the new head of #%v\n", pr.Number)
+	return true
+}
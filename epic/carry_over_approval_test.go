@@ -0,0 +1,54 @@
+package epic
+
+import (
+	"testing"
+
+	"github.com/voyagegroup/popuko/forge"
+)
+
+func TestIsSameFileDiffs(t *testing.T) {
+	base := []*forge.FileDiff{
+		&forge.FileDiff{Filename: "a.go", Status: "modified", Patch: "@@ -1,3 +1,4 @@ func a()\n+fix"},
+		&forge.FileDiff{Filename: "logo.png", Status: "added", SHA: "abc"},
+	}
+
+	type Testcase struct {
+		input    []*forge.FileDiff
+		expected bool
+	}
+	list := []Testcase{
+		Testcase{
+			input: []*forge.FileDiff{
+				&forge.FileDiff{Filename: "logo.png", Status: "added", SHA: "abc"},
+				&forge.FileDiff{Filename: "a.go", Status: "modified", Patch: "@@ -10,3 +10,4 @@ func a()\n+fix"},
+			},
+			expected: true,
+		},
+		Testcase{
+			input: []*forge.FileDiff{
+				&forge.FileDiff{Filename: "a.go", Status: "modified", Patch: "@@ -1,3 +1,4 @@ func a()\n+fix it"},
+				&forge.FileDiff{Filename: "logo.png", Status: "added", SHA: "abc"},
+			},
+			expected: false,
+		},
+		Testcase{
+			input: []*forge.FileDiff{
+				&forge.FileDiff{Filename: "a.go", Status: "modified", Patch: "@@ -1,3 +1,4 @@ func a()\n+fix"},
+				&forge.FileDiff{Filename: "logo.png", Status: "added", SHA: "def"},
+			},
+			expected: false,
+		},
+		Testcase{
+			input: []*forge.FileDiff{
+				&forge.FileDiff{Filename: "a.go", Status: "modified", Patch: "@@ -1,3 +1,4 @@ func a()\n+fix"},
+			},
+			expected: false,
+		},
+	}
+	for i, tc := range list {
+		if actual := isSameFileDiffs(base, tc.input); actual != tc.expected {
+			t.Errorf("#%v should be %v but %v", i, tc.expected, actual)
+			return
+		}
+	}
+}
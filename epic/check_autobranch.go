@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/notify"
 	"github.com/voyagegroup/popuko/operation"
 	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
 	"github.com/voyagegroup/popuko/setting"
 )
 
@@ -22,35 +25,21 @@ type StateChangeInfo struct {
 	IsRelatedToAutoBranchBody func(string) bool
 }
 
-func CheckAutoBranchWithStatusEvent(ctx context.Context, client *github.Client, autoMergeRepo *queue.AutoMergeQRepo, ev *github.StatusEvent) {
+func CheckAutoBranch(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, autoMergeRepo *queue.AutoMergeQRepo, ev *forge.StatusEvent) {
 	info := StateChangeInfo{
-		Status:                    *ev.State,
-		Owner:                     *ev.Repo.Owner.Login,
-		Name:                      *ev.Repo.Name,
-		DefaultBranch:             ev.Repo.GetDefaultBranch(),
-		NotHandle:                 *ev.State == "pending",
-		ID:                        *ev.ID,
-		SHA:                       *ev.SHA,
+		Status:                    ev.State,
+		Owner:                     ev.Owner,
+		Name:                      ev.Name,
+		DefaultBranch:             ev.DefaultBranch,
+		NotHandle:                 !ev.Completed,
+		ID:                        ev.ID,
+		SHA:                       ev.SHA,
 		IsRelatedToAutoBranchBody: isRelatedToAutoBranchBodyWithStatusEvent(ev),
 	}
-	checkAutoBranch(ctx, client, autoMergeRepo, info)
+	checkAutoBranch(ctx, client, notifier, reporter, autoMergeRepo, info)
 }
 
-func CheckAutoBranchWithCheckSuiteEvent(ctx context.Context, client *github.Client, autoMergeRepo *queue.AutoMergeQRepo, ev *github.CheckSuiteEvent) {
-	info := StateChangeInfo{
-		Status:                    *ev.CheckSuite.Conclusion,
-		Owner:                     *ev.Repo.Owner.Login,
-		Name:                      *ev.Repo.Name,
-		DefaultBranch:             ev.Repo.GetDefaultBranch(),
-		NotHandle:                 *ev.CheckSuite.Status != "completed",
-		ID:                        *ev.CheckSuite.ID,
-		SHA:                       *ev.CheckSuite.HeadSHA,
-		IsRelatedToAutoBranchBody: isRelatedToAutoBranchBodyWithCheckSuiteEvent(ev),
-	}
-	checkAutoBranch(ctx, client, autoMergeRepo, info)
-}
-
-func checkAutoBranch(ctx context.Context, client *github.Client, autoMergeRepo *queue.AutoMergeQRepo, info StateChangeInfo) {
+func checkAutoBranch(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, autoMergeRepo *queue.AutoMergeQRepo, info StateChangeInfo) {
 	log.Println("info: Start: checkAutoBranch")
 	defer log.Println("info: End: checkAutoBranch")
 
@@ -62,7 +51,7 @@ func checkAutoBranch(ctx context.Context, client *github.Client, autoMergeRepo *
 
 	log.Printf("info: Target repository is %v/%v\n", info.Owner, info.Name)
 
-	repoInfo := GetRepositoryInfo(ctx, client.Repositories, info.Owner, info.Name, info.DefaultBranch)
+	repoInfo := GetRepositoryInfo(ctx, client, info.Owner, info.Name, info.DefaultBranch)
 	if repoInfo == nil {
 		log.Println("debug: cannot get repositoryInfo")
 		return
@@ -105,28 +94,39 @@ func checkAutoBranch(ctx context.Context, client *github.Client, autoMergeRepo *
 	}
 	log.Println("info: the status event is related to auto branch.")
 
-	mergeSucceedItem(ctx, client, info.Owner, info.Name, repoInfo, q, info)
+	if retryActiveItem(ctx, client, reporter, info.Owner, info.Name, repoInfo, q, info) {
+		q.Save()
+		return
+	}
+
+	if info.Status == "success" {
+		// The tree may have been closed while testing the auto branch.
+		if closed, reason := repoInfo.IsTreeClosed(time.Now()); closed && !repoInfo.CanBypassClosedTree(active.Priority) {
+			putBackActiveItem(q)
+			holdQueue(ctx, client, notifier, reporter, info.Owner, info.Name, q, reason)
+			q.Save()
+			return
+		}
+	}
+
+	mergeSucceedItem(ctx, client, notifier, reporter, info.Owner, info.Name, repoInfo, q, info)
 
 	q.RemoveActive()
 	q.Save()
 
-	tryNextItem(ctx, client, info.Owner, info.Name, q, repoInfo.AutoBranchName)
+	tryNextItem(ctx, client, notifier, reporter, info.Owner, info.Name, q, repoInfo)
 
 	log.Println("info: complete to start the next trying")
 }
 
-func isRelatedToAutoBranchBodyWithStatusEvent(ev *github.StatusEvent) func(string) bool {
+func isRelatedToAutoBranchBodyWithStatusEvent(ev *forge.StatusEvent) func(string) bool {
 	return func(autoBranch string) bool {
-		return operation.IsIncludeAutoBranch(ev.Branches, autoBranch)
-	}
-}
-
-func isRelatedToAutoBranchBodyWithCheckSuiteEvent(ev *github.CheckSuiteEvent) func(string) bool {
-	return func(autoBranch string) bool {
-		if ev.CheckSuite.HeadBranch == nil {
-			return false
+		// Some forges do not tell branches which contain the commit.
+		// We can only check the commit hash for them.
+		if ev.Branches == nil {
+			return true
 		}
-		return *ev.CheckSuite.HeadBranch == autoBranch
+		return operation.IsIncludeAutoBranch(ev.Branches, autoBranch)
 	}
 }
 
@@ -161,7 +161,9 @@ func checkCommitHashOnTrying(active *queue.AutoMergeQueueItem, info StateChangeI
 
 func mergeSucceedItem(
 	ctx context.Context,
-	client *github.Client,
+	client forge.Client,
+	notifier *notify.Notifier,
+	reporter *report.Reporter,
 	owner string,
 	name string,
 	repoInfo *setting.RepositoryInfo,
@@ -172,13 +174,13 @@ func mergeSucceedItem(
 
 	prNum := active.PullRequest
 
-	prInfo, _, err := client.PullRequests.Get(ctx, owner, name, prNum)
+	prInfo, err := client.GetPullRequest(ctx, owner, name, prNum)
 	if err != nil {
 		log.Println("info: could not fetch the pull request information.")
 		return false
 	}
 
-	if *prInfo.State != "open" {
+	if prInfo.State != "open" {
 		log.Printf("info: the pull request #%v has been resolved the state\n", prNum)
 		return true
 	}
@@ -187,15 +189,27 @@ func mergeSucceedItem(
 		log.Println("info: could not merge pull request")
 
 		comment := ":collision: The result of what tried to merge this pull request is `" + info.Status + "`."
-		commentStatus(ctx, client, owner, name, prNum, comment, repoInfo.AutoBranchName)
-
-		currentLabels := operation.GetLabelsByIssue(ctx, client.Issues, owner, name, prNum)
+		if active.Retries > 0 {
+			comment += fmt.Sprintf(" We have retried %v time(s).", active.Retries)
+		}
+		commentStatus(ctx, client, reporter, owner, name, prNum, report.KindTestsFailed, comment, repoInfo.AutoBranchName)
+
+		notifier.Notify(&notify.Event{
+			Kind:    notify.EventTestsFailed,
+			Owner:   owner,
+			Name:    name,
+			Number:  prNum,
+			SHA:     info.SHA,
+			Message: "The result of the auto branch is `" + info.Status + "`.",
+		})
+
+		currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, prNum)
 		if currentLabels == nil {
 			return false
 		}
 
-		labels := operation.AddFailsTestsWithUpsreamLabel(currentLabels)
-		_, _, err = client.Issues.ReplaceLabelsForIssue(ctx, owner, name, prNum, labels)
+		labels := operation.AddFailsTestsWithUpsreamLabel(repoInfo.Labels, currentLabels)
+		err = client.ReplaceLabels(ctx, owner, name, prNum, labels)
 		if err != nil {
 			log.Println("warn: could not change labels of the issue")
 		}
@@ -204,23 +218,32 @@ func mergeSucceedItem(
 	}
 
 	comment := ":tada: The result of what tried to merge this pull request is `" + info.Status + "`."
-	commentStatus(ctx, client, owner, name, prNum, comment, repoInfo.AutoBranchName)
+	commentStatus(ctx, client, reporter, owner, name, prNum, report.KindMerged, comment, repoInfo.AutoBranchName)
 
-	if ok := operation.MergePullRequest(ctx, client, owner, name, prInfo, active.PrHead); !ok {
+	if ok := operation.MergePullRequest(ctx, client, reporter, repoInfo.Labels, owner, name, prInfo, active.PrHead); !ok {
 		log.Printf("info: cannot merge pull request #%v\n", prNum)
 		return false
 	}
 
+	notifier.Notify(&notify.Event{
+		Kind:    notify.EventMerged,
+		Owner:   owner,
+		Name:    name,
+		Number:  prNum,
+		SHA:     active.PrHead,
+		Message: "Merged " + active.PrHead + ".",
+	})
+
 	if repoInfo.DeleteAfterAutoMerge {
-		operation.DeleteBranchByPullRequest(ctx, client.Git, prInfo)
+		operation.DeleteBranchByPullRequest(ctx, client, prInfo)
 	}
 
 	log.Printf("info: complete merging #%v into master\n", prNum)
 	return true
 }
 
-func commentStatus(ctx context.Context, client *github.Client, owner, name string, prNum int, comment string, autoBranch string) {
-	status, _, err := client.Repositories.GetCombinedStatus(ctx, owner, name, autoBranch, nil)
+func commentStatus(ctx context.Context, client forge.Client, reporter *report.Reporter, owner, name string, prNum int, kind string, comment string, autoBranch string) {
+	status, err := client.GetCombinedStatus(ctx, owner, name, autoBranch)
 	if err != nil {
 		log.Println("error: could not get the status about the auto branch.")
 	}
@@ -229,44 +252,61 @@ func commentStatus(ctx context.Context, client *github.Client, owner, name strin
 		comment += "\n\n"
 
 		for _, s := range status.Statuses {
-			if s.TargetURL == nil {
+			if s.TargetURL == "" {
 				continue
 			}
 
 			var item string
-			if s.Description == nil || *s.Description == "" {
-				item = fmt.Sprintf("* %v\n", *s.TargetURL)
+			if s.Description == "" {
+				item = fmt.Sprintf("* %v\n", s.TargetURL)
 			} else {
-				item = fmt.Sprintf("* [%v](%v)\n", *s.Description, *s.TargetURL)
+				item = fmt.Sprintf("* [%v](%v)\n", s.Description, s.TargetURL)
 			}
 
 			comment += item
 		}
 	}
 
-	if ok := operation.AddComment(ctx, client.Issues, owner, name, prNum, comment); !ok {
+	t := &report.Transition{
+		Kind:    kind,
+		Message: comment,
+		CI:      status,
+	}
+	if ok := reporter.Report(ctx, client, owner, name, prNum, t); !ok {
 		log.Println("error: could not write the comment about the result of auto branch.")
 	}
 }
 
-func tryNextItem(ctx context.Context, client *github.Client, owner, name string, q *queue.AutoMergeQueue, autoBranch string) (ok, hasNext bool) {
+func tryNextItem(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, owner, name string, q *queue.AutoMergeQueue, repoInfo *setting.RepositoryInfo) (ok, hasNext bool) {
 	defer q.Save()
 
-	next, nextInfo := getNextAvailableItem(ctx, client, owner, name, q)
+	closed, reason := repoInfo.IsTreeClosed(time.Now())
+	if !closed {
+		clearWaitingMarks(q)
+	}
+
+	next, nextInfo := getNextAvailableItem(ctx, client, notifier, reporter, owner, name, q, repoInfo, closed)
 	if next == nil {
+		if closed && q.Front() != nil {
+			holdQueue(ctx, client, notifier, reporter, owner, name, q, reason)
+			return true, false
+		}
+
 		log.Printf("info: there is no awating item in the queue of %v/%v\n", owner, name)
 		return true, false
 	}
 
 	nextNum := next.PullRequest
 
-	ok, commit := operation.TryWithDefaultBranch(ctx, client, owner, name, nextInfo, autoBranch)
+	ok, commit := operation.TryWithDefaultBranch(ctx, client, reporter, owner, name, nextInfo, repoInfo.AutoBranchName)
 	if !ok {
 		log.Printf("info: we cannot try #%v with the latest `master`.", nextNum)
-		return tryNextItem(ctx, client, owner, name, q, autoBranch)
+		return tryNextItem(ctx, client, notifier, reporter, owner, name, q, repoInfo)
 	}
 
+	now := time.Now()
 	next.AutoBranchHead = &commit
+	next.StartedAt = &now
 	q.SetActive(next)
 	log.Printf("info: pin #%v as the active item to queue\n", nextNum)
 
@@ -275,44 +315,102 @@ func tryNextItem(ctx context.Context, client *github.Client, owner, name string,
 
 func getNextAvailableItem(
 	ctx context.Context,
-	client *github.Client,
+	client forge.Client,
+	notifier *notify.Notifier,
+	reporter *report.Reporter,
 	owner string,
 	name string,
-	queue *queue.AutoMergeQueue) (*queue.AutoMergeQueueItem, *github.PullRequest) {
-
-	issueSvc := client.Issues
-	prSvc := client.PullRequests
+	q *queue.AutoMergeQueue,
+	repoInfo *setting.RepositoryInfo,
+	closed bool) (*queue.AutoMergeQueueItem, *forge.PullRequest) {
 
 	log.Println("Start to find the next item")
 	defer log.Println("End to find the next item")
 
+	statusLabels := repoInfo.Labels
+
+	// Items which wait for their dependencies or for the merge window stay at the front of the queue.
+	waiting := make([]*queue.AutoMergeQueueItem, 0)
+	defer func() {
+		for i := len(waiting) - 1; i >= 0; i-- {
+			q.PushFront(waiting[i])
+		}
+	}()
+
 	for {
-		ok, next := queue.TakeNext()
+		ok, next := q.TakeNext()
 		if !ok || next == nil {
 			log.Printf("debug: there is no awating item in the queue of %v/%v\n", owner, name)
 			return nil, nil
 		}
 
+		// Leave items in the queue while the tree is closed unless they have the priority to bypass it.
+		if closed && !repoInfo.CanBypassClosedTree(next.Priority) {
+			log.Printf("info: #%v waits for the tree of %v/%v to open\n", next.PullRequest, owner, name)
+			waiting = append(waiting, next)
+			continue
+		}
+
 		log.Println("debug: the next item has fetched from queue.")
 		prNum := next.PullRequest
 
-		nextInfo, _, err := prSvc.Get(ctx, owner, name, prNum)
+		nextInfo, err := client.GetPullRequest(ctx, owner, name, prNum)
 		if err != nil {
 			log.Println("debug: could not fetch the pull request information.")
 			continue
 		}
 
-		if next.PrHead != *nextInfo.Head.SHA {
-			operation.CommentHeadIsDifferentFromAccepted(ctx, issueSvc, owner, name, prNum)
+		if accepted := next.PrHead; accepted != nextInfo.HeadSHA && isSamePatch(ctx, client, owner, name, nextInfo.BaseRef, accepted, nextInfo.HeadSHA) {
+			next.PrHead = nextInfo.HeadSHA
+			carryOverApproval(ctx, client, reporter, owner, name, q, prNum, accepted, nextInfo.HeadSHA)
+		}
+
+		if next.PrHead != nextInfo.HeadSHA {
+			operation.CommentHeadIsDifferentFromAccepted(ctx, client, reporter, statusLabels, owner, name, prNum)
+			notifier.Notify(&notify.Event{
+				Kind:    notify.EventHeadChanged,
+				Owner:   owner,
+				Name:    name,
+				Number:  prNum,
+				SHA:     nextInfo.HeadSHA,
+				Message: "The head has been changed from " + next.PrHead + " after it was approved.",
+			})
 			continue
 		}
 
-		if state := *nextInfo.State; state != "open" {
+		if state := nextInfo.State; state != "open" {
 			log.Printf("debug: the pull request #%v has been resolved the state as `%v`\n", prNum, state)
 			continue
 		}
 
-		ok, mergeable := operation.IsMergeable(ctx, prSvc, owner, name, prNum, nextInfo)
+		// `Depends-on:` may have been edited after it was approved.
+		next.DependsOn = resolveDependencies(owner, name, prNum, nextInfo.Body, q.GetDependencies(prNum))
+		if cycle := findCycle(append(append(q.Awaiting(), waiting...), next), owner, name, prNum); cycle != nil {
+			waiting = excludeItems(waiting, cycle)
+			dropCycle(ctx, client, reporter, statusLabels, owner, name, q, cycle)
+			continue
+		}
+
+		if ready, closed := checkDependencies(ctx, client, next); closed != nil {
+			commentAsDependencyClosed(ctx, client, reporter, statusLabels, owner, name, nextInfo, closed)
+			continue
+		} else if !ready {
+			waiting = append(waiting, next)
+			continue
+		}
+
+		// The pull request may be marked as a draft or labeled as blocking after it was approved.
+		if reason := repoInfo.BlockingReason(nextInfo.Draft, nextInfo.Title, nextInfo.Labels); reason != "" {
+			commentAsBlocked(ctx, client, reporter, statusLabels, owner, name, nextInfo, reason)
+			continue
+		}
+
+		if reason := checkMergeRequirements(ctx, client, owner, name, nextInfo); reason != "" {
+			commentAsBlocked(ctx, client, reporter, statusLabels, owner, name, nextInfo, reason)
+			continue
+		}
+
+		ok, mergeable := operation.IsMergeable(ctx, client, owner, name, prNum, nextInfo)
 		if !ok {
 			log.Println("info: We treat it as 'mergeable' to avoid miss detection because we could not fetch the pr info,")
 			continue
@@ -320,30 +418,44 @@ func getNextAvailableItem(
 
 		if !mergeable {
 			comment := ":lock: Merge conflict"
-			if ok := operation.AddComment(ctx, issueSvc, owner, name, prNum, comment); !ok {
+			t := &report.Transition{
+				Kind:    report.KindMergeConflict,
+				Message: comment,
+				SHA:     nextInfo.HeadSHA,
+			}
+			if ok := reporter.Report(ctx, client, owner, name, prNum, t); !ok {
 				log.Println("error: could not write the comment about the result of auto branch.")
 			}
 
-			currentLabels := operation.GetLabelsByIssue(ctx, issueSvc, owner, name, prNum)
+			notifier.Notify(&notify.Event{
+				Kind:    notify.EventMergeConflict,
+				Owner:   owner,
+				Name:    name,
+				Number:  prNum,
+				SHA:     nextInfo.HeadSHA,
+				Message: "This cannot be merged because of the merge conflict.",
+			})
+
+			currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, prNum)
 			if currentLabels == nil {
 				continue
 			}
 
-			labels := operation.AddNeedRebaseLabel(currentLabels)
+			labels := operation.AddNeedRebaseLabel(statusLabels, currentLabels)
 			log.Printf("debug: the changed labels: %v\n", labels)
-			_, _, err = issueSvc.ReplaceLabelsForIssue(ctx, owner, name, prNum, labels)
+			err = client.ReplaceLabels(ctx, owner, name, prNum, labels)
 			if err != nil {
 				log.Println("warn: could not change labels of the issue")
 			}
 
 			continue
 		} else {
-			label := operation.GetLabelsByIssue(ctx, issueSvc, owner, name, prNum)
+			label := operation.GetLabelsByIssue(ctx, client, owner, name, prNum)
 			if label == nil {
 				continue
 			}
 
-			if !operation.HasLabelInList(label, operation.LABEL_AWAITING_MERGE) {
+			if !operation.HasLabelInList(label, statusLabels.AwaitingMerge.Name) {
 				continue
 			}
 		}
@@ -351,3 +463,31 @@ func getNextAvailableItem(
 		return next, nextInfo
 	}
 }
+
+// commentAsBlocked explains why the pull request is removed from the queue
+// and sets back its label to the awaiting review.
+func commentAsBlocked(ctx context.Context, client forge.Client, reporter *report.Reporter, statusLabels *setting.StatusLabels, owner, name string, pr *forge.PullRequest, reason string) {
+	number := pr.Number
+	log.Printf("info: #%v is removed from the queue because it %v\n", number, reason)
+
+	comment := fmt.Sprintf(":construction: This has been removed from the approved queue because it %v. "+
+		"Please approve it again after resolving it.", reason)
+	t := &report.Transition{
+		Kind:    report.KindBlocked,
+		Message: comment,
+		SHA:     pr.HeadSHA,
+	}
+	if ok := reporter.Report(ctx, client, owner, name, number, t); !ok {
+		log.Println("error: could not write the comment about the blocked pull request.")
+	}
+
+	currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, number)
+	if currentLabels == nil {
+		return
+	}
+
+	labels := operation.AddAwaitingReviewLabel(statusLabels, currentLabels)
+	if err := client.ReplaceLabels(ctx, owner, name, number, labels); err != nil {
+		log.Println("warn: could not change labels of the issue")
+	}
+}
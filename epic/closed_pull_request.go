@@ -0,0 +1,121 @@
+package epic
+
+import (
+	"context"
+	"log"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/notify"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// CleanUpClosedPullRequest removes the pull request which is closed (or merged by hand) from the queue,
+// and discards its approvals. If it's the active item, this deletes the auto branch and tries the next item
+// instead of waiting for the status of the auto branch.
+// Items which depend on it are also removed if it's closed without being merged.
+func CleanUpClosedPullRequest(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, autoMergeRepo *queue.AutoMergeQRepo, repo forge.Repository, pr *forge.PullRequest) {
+	owner := repo.Owner
+	name := repo.Name
+	prNum := pr.Number
+
+	// The closed pull request will not have transitions anymore.
+	reporter.Forget(owner, name, prNum)
+
+	qHandle := autoMergeRepo.Get(owner, name)
+	if qHandle == nil {
+		log.Println("error: cannot get the queue handle")
+		return
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+
+	mutated := q.RemoveApproval(prNum)
+	if q.RemoveDependencies(prNum) {
+		mutated = true
+	}
+
+	// `RemoveAwaiting()` also removes the active item. So we need to check it at first.
+	active := q.GetActive()
+	if active == nil || active.PullRequest != prNum {
+		if found := q.RemoveAwaiting(prNum); found {
+			log.Printf("info: drop #%v from the queue because it has been closed\n", prNum)
+			mutated = true
+		}
+
+		if !hasDependents(q, owner, name, prNum) {
+			if mutated {
+				q.Save()
+			}
+			return
+		}
+
+		repoInfo := GetRepositoryInfo(ctx, client, owner, name, repo.DefaultBranch)
+		if repoInfo == nil {
+			log.Println("debug: cannot get repositoryInfo")
+			q.Save()
+			return
+		}
+
+		if !pr.Merged {
+			dropDependents(ctx, client, reporter, repoInfo.Labels, owner, name, q, pr)
+		}
+
+		if q.HasActive() {
+			q.Save()
+			return
+		}
+
+		// Items which have waited for the merged pull request may be available now.
+		tryNextItem(ctx, client, notifier, reporter, owner, name, q, repoInfo)
+		return
+	}
+
+	log.Printf("info: give up the auto branch for the active #%v because it has been closed\n", prNum)
+	q.RemoveActive()
+
+	repoInfo := GetRepositoryInfo(ctx, client, owner, name, repo.DefaultBranch)
+	if repoInfo == nil {
+		log.Println("debug: cannot get repositoryInfo")
+		q.Save()
+		return
+	}
+
+	autoBranch := repoInfo.AutoBranchName
+	if err := client.DeleteBranch(ctx, owner, name, autoBranch); err != nil {
+		log.Printf("info: could not delete the auto branch `%v`: %v\n", autoBranch, err)
+	}
+
+	if !pr.Merged {
+		dropDependents(ctx, client, reporter, repoInfo.Labels, owner, name, q, pr)
+	}
+
+	tryNextItem(ctx, client, notifier, reporter, owner, name, q, repoInfo)
+}
+
+// RestoreAwaitingReviewLabel labels `S-awaiting-review` to the reopened pull request
+// because all status labels have been removed when it was closed.
+func RestoreAwaitingReviewLabel(ctx context.Context, client forge.Client, statusLabels *setting.StatusLabels, repo forge.Repository, pr *forge.PullRequest) {
+	owner := repo.Owner
+	name := repo.Name
+	number := pr.Number
+
+	currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, number)
+	if currentLabels == nil {
+		log.Printf("warn: could not get all labels of #%v\n", number)
+		return
+	}
+
+	labels := operation.AddAwaitingReviewLabel(statusLabels, currentLabels)
+	if err := client.ReplaceLabels(ctx, owner, name, number, labels); err != nil {
+		log.Printf("warn: could not label `%v` to #%v\n", statusLabels.AwaitingReview.Name, number)
+		return
+	}
+
+	log.Printf("info: restore `%v` to the reopened #%v\n", statusLabels.AwaitingReview.Name, number)
+}
@@ -0,0 +1,99 @@
+package epic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// CommandRejection is returned if this bot does not accept a command by the reason
+// which the commander should know (e.g. the commander does not have the privilege).
+type CommandRejection struct {
+	// The short reason which is replied to the commander.
+	Reason string
+	// The additional explanation which is replied only if `command.feedback` is "verbose".
+	Detail string
+}
+
+func (r *CommandRejection) Error() string {
+	return "info: the command is rejected: " + r.Reason
+}
+
+func rejectCommand(reason string, detail string) (bool, error) {
+	log.Printf("info: the command is rejected: %v\n", reason)
+	return false, &CommandRejection{
+		Reason: reason,
+		Detail: detail,
+	}
+}
+
+func rejectReviewerOnly(sender string, info *setting.RepositoryInfo) (bool, error) {
+	return rejectCommand(fmt.Sprintf("`%v` is not a reviewer of this repository", sender),
+		"Reviewers are listed in `OWNERS.json`: "+quoteNames(info.Reviewers()))
+}
+
+// ReplyToCommand reacts to the comment of the command by the result of it.
+// If the command is rejected, this also replies the reason.
+// This does nothing for the command which this bot does not handle (`ok` is false and `err` is nil)
+// or which fails by other errors.
+func ReplyToCommand(ctx context.Context, client forge.Client, ev *forge.CommentEvent, info *setting.RepositoryInfo, ok bool, err error) {
+	var rejection *CommandRejection
+	switch {
+	case errors.As(err, &rejection):
+		addReaction(ctx, client, ev, info, forge.ReactionRejected)
+
+		if !info.RepliesToCommand() {
+			return
+		}
+
+		comment := fmt.Sprintf(":no_entry_sign: @%v Your command is rejected: %v.", ev.Sender, rejection.Reason)
+		if info.CommandFeedback == setting.FeedbackVerbose && rejection.Detail != "" {
+			comment += "\n\n" + rejection.Detail
+		}
+		if ok := operation.AddComment(ctx, client, ev.Owner, ev.Name, ev.Number, comment); !ok {
+			log.Println("info: could not create the comment to explain the rejection.")
+		}
+	case ok && err == nil:
+		addReaction(ctx, client, ev, info, forge.ReactionOK)
+	}
+}
+
+// ReplyToMalformedCommand reacts to the comment which is addressed to this bot but cannot be parsed.
+// `syntaxErr` is replied only if `command.feedback` is "verbose".
+func ReplyToMalformedCommand(ctx context.Context, client forge.Client, ev *forge.CommentEvent, info *setting.RepositoryInfo, botName string, syntaxErr error) {
+	addReaction(ctx, client, ev, info, forge.ReactionParseError)
+
+	if !info.RepliesToCommand() {
+		return
+	}
+
+	comment := fmt.Sprintf(":grey_question: @%v I could not understand your command. "+
+		"Please write it in the first line like `@%v r+`, `@%v r=<reviewer>`, `@%v r-` or `r? @<reviewer>`.", ev.Sender, botName, botName, botName)
+	if info.CommandFeedback == setting.FeedbackVerbose && syntaxErr != nil {
+		comment += fmt.Sprintf("\n\nSyntax error: %v", syntaxErr)
+	}
+	if ok := operation.AddComment(ctx, client, ev.Owner, ev.Name, ev.Number, comment); !ok {
+		log.Println("info: could not create the comment to explain the syntax error.")
+	}
+}
+
+func addReaction(ctx context.Context, client forge.Client, ev *forge.CommentEvent, info *setting.RepositoryInfo, reaction string) {
+	if !info.ReactsToCommand() {
+		return
+	}
+
+	err := client.AddReaction(ctx, ev.Owner, ev.Name, ev.Kind, ev.CommentID, reaction)
+	if err == forge.ErrUnsupportedComment {
+		log.Printf("debug: cannot react to the comment %v\n", ev.CommentID)
+		return
+	}
+
+	if err != nil {
+		log.Printf("info: could not add the reaction `%v` to the comment %v: %v\n", reaction, ev.CommentID, err)
+	}
+}
@@ -0,0 +1,317 @@
+package epic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/input"
+	"github.com/voyagegroup/popuko/notify"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+type DependsCommand struct {
+	Owner string
+	Name  string
+
+	Client  forge.Client
+	BotName string
+	Info    *setting.RepositoryInfo
+
+	AutoMergeRepo *queue.AutoMergeQRepo
+	Notifier      *notify.Notifier
+	Reporter      *report.Reporter
+}
+
+// DeclareDependencies records pull requests which must be merged before the pull request
+// in addition to `Depends-on:` in its description.
+func (c *DependsCommand) DeclareDependencies(ctx context.Context, ev *forge.CommentEvent, cmd *input.DependsCommand) (bool, error) {
+	log.Printf("info: Start: declare dependencies by %v\n", ev.CommentID)
+	defer log.Printf("info: End: declare dependencies by %v\n", ev.CommentID)
+
+	if c.BotName != cmd.BotName() {
+		log.Printf("info: this command works only if target user is actual our bot.")
+		return false, nil
+	}
+
+	number := ev.Number
+	if !ev.IsPullRequest {
+		return rejectCommand(fmt.Sprintf("#%v is not a pull request", number), "")
+	}
+
+	sender := ev.Sender
+	if sender != ev.IssueUser && !c.Info.IsReviewer(sender) {
+		return rejectCommand(fmt.Sprintf("`%v` does not have the privilege to declare dependencies of this pull request", sender),
+			"The author of the pull request and reviewers can declare them.")
+	}
+
+	if !c.Info.EnableAutoMerge {
+		return rejectCommand("this repository does not enable Auto-Merging", "Dependencies are respected only by the approved queue.")
+	}
+
+	qHandle := c.AutoMergeRepo.Get(c.Owner, c.Name)
+	if qHandle == nil {
+		log.Println("error: cannot get the queue handle")
+		return false, errors.New("error: cannot get the queue handle")
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+
+	declared := toDependencies(c.Owner, c.Name, number, cmd.Dependencies)
+	q.SetDependencies(number, declared)
+	log.Printf("info: #%v depends on %v by the command\n", number, declared)
+
+	// Update the queued item to show its dependencies in the queue API.
+	if has, item := q.IsAwaiting(number); has {
+		pr, err := c.Client.GetPullRequest(ctx, c.Owner, c.Name, number)
+		if err != nil {
+			log.Println("info: could not fetch the pull request information.")
+			q.Save()
+			return false, err
+		}
+		item.DependsOn = resolveDependencies(c.Owner, c.Name, number, pr.Body, declared)
+
+		if cycle := findCycle(q.Awaiting(), c.Owner, c.Name, number); cycle != nil {
+			dropCycle(ctx, c.Client, c.Reporter, c.Info.Labels, c.Owner, c.Name, q, cycle)
+		}
+	}
+
+	q.Save()
+
+	// The item which has been skipped may be available now.
+	if !q.HasActive() && q.Front() != nil {
+		tryNextItem(ctx, c.Client, c.Notifier, c.Reporter, c.Owner, c.Name, q, c.Info)
+	}
+
+	return true, nil
+}
+
+// toDependencies completes the repository of each reference with owner/name,
+// and removes duplicated ones and the reference to the pull request itself.
+func toDependencies(owner, name string, number int, refs []*input.Reference) []*queue.Dependency {
+	list := make([]*queue.Dependency, 0, len(refs))
+	for _, ref := range refs {
+		d := &queue.Dependency{
+			Owner:  ref.Owner,
+			Name:   ref.Name,
+			Number: ref.Number,
+		}
+		if d.Owner == "" {
+			d.Owner = owner
+			d.Name = name
+		}
+
+		list = appendDependency(list, owner, name, number, d)
+	}
+	return list
+}
+
+func appendDependency(list []*queue.Dependency, owner, name string, number int, d *queue.Dependency) []*queue.Dependency {
+	if d.Is(owner, name, number) {
+		return list
+	}
+
+	for _, elm := range list {
+		if elm.Is(d.Owner, d.Name, d.Number) {
+			return list
+		}
+	}
+	return append(list, d)
+}
+
+// resolveDependencies returns dependencies of #number which are declared by `Depends-on:` in `body` and by the command.
+func resolveDependencies(owner, name string, number int, body string, declared []*queue.Dependency) []*queue.Dependency {
+	list := toDependencies(owner, name, number, input.ParseDependencies(body))
+	for _, d := range declared {
+		list = appendDependency(list, owner, name, number, d)
+	}
+	return list
+}
+
+// checkDependencies returns true if all dependencies have been merged.
+// `closed` is the dependency which has been closed without being merged.
+func checkDependencies(ctx context.Context, client forge.Client, item *queue.AutoMergeQueueItem) (ready bool, closed *queue.Dependency) {
+	ready = true
+	for _, d := range item.DependsOn {
+		pr, err := client.GetPullRequest(ctx, d.Owner, d.Name, d.Number)
+		if err != nil {
+			log.Printf("info: could not fetch %v which #%v depends on: %v\n", d, item.PullRequest, err)
+			ready = false
+			continue
+		}
+
+		if pr.Merged {
+			continue
+		}
+
+		if pr.State != "open" {
+			return false, d
+		}
+
+		log.Printf("info: #%v waits for %v to be merged\n", item.PullRequest, d)
+		ready = false
+	}
+	return ready, nil
+}
+
+// commentAsDependencyClosed tells the author that the pull request has been removed from the queue
+// because its dependency has been closed without being merged, and sets back its label to the awaiting review.
+func commentAsDependencyClosed(ctx context.Context, client forge.Client, reporter *report.Reporter, statusLabels *setting.StatusLabels, owner, name string, pr *forge.PullRequest, d *queue.Dependency) {
+	number := pr.Number
+	log.Printf("info: #%v is removed from the queue because %v has been closed\n", number, d)
+
+	comment := fmt.Sprintf(":construction: @%v This has been removed from the approved queue because it depends on %v "+
+		"which has been closed without being merged. Please update its dependencies and approve it again.", pr.User, d)
+	t := &report.Transition{
+		Kind:    report.KindBlocked,
+		Message: comment,
+		SHA:     pr.HeadSHA,
+	}
+	if ok := reporter.Report(ctx, client, owner, name, number, t); !ok {
+		log.Println("error: could not write the comment about the closed dependency.")
+	}
+
+	currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, number)
+	if currentLabels == nil {
+		return
+	}
+
+	labels := operation.AddAwaitingReviewLabel(statusLabels, currentLabels)
+	if err := client.ReplaceLabels(ctx, owner, name, number, labels); err != nil {
+		log.Println("warn: could not change labels of the issue")
+	}
+}
+
+// hasDependents returns true if some awaiting items depend on #number in owner/name.
+func hasDependents(q *queue.AutoMergeQueue, owner, name string, number int) bool {
+	return len(dependentsOf(q, owner, name, number)) > 0
+}
+
+func dependentsOf(q *queue.AutoMergeQueue, owner, name string, number int) []*queue.AutoMergeQueueItem {
+	list := make([]*queue.AutoMergeQueueItem, 0)
+	for _, item := range q.Awaiting() {
+		for _, d := range item.DependsOn {
+			if d.Is(owner, name, number) {
+				list = append(list, item)
+				break
+			}
+		}
+	}
+	return list
+}
+
+// dropDependents removes awaiting items which depend on `closed` from the queue, and tells their authors.
+func dropDependents(ctx context.Context, client forge.Client, reporter *report.Reporter, statusLabels *setting.StatusLabels, owner, name string, q *queue.AutoMergeQueue, closed *forge.PullRequest) {
+	d := &queue.Dependency{
+		Owner:  owner,
+		Name:   name,
+		Number: closed.Number,
+	}
+
+	for _, item := range dependentsOf(q, owner, name, closed.Number) {
+		q.RemoveAwaiting(item.PullRequest)
+
+		pr, err := client.GetPullRequest(ctx, owner, name, item.PullRequest)
+		if err != nil {
+			log.Printf("info: could not fetch #%v: %v\n", item.PullRequest, err)
+			continue
+		}
+
+		commentAsDependencyClosed(ctx, client, reporter, statusLabels, owner, name, pr, d)
+	}
+}
+
+// findCycle returns pull requests on the cycle of dependencies which #number is on
+// in the order of dependencies (e.g. `[1, 2]` for #1 -> #2 -> #1), or nil if there is no cycle.
+// Only dependencies on pull requests of owner/name in `items` are followed.
+func findCycle(items []*queue.AutoMergeQueueItem, owner, name string, number int) []int {
+	byNumber := make(map[int]*queue.AutoMergeQueueItem, len(items))
+	for _, item := range items {
+		byNumber[item.PullRequest] = item
+	}
+
+	visited := make(map[int]bool)
+	var visit func(path []int) []int
+	visit = func(path []int) []int {
+		item, ok := byNumber[path[len(path)-1]]
+		if !ok {
+			return nil
+		}
+
+		for _, d := range item.DependsOn {
+			if d.Owner != owner || d.Name != name {
+				continue
+			}
+
+			if d.Number == number {
+				return path
+			}
+
+			if visited[d.Number] {
+				continue
+			}
+			visited[d.Number] = true
+
+			if cycle := visit(append(path, d.Number)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+
+	return visit([]int{number})
+}
+
+func formatCycle(cycle []int) string {
+	refs := make([]string, 0, len(cycle)+1)
+	for _, number := range cycle {
+		refs = append(refs, fmt.Sprintf("#%v", number))
+	}
+	refs = append(refs, fmt.Sprintf("#%v", cycle[0]))
+	return strings.Join(refs, " -> ")
+}
+
+// dropCycle removes pull requests on `cycle` from the queue, and tells their authors.
+// None of them can be merged before others are merged.
+func dropCycle(ctx context.Context, client forge.Client, reporter *report.Reporter, statusLabels *setting.StatusLabels, owner, name string, q *queue.AutoMergeQueue, cycle []int) {
+	reason := "has circular dependencies: " + formatCycle(cycle)
+	for _, number := range cycle {
+		q.RemoveAwaiting(number)
+
+		pr, err := client.GetPullRequest(ctx, owner, name, number)
+		if err != nil {
+			log.Printf("info: could not fetch #%v: %v\n", number, err)
+			continue
+		}
+
+		commentAsBlocked(ctx, client, reporter, statusLabels, owner, name, pr, reason)
+	}
+}
+
+// excludeItems returns items in `list` except pull requests in `numbers`.
+func excludeItems(list []*queue.AutoMergeQueueItem, numbers []int) []*queue.AutoMergeQueueItem {
+	rest := make([]*queue.AutoMergeQueueItem, 0, len(list))
+	for _, item := range list {
+		excluded := false
+		for _, number := range numbers {
+			if item.PullRequest == number {
+				excluded = true
+				break
+			}
+		}
+
+		if !excluded {
+			rest = append(rest, item)
+		}
+	}
+	return rest
+}
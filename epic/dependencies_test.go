@@ -0,0 +1,91 @@
+package epic
+
+import (
+	"testing"
+
+	"github.com/voyagegroup/popuko/queue"
+)
+
+func TestResolveDependencies(t *testing.T) {
+	type Testcase struct {
+		body     string
+		declared []*queue.Dependency
+		expected []string
+	}
+	list := []Testcase{
+		Testcase{
+			body:     "",
+			declared: nil,
+			expected: []string{},
+		},
+		Testcase{
+			body:     "Depends-on: #1, other/repo#2",
+			declared: nil,
+			expected: []string{"foo/bar#1", "other/repo#2"},
+		},
+		Testcase{
+			// The reference to itself and duplicated ones are ignored.
+			body: "Depends-on: #1 #3 foo/bar#1",
+			declared: []*queue.Dependency{
+				&queue.Dependency{Owner: "foo", Name: "bar", Number: 1},
+				&queue.Dependency{Owner: "foo", Name: "bar", Number: 4},
+			},
+			expected: []string{"foo/bar#1", "foo/bar#4"},
+		},
+	}
+
+	for i, test := range list {
+		actual := resolveDependencies("foo", "bar", 3, test.body, test.declared)
+		if len(actual) != len(test.expected) {
+			t.Errorf("#%v should be %v but %v", i, test.expected, actual)
+			continue
+		}
+
+		for j, d := range actual {
+			if d.String() != test.expected[j] {
+				t.Errorf("#%v should be %v but %v", i, test.expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	dep := func(owner, name string, number int) *queue.Dependency {
+		return &queue.Dependency{Owner: owner, Name: name, Number: number}
+	}
+
+	items := []*queue.AutoMergeQueueItem{
+		&queue.AutoMergeQueueItem{PullRequest: 1, DependsOn: []*queue.Dependency{dep("foo", "bar", 2)}},
+		&queue.AutoMergeQueueItem{PullRequest: 2, DependsOn: []*queue.Dependency{dep("foo", "bar", 5), dep("foo", "bar", 3)}},
+		&queue.AutoMergeQueueItem{PullRequest: 3, DependsOn: []*queue.Dependency{dep("foo", "bar", 1)}},
+		&queue.AutoMergeQueueItem{PullRequest: 4, DependsOn: []*queue.Dependency{dep("foo", "bar", 1)}},
+		&queue.AutoMergeQueueItem{PullRequest: 5, DependsOn: []*queue.Dependency{dep("other", "repo", 6)}},
+		// The same number in another repository is a different pull request.
+		&queue.AutoMergeQueueItem{PullRequest: 6, DependsOn: []*queue.Dependency{dep("other", "repo", 5)}},
+	}
+
+	type Testcase struct {
+		number   int
+		expected string
+	}
+	list := []Testcase{
+		Testcase{1, "#1 -> #2 -> #3 -> #1"},
+		Testcase{3, "#3 -> #1 -> #2 -> #3"},
+		// #4 waits for the cycle, but it is not on the cycle.
+		Testcase{4, ""},
+		Testcase{5, ""},
+		Testcase{6, ""},
+	}
+
+	for _, test := range list {
+		actual := ""
+		if cycle := findCycle(items, "foo", "bar", test.number); cycle != nil {
+			actual = formatCycle(cycle)
+		}
+
+		if actual != test.expected {
+			t.Errorf("#%v: the cycle should be `%v` but `%v`", test.number, test.expected, actual)
+		}
+	}
+}
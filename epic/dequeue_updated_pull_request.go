@@ -0,0 +1,135 @@
+package epic
+
+import (
+	"context"
+	"log"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/notify"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+)
+
+// DequeueUpdatedPullRequest removes the pull request from the queue as soon as new commits are pushed to it
+// instead of waiting until the queue reaches it. Partial approvals for the old head are also discarded.
+// If the pull request is the active item, this gives up its auto branch and tries the next item.
+// If the new head has the same changes as the approved one (e.g. a rebase), this keeps its approval instead.
+func DequeueUpdatedPullRequest(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, autoMergeRepo *queue.AutoMergeQRepo, repo forge.Repository, pr *forge.PullRequest) {
+	owner := repo.Owner
+	name := repo.Name
+	prNum := pr.Number
+	head := pr.HeadSHA
+
+	qHandle := autoMergeRepo.Get(owner, name)
+	if qHandle == nil {
+		log.Println("error: cannot get the queue handle")
+		return
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+
+	if accepted := staleHead(q, prNum, head); accepted != "" && isSamePatch(ctx, client, owner, name, pr.BaseRef, accepted, head) {
+		if keepRebasedPullRequest(ctx, client, reporter, repo, q, pr, accepted) {
+			return
+		}
+	}
+
+	mutated := false
+	if approval := q.GetApproval(prNum); approval != nil && approval.PrHead != head {
+		log.Printf("info: discard approvals for the old head of #%v\n", prNum)
+		q.RemoveApproval(prNum)
+		mutated = true
+	}
+
+	var accepted string
+	if has, item := q.IsAwaiting(prNum); has && item.PrHead != head {
+		accepted = item.PrHead
+		q.RemoveAwaiting(prNum)
+	}
+
+	isActive := false
+	if active := q.GetActive(); active != nil && active.PullRequest == prNum && active.PrHead != head {
+		log.Printf("info: give up the auto branch for the active #%v because its head is changed\n", prNum)
+		accepted = active.PrHead
+		q.RemoveActive()
+		isActive = true
+	}
+
+	if accepted == "" {
+		if mutated {
+			q.Save()
+		}
+		return
+	}
+
+	repoInfo := GetRepositoryInfo(ctx, client, owner, name, repo.DefaultBranch)
+	if repoInfo == nil {
+		log.Println("debug: cannot get repositoryInfo")
+		q.Save()
+		return
+	}
+
+	log.Printf("info: drop #%v from the queue because its head is changed from %v to %v\n", prNum, accepted, head)
+	operation.CommentHeadIsDifferentFromAccepted(ctx, client, reporter, repoInfo.Labels, owner, name, prNum)
+	notifier.Notify(&notify.Event{
+		Kind:    notify.EventHeadChanged,
+		Owner:   owner,
+		Name:    name,
+		Number:  prNum,
+		SHA:     head,
+		Message: "The head has been changed from " + accepted + " after it was approved.",
+	})
+
+	if !isActive {
+		q.Save()
+		return
+	}
+
+	tryNextItem(ctx, client, notifier, reporter, owner, name, q, repoInfo)
+}
+
+// staleHead returns the approved head of the pull request if it's different from `head`.
+func staleHead(q *queue.AutoMergeQueue, number int, head string) string {
+	if active := q.GetActive(); active != nil && active.PullRequest == number && active.PrHead != head {
+		return active.PrHead
+	}
+
+	if has, item := q.IsAwaiting(number); has && item.PrHead != head {
+		return item.PrHead
+	}
+
+	if approval := q.GetApproval(number); approval != nil && approval.PrHead != head {
+		return approval.PrHead
+	}
+
+	return ""
+}
+
+// keepRebasedPullRequest keeps the approval and the position of the pull request whose new head
+// has the same changes as `accepted`. If it's the active item, this rebuilds the auto branch.
+// This returns false if we cannot keep it.
+func keepRebasedPullRequest(ctx context.Context, client forge.Client, reporter *report.Reporter, repo forge.Repository, q *queue.AutoMergeQueue, pr *forge.PullRequest, accepted string) bool {
+	owner := repo.Owner
+	name := repo.Name
+	prNum := pr.Number
+
+	if active := q.GetActive(); active != nil && active.PullRequest == prNum && active.PrHead == accepted {
+		repoInfo := GetRepositoryInfo(ctx, client, owner, name, repo.DefaultBranch)
+		if repoInfo == nil {
+			log.Println("debug: cannot get repositoryInfo")
+			return false
+		}
+
+		if !rebuildActiveItem(ctx, client, reporter, owner, name, repoInfo, active, pr) {
+			return false
+		}
+	}
+
+	carryOverApproval(ctx, client, reporter, owner, name, q, prNum, accepted, pr.HeadSHA)
+	q.Save()
+	return true
+}
@@ -4,154 +4,391 @@ import (
 	"context"
 	"log"
 	"sync"
+	"time"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
 
+	"github.com/voyagegroup/popuko/notify"
 	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
+	"github.com/voyagegroup/popuko/store"
 )
 
-func DetectUnmergeablePR(ctx context.Context, client *github.Client, ev *github.PushEvent) {
-	owner := *ev.Repo.Owner.Name
+// UnmergeableDetector patrols pull requests which become unmergeable by a push to the default branch.
+//
+// The result of the check is persisted per pull request with the head of the default branch,
+// so we don't check the same pair again (e.g. a redelivered push).
+// For a large repository, we check the first `burst` pull requests at once
+// and the rest one by one every `interval` in background.
+type UnmergeableDetector struct {
+	client   forge.Client
+	notifier *notify.Notifier
+	reporter *report.Reporter
+	cache    *store.FileStore
+	burst    int
+	interval time.Duration
+
+	// Background checks are derived from this, and they stop on Shutdown.
+	ctx  context.Context
+	stop context.CancelFunc
+
+	mux sync.Mutex
+	// `owner/name` => the function to cancel the background check for the older push.
+	running map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewUnmergeableDetector(client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, cache *store.FileStore, burst int, interval time.Duration) *UnmergeableDetector {
+	ctx, stop := context.WithCancel(context.Background())
+	return &UnmergeableDetector{
+		client:   client,
+		notifier: notifier,
+		reporter: reporter,
+		cache:    cache,
+		burst:    burst,
+		interval: interval,
+		ctx:      ctx,
+		stop:     stop,
+		running:  make(map[string]context.CancelFunc),
+	}
+}
+
+// mergeabilityCache is the document saved in the cache per repository.
+type mergeabilityCache struct {
+	// The number of the pull request => the last result.
+	PullRequests map[int]*mergeabilityEntry `json:"pull_requests"`
+}
+
+type mergeabilityEntry struct {
+	// The head of the pull request which has been checked.
+	HeadSHA string `json:"head_sha"`
+	// The head of the default branch which the pull request has been checked with.
+	BaseSHA   string `json:"base_sha"`
+	Mergeable bool   `json:"mergeable"`
+}
+
+func (d *UnmergeableDetector) DetectUnmergeablePR(ctx context.Context, ev *forge.PushEvent) {
+	client := d.client
+
+	owner := ev.Owner
 	log.Printf("debug: repository owner is %v\n", owner)
-	repo := *ev.Repo.Name
+	repo := ev.Name
 	log.Printf("debug: repository name is %v\n", repo)
 
 	fullRepositoryName := owner + "/" + repo
-	defaultBranch := ev.Repo.GetDefaultBranch()
+	defaultBranch := ev.DefaultBranch
 	if defaultBranch == "" {
 		// I seem we may not require this fallback path. But GitHub API example is not reliable.
 		log.Printf("debug: could not get default branch name from pushed event for %v\n", fullRepositoryName)
-		repoInfo, _, err := client.Repositories.Get(ctx, owner, repo)
+		branch, err := client.GetDefaultBranch(ctx, owner, repo)
 		if err != nil {
 			log.Printf("warn: could not fetch the repository infor for %v by %v\n", fullRepositoryName, err)
 			return
 		}
 
-		defaultBranch = repoInfo.GetDefaultBranch()
+		defaultBranch = branch
 	}
 	log.Printf("info: the default branch name is `%v` for %v\n", defaultBranch, fullRepositoryName)
 
 	// At this moment, we only care a pull request which are looking master branch.
-	if *ev.Ref != "refs/heads/"+defaultBranch {
-		log.Printf("info: pushed branch is not related to me: %v\n", *ev.Ref)
+	if ev.Ref != "refs/heads/"+defaultBranch {
+		log.Printf("info: pushed branch is not related to me: %v\n", ev.Ref)
+		return
+	}
+
+	repoInfo := GetRepositoryInfo(ctx, client, owner, repo, defaultBranch)
+	if repoInfo == nil {
+		log.Println("debug: cannot get repositoryInfo")
 		return
 	}
 
-	prSvc := client.PullRequests
+	// The background check for the older push is meaningless now.
+	d.cancel(fullRepositoryName)
 
-	prList, _, err := prSvc.List(ctx, owner, repo, &github.PullRequestListOptions{
-		State: "open",
-	})
+	prList, err := client.ListOpenPullRequests(ctx, owner, repo, defaultBranch)
 	if err != nil {
 		log.Println("warn: could not fetch opened pull requests")
 		return
 	}
 
-	compare := *ev.Compare
+	targets := d.filterTargets(owner, repo, defaultBranch, repoInfo.Labels, ev.After, prList)
+	log.Printf("info: check %v of %v open pull requests in %v\n", len(targets), len(prList), fullRepositoryName)
+
+	compare := ev.Compare
 	comment := ":umbrella: The latest upstream change (presumably [these](" + compare + ")) made this pull request unmergeable. Please resolve the merge conflicts."
 
+	newInfo := func(pr *forge.PullRequest) *markUnmergeableInfo {
+		return &markUnmergeableInfo{
+			client:            client,
+			notifier:          d.notifier,
+			reporter:          d.reporter,
+			RepoOwner:         owner,
+			Repo:              repo,
+			DefaultBranchName: defaultBranch,
+			PullRequest:       pr,
+			Labels:            repoInfo.Labels,
+			Comment:           comment,
+		}
+	}
+
+	burst := targets
+	var rest []*forge.PullRequest
+	if d.burst > 0 && len(targets) > d.burst {
+		burst, rest = targets[:d.burst], targets[d.burst:]
+	}
+
 	// Restrict the number of Goroutine which checks unmergeables
 	// to avoid the API limits at a moment.
 	const maxConcurrency int = 8
 	semaphore := make(chan int, maxConcurrency)
 
 	wg := &sync.WaitGroup{}
-	for _, item := range prList {
+	for _, item := range burst {
 		wg.Add(1)
 
-		go markUnmergeable(ctx, wg, &markUnmergeableInfo{
-			issueSvc:          client.Issues,
-			prSvc:             prSvc,
-			RepoOwner:         owner,
-			Repo:              repo,
-			DefaultBranchName: defaultBranch,
-			Number:            *item.Number,
-			Comment:           comment,
-			semaphore:         semaphore,
-		})
+		info := newInfo(item)
+		go func() {
+			defer wg.Done()
+
+			semaphore <- 0 // wait until the internal buffer takes a space.
+			defer func() {
+				<-semaphore // release the space of the internal buffer
+			}()
+
+			d.check(ctx, info, ev.After)
+		}()
 	}
 	wg.Wait()
+
+	if len(rest) == 0 {
+		return
+	}
+
+	log.Printf("info: check the rest %v pull requests in %v every %v\n", len(rest), fullRepositoryName, d.interval)
+	d.mux.Lock()
+	bgCtx, cancel := context.WithCancel(d.ctx)
+	d.running[fullRepositoryName] = cancel
+	d.mux.Unlock()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer cancel()
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for _, item := range rest {
+			select {
+			case <-bgCtx.Done():
+				log.Printf("info: stop checking the older push for %v\n", fullRepositoryName)
+				return
+			case <-ticker.C:
+			}
+
+			d.check(bgCtx, newInfo(item), ev.After)
+		}
+	}()
 }
 
-type markUnmergeableInfo struct {
-	issueSvc          *github.IssuesService
-	prSvc             *github.PullRequestsService
-	RepoOwner         string
-	Repo              string
-	DefaultBranchName string
-	Number            int
-	Comment           string
-	semaphore         chan int
+// Wait blocks until all background checks finish.
+func (d *UnmergeableDetector) Wait() {
+	d.wg.Wait()
 }
 
-func markUnmergeable(ctx context.Context, wg *sync.WaitGroup, info *markUnmergeableInfo) {
-	info.semaphore <- 0 // wait until the internal buffer takes a space.
+// Shutdown cancels all background checks and waits for the checks in progress.
+// The rest are checked by the next push.
+func (d *UnmergeableDetector) Shutdown() {
+	d.mux.Lock()
+	d.stop()
+	for repo, cancel := range d.running {
+		cancel()
+		delete(d.running, repo)
+	}
+	d.mux.Unlock()
 
-	var err error
-	defer wg.Done()
-	defer func() {
-		<-info.semaphore // release the space of the internal buffer
+	d.wg.Wait()
+}
 
-		if err != nil {
-			log.Printf("error: %v\n", err)
+func (d *UnmergeableDetector) cancel(repo string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if cancel, ok := d.running[repo]; ok {
+		cancel()
+		delete(d.running, repo)
+	}
+}
+
+// filterTargets returns pull requests which we need to check,
+// and drops cache entries for pull requests which are not open anymore.
+func (d *UnmergeableDetector) filterTargets(owner, name, defaultBranch string, statusLabels *setting.StatusLabels, baseSHA string, list []*forge.PullRequest) []*forge.PullRequest {
+	h := d.cache.Get(owner, name)
+	if h == nil {
+		log.Printf("warn: cannot get the mergeability cache of %v/%v\n", owner, name)
+		return list
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	var cache mergeabilityCache
+	h.Load(&cache)
+
+	open := make(map[int]*mergeabilityEntry)
+	targets := make([]*forge.PullRequest, 0, len(list))
+	for _, pr := range list {
+		if entry, ok := cache.PullRequests[pr.Number]; ok {
+			open[pr.Number] = entry
+			if baseSHA != "" && entry.BaseSHA == baseSHA && entry.HeadSHA == pr.HeadSHA {
+				log.Printf("debug: #%v has been checked with %v\n", pr.Number, baseSHA)
+				continue
+			}
 		}
-	}()
 
-	issueSvc := info.issueSvc
+		if !operation.IsRelatedToDefaultBranch(pr, owner, defaultBranch) {
+			log.Printf("info: #%v is not related to `%v` branch", pr.Number, defaultBranch)
+			continue
+		}
 
-	repoOwner := info.RepoOwner
-	log.Printf("debug: repository owner is %v\n", repoOwner)
-	repo := info.Repo
-	log.Printf("debug: repository name is %v\n", repo)
-	number := info.Number
-	log.Printf("debug: pull request number is %v\n", number)
-	defaultBranchName := info.DefaultBranchName
-	log.Printf("debug: the default branch name is %v\n", defaultBranchName)
+		// We don't have to warn to a pull request which have been marked as unmergeable.
+		if operation.HasLabelInList(pr.Labels, statusLabels.NeedsRebase.Name) {
+			log.Printf("info: #%v has marked as 'should rebase on the latest master'.\n", pr.Number)
+			continue
+		}
 
-	pr, _, err := info.prSvc.Get(ctx, repoOwner, repo, number)
-	if err != nil || pr == nil {
-		log.Println("info: could not get the info for pull request")
-		log.Printf("debug: %v\n", err)
-		return
+		targets = append(targets, pr)
 	}
 
-	if !operation.IsRelatedToDefaultBranch(pr, repoOwner, defaultBranchName) {
-		log.Printf("info: #%v is not related to `%v` branch", number, defaultBranchName)
-		return
+	if len(open) != len(cache.PullRequests) {
+		cache.PullRequests = open
+		h.Save(&cache)
 	}
 
-	currentLabels := operation.GetLabelsByIssue(ctx, issueSvc, repoOwner, repo, number)
-	if currentLabels == nil {
+	return targets
+}
+
+func (d *UnmergeableDetector) check(ctx context.Context, info *markUnmergeableInfo, baseSHA string) {
+	ok, mergeable := markUnmergeable(ctx, info)
+	if !ok || baseSHA == "" {
 		return
 	}
 
-	// We don't have to warn to a pull request which have been marked as unmergeable.
-	if operation.HasLabelInList(currentLabels, operation.LABEL_NEEDS_REBASE) {
-		log.Printf("info: #%v has marked as 'should rebase on the latest master'.\n", number)
+	h := d.cache.Get(info.RepoOwner, info.Repo)
+	if h == nil {
 		return
 	}
 
-	ok, mergeable := operation.IsMergeable(ctx, info.prSvc, repoOwner, repo, number, pr)
+	h.Lock()
+	defer h.Unlock()
+
+	var cache mergeabilityCache
+	h.Load(&cache)
+	if cache.PullRequests == nil {
+		cache.PullRequests = make(map[int]*mergeabilityEntry)
+	}
+
+	cache.PullRequests[info.PullRequest.Number] = &mergeabilityEntry{
+		HeadSHA:   info.PullRequest.HeadSHA,
+		BaseSHA:   baseSHA,
+		Mergeable: mergeable,
+	}
+	h.Save(&cache)
+}
+
+type markUnmergeableInfo struct {
+	client            forge.Client
+	notifier          *notify.Notifier
+	reporter          *report.Reporter
+	RepoOwner         string
+	Repo              string
+	DefaultBranchName string
+	// The pull request from the list. This may not have `Mergeable`.
+	PullRequest *forge.PullRequest
+	Labels      *setting.StatusLabels
+	Comment     string
+}
+
+// markUnmergeable returns whether the pull request is mergeable.
+// `ok` is false if we could not decide it.
+func markUnmergeable(ctx context.Context, info *markUnmergeableInfo) (ok bool, mergeable bool) {
+	client := info.client
+
+	repoOwner := info.RepoOwner
+	repo := info.Repo
+	pr := info.PullRequest
+	number := pr.Number
+	log.Printf("debug: pull request number is %v\n", number)
+
+	// The list of pull requests does not contain `mergeable` on GitHub.
+	// Fetching the pull request also triggers to compute it.
+	if pr.Mergeable == nil {
+		fetched, err := client.GetPullRequest(ctx, repoOwner, repo, number)
+		if err != nil || fetched == nil {
+			log.Println("info: could not get the info for pull request")
+			log.Printf("debug: %v\n", err)
+			return false, false
+		}
+		pr = fetched
+	}
+
+	ok, mergeable = operation.IsMergeable(ctx, client, repoOwner, repo, number, pr)
 	if !ok {
 		log.Printf("info: We treat #%v as 'mergeable' to avoid miss detection because we could not fetch the pr info,\n", number)
-		return
+		return false, false
 	}
 
 	if mergeable {
 		log.Printf("info: do not have to mark %v as 'unmergeable'\n", number)
-		return
+		return true, true
 	}
 
-	if ok := operation.AddComment(ctx, issueSvc, repoOwner, repo, number, info.Comment); !ok {
+	currentLabels := operation.GetLabelsByIssue(ctx, client, repoOwner, repo, number)
+	if currentLabels == nil {
+		return false, false
+	}
+
+	// The label might be added after listing pull requests.
+	if operation.HasLabelInList(currentLabels, info.Labels.NeedsRebase.Name) {
+		log.Printf("info: #%v has marked as 'should rebase on the latest master'.\n", number)
+		return true, false
+	}
+
+	t := &report.Transition{
+		Kind:    report.KindMergeConflict,
+		Message: info.Comment,
+		SHA:     pr.HeadSHA,
+	}
+	if ok := info.reporter.Report(ctx, client, repoOwner, repo, number, t); !ok {
 		log.Printf("info: could not create the comment about unmergeables to #%v\n", number)
-		return
+		return false, false
 	}
 
-	labels := operation.AddNeedRebaseLabel(currentLabels)
-	log.Printf("debug: the changed labels: %v of #%v\n", labels, number)
-	_, _, err = issueSvc.ReplaceLabelsForIssue(ctx, repoOwner, repo, number, labels)
-	if err != nil {
+	info.notifier.Notify(&notify.Event{
+		Kind:    notify.EventMergeConflict,
+		Owner:   repoOwner,
+		Name:    repo,
+		Number:  number,
+		SHA:     pr.HeadSHA,
+		Message: "The latest upstream change made this unmergeable.",
+	})
+
+	// This may run in background outside the event queue of the repository.
+	// Change only status labels so that we don't overwrite labels changed by other events meanwhile.
+	for _, label := range currentLabels {
+		if !info.Labels.IsStatusLabel(label) {
+			continue
+		}
+		if err := client.RemoveLabel(ctx, repoOwner, repo, number, label); err != nil {
+			log.Printf("info: could not remove `%v` from #%v: %v\n", label, number, err)
+		}
+	}
+
+	log.Printf("debug: add `%v` to #%v\n", info.Labels.NeedsRebase.Name, number)
+	if err := client.AddLabels(ctx, repoOwner, repo, number, []string{info.Labels.NeedsRebase.Name}); err != nil {
 		log.Printf("could not change labels of #%v\n", number)
-		return
 	}
+
+	return true, false
 }
@@ -3,17 +3,16 @@ package epic
 import (
 	"context"
 	"encoding/json"
-	"io/ioutil"
 	"log"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
 	"github.com/voyagegroup/popuko/setting"
 )
 
-func GetRepositoryInfo(ctx context.Context, repoSvc *github.RepositoriesService, owner, name, defaultBranchName string) *setting.RepositoryInfo {
+func GetRepositoryInfo(ctx context.Context, client forge.Client, owner, name, defaultBranchName string) *setting.RepositoryInfo {
 	var repoinfo *setting.RepositoryInfo
 	log.Println("info: Use `OWNERS` file.")
-	ok, owners := fetchOwnersFile(ctx, repoSvc, owner, name, defaultBranchName)
+	ok, owners := fetchOwnersFile(ctx, client, owner, name, defaultBranchName)
 	if !ok {
 		log.Println("error: could not handle OWNERS file.")
 		return nil
@@ -25,38 +24,31 @@ func GetRepositoryInfo(ctx context.Context, repoSvc *github.RepositoriesService,
 		return nil
 	}
 
+	repoinfo.ResolveTeams(teamResolver(ctx, client))
+
 	return repoinfo
 }
 
-func fetchOwnersFile(ctx context.Context, svc *github.RepositoriesService, owner string, reponame string, defaultBranchName string) (bool, *setting.OwnersFile) {
+func fetchOwnersFile(ctx context.Context, client forge.Client, owner string, reponame string, defaultBranchName string) (bool, *setting.OwnersFile) {
 	fullRepositoryName := owner + "/" + reponame
 	if defaultBranchName == "" {
 		log.Printf("debug: could not get default branch name from the event for %v\n", fullRepositoryName)
-		repoInfo, _, err := svc.Get(ctx, owner, reponame)
+		branch, err := client.GetDefaultBranch(ctx, owner, reponame)
 		if err != nil {
 			log.Printf("warn: could not fetch the repository infor for %v by %v\n", fullRepositoryName, err)
 			return false, nil
 		}
 
-		defaultBranchName = repoInfo.GetDefaultBranch()
+		defaultBranchName = branch
 	}
 	log.Printf("info: the default branch name is `%v` for %v\n", defaultBranchName, fullRepositoryName)
 
-	file, err := svc.DownloadContents(ctx, owner, reponame, "OWNERS.json", &github.RepositoryContentGetOptions{
-		// We always use the file in master which we regard as accepted to the project.
-		Ref: defaultBranchName,
-	})
+	// We always use the file in master which we regard as accepted to the project.
+	raw, err := client.GetFile(ctx, owner, reponame, "OWNERS.json", defaultBranchName)
 	if err != nil {
 		log.Printf("error: could not fetch `OWNERS.json`: %v\n", err)
 		return false, nil
 	}
-
-	raw, err := ioutil.ReadAll(file)
-	defer file.Close()
-	if err != nil {
-		log.Printf("error: could not read `OWNERS.json`: %v\n", err)
-		return false, nil
-	}
 	log.Printf("debug: OWNERS.json:\n%v\n", string(raw))
 
 	var decoded setting.OwnersFile
@@ -0,0 +1,83 @@
+package epic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// IsQueued returns true if #number is awaiting or being tested in the queue.
+func IsQueued(autoMergeRepo *queue.AutoMergeQRepo, owner, name string, number int) bool {
+	qHandle := autoMergeRepo.Get(owner, name)
+	if qHandle == nil {
+		log.Println("error: cannot get the queue handle")
+		return false
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+	if active := q.GetActive(); active != nil && active.PullRequest == number {
+		return true
+	}
+
+	ok, _ := q.IsAwaiting(number)
+	return ok
+}
+
+// ReplyToLabelChange reverts the merge label which is added (or removed if `added` is false)
+// by a human if the command by it is rejected, and explains the reason.
+// A label cannot have a reaction, so this comments if `command.feedback` is not "none".
+func ReplyToLabelChange(ctx context.Context, client forge.Client, ev *forge.CommentEvent, info *setting.RepositoryInfo, added bool, err error) {
+	var rejection *CommandRejection
+	if !errors.As(err, &rejection) {
+		return
+	}
+
+	owner := ev.Owner
+	name := ev.Name
+	number := ev.Number
+	merge := info.Labels.AwaitingMerge.Name
+
+	currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, number)
+	if currentLabels != nil {
+		var labels []string
+		if added {
+			labels = make([]string, 0, len(currentLabels))
+			for _, l := range currentLabels {
+				if l != merge {
+					labels = append(labels, l)
+				}
+			}
+		} else {
+			labels = operation.AddAwaitingMergeLabel(info.Labels, currentLabels)
+		}
+
+		if err := client.ReplaceLabels(ctx, owner, name, number, labels); err != nil {
+			log.Printf("info: could not revert the label `%v` of #%v: %v\n", merge, number, err)
+		}
+	}
+
+	if !info.ReactsToCommand() {
+		return
+	}
+
+	verb := "added"
+	if !added {
+		verb = "removed"
+	}
+	comment := fmt.Sprintf(":no_entry_sign: @%v The label `%v` which you %v has been reverted: %v.", ev.Sender, merge, verb, rejection.Reason)
+	if info.CommandFeedback == setting.FeedbackVerbose && rejection.Detail != "" {
+		comment += "\n\n" + rejection.Detail
+	}
+	if ok := operation.AddComment(ctx, client, owner, name, number, comment); !ok {
+		log.Println("info: could not create the comment to explain the reverted label.")
+	}
+}
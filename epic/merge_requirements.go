@@ -0,0 +1,135 @@
+package epic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/operation"
+)
+
+// checkMergeRequirements returns why the forge will refuse to merge the pull request
+// by its reviews or the protection of its base branch. This returns the empty string if we can try it.
+// We check them before building the auto branch to keep CI free for pull requests which can be merged.
+// Only changes requested by collaborators who can write to the repository block it as the forge does.
+// Anyone can submit a review on a public repository.
+func checkMergeRequirements(ctx context.Context, client forge.Client, owner, name string, pr *forge.PullRequest) string {
+	reviews, err := client.ListReviews(ctx, owner, name, pr.Number)
+	if err != nil {
+		log.Printf("warn: could not get reviews of #%v: %v\n", pr.Number, err)
+		reviews = nil
+	}
+
+	latest := latestReviews(reviews)
+	if users := writersOf(ctx, client, owner, name, reviewersByState(latest, "changes_requested")); len(users) > 0 {
+		return "has changes requested by " + quoteNames(users)
+	}
+
+	protection, err := client.GetBranchProtection(ctx, owner, name, pr.BaseRef)
+	if err != nil {
+		log.Printf("warn: could not get the protection of `%v`: %v\n", pr.BaseRef, err)
+		return ""
+	}
+
+	if protection == nil {
+		return ""
+	}
+
+	if required := protection.RequiredApprovingReviews; required > 0 {
+		approved := 0
+		for _, r := range latest {
+			if r.State != "approved" {
+				continue
+			}
+			if protection.DismissStaleReviews && r.CommitID != pr.HeadSHA {
+				continue
+			}
+			approved++
+		}
+
+		if approved < required {
+			return fmt.Sprintf("has %v approving reviews but the branch protection of `%v` requires %v", approved, pr.BaseRef, required)
+		}
+	}
+
+	if len(protection.RequiredStatusChecks) > 0 {
+		if failed := failedRequiredChecks(ctx, client, owner, name, pr.HeadSHA, protection.RequiredStatusChecks); len(failed) > 0 {
+			return "fails the required status checks " + quoteNames(failed)
+		}
+	}
+
+	return ""
+}
+
+// latestReviews returns the last effective review per reviewer.
+// A comment does not change the state, and a dismissed review cancels the previous one.
+func latestReviews(reviews []*forge.Review) map[string]*forge.Review {
+	latest := make(map[string]*forge.Review)
+	for _, r := range reviews {
+		switch r.State {
+		case "approved", "changes_requested":
+			latest[r.User] = r
+		case "dismissed":
+			delete(latest, r.User)
+		}
+	}
+	return latest
+}
+
+func reviewersByState(latest map[string]*forge.Review, state string) []string {
+	users := make([]string, 0)
+	for user, r := range latest {
+		if r.State == state {
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// writersOf returns users who have the write permission to the repository.
+// A user whose permission cannot be fetched is regarded as a writer not to miss the blocking review.
+func writersOf(ctx context.Context, client forge.Client, owner, name string, users []string) []string {
+	writers := make([]string, 0, len(users))
+	for _, user := range users {
+		permission, err := client.GetPermission(ctx, owner, name, user)
+		if err != nil {
+			log.Printf("warn: could not get the permission of `%v` to %v/%v: %v\n", user, owner, name, err)
+			writers = append(writers, user)
+			continue
+		}
+
+		switch permission {
+		case "owner", "admin", "write":
+			writers = append(writers, user)
+		default:
+			log.Printf("info: the review by `%v` does not block because the permission is `%v`\n", user, permission)
+		}
+	}
+	return writers
+}
+
+// failedRequiredChecks returns the required contexts which have failed for `sha`.
+// Pending or missing ones are not regarded as failed because they may finish while we test the auto branch.
+func failedRequiredChecks(ctx context.Context, client forge.Client, owner, name, sha string, required []string) []string {
+	list := make([]string, 0)
+
+	ok, failing := operation.GetFailingContexts(ctx, client, owner, name, sha)
+	if !ok {
+		return list
+	}
+
+	failed := make(map[string]bool)
+	for _, context := range failing {
+		failed[context] = true
+	}
+
+	for _, context := range required {
+		if failed[context] {
+			list = append(list, context)
+		}
+	}
+	return list
+}
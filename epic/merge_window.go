@@ -0,0 +1,137 @@
+package epic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/notify"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+)
+
+// holdQueue leaves awaiting items in the queue while the tree is closed
+// and tells each of them only once that it is waiting for the merge window.
+func holdQueue(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, owner, name string, q *queue.AutoMergeQueue, reason string) {
+	log.Printf("info: hold the queue of %v/%v because %v\n", owner, name, reason)
+
+	awaiting := q.Awaiting()
+	held := false
+	for _, item := range awaiting {
+		if item.WaitingForWindow {
+			held = true
+			break
+		}
+	}
+
+	for _, item := range awaiting {
+		if item.WaitingForWindow {
+			continue
+		}
+		item.WaitingForWindow = true
+
+		comment := fmt.Sprintf(":zzz: This is waiting for the merge window because %v. "+
+			"It stays in the approved queue and will be tried automatically when the tree opens.", reason)
+		t := &report.Transition{
+			Kind:     report.KindWaiting,
+			Message:  comment,
+			SHA:      item.PrHead,
+			Position: queuePosition(q, item.PullRequest),
+		}
+		if ok := reporter.Report(ctx, client, owner, name, item.PullRequest, t); !ok {
+			log.Println("info: could not create the comment about the merge window.")
+		}
+	}
+
+	// Notify only when the queue starts to be held.
+	if held {
+		return
+	}
+
+	front := q.Front()
+	notifier.Notify(&notify.Event{
+		Kind:    notify.EventTreeClosed,
+		Owner:   owner,
+		Name:    name,
+		Number:  front.PullRequest,
+		SHA:     front.PrHead,
+		Message: fmt.Sprintf("The approved queue is held because %v.", reason),
+	})
+}
+
+// clearWaitingMarks forgets that awaiting items have been told about the merge window
+// so that they are told again when the tree is closed next time.
+func clearWaitingMarks(q *queue.AutoMergeQueue) {
+	for _, item := range q.Awaiting() {
+		item.WaitingForWindow = false
+	}
+}
+
+// putBackActiveItem returns the active item to the front of the queue without merging it.
+// Its auto branch will be rebuilt when the tree opens because the upstream may change until then.
+func putBackActiveItem(q *queue.AutoMergeQueue) {
+	active := q.GetActive()
+	q.RemoveActive()
+
+	active.AutoBranchHead = nil
+	active.StartedAt = nil
+	active.Retries = 0
+	if ok := q.PushFront(active); !ok {
+		log.Printf("error: cannot put back #%v to the queue\n", active.PullRequest)
+	}
+}
+
+// ResumeQueue starts to try the next item of the queue which has been held by the closed tree
+// if the tree opens at `now`.
+func ResumeQueue(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, qHandle *queue.AutoMergeQueueHandle, now time.Time) {
+	owner := qHandle.Owner()
+	name := qHandle.Name()
+
+	// Peek the queue at first to avoid fetching `OWNERS.json` for a queue which is not held.
+	qHandle.Lock()
+	held := isHeld(qHandle.Load())
+	qHandle.Unlock()
+	if !held {
+		return
+	}
+
+	repoInfo := GetRepositoryInfo(ctx, client, owner, name, "")
+	if repoInfo == nil {
+		log.Println("debug: cannot get repositoryInfo")
+		return
+	}
+
+	if !repoInfo.EnableAutoMerge {
+		return
+	}
+
+	if closed, _ := repoInfo.IsTreeClosed(now); closed {
+		return
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+	if !isHeld(q) {
+		return
+	}
+
+	log.Printf("info: resume the queue of %v/%v\n", owner, name)
+	tryNextItem(ctx, client, notifier, reporter, owner, name, q, repoInfo)
+}
+
+func isHeld(q *queue.AutoMergeQueue) bool {
+	if q.HasActive() {
+		return false
+	}
+
+	for _, item := range q.Awaiting() {
+		if item.WaitingForWindow {
+			return true
+		}
+	}
+	return false
+}
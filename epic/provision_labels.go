@@ -0,0 +1,111 @@
+package epic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/input"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// ProvisionLabels creates status labels which the repository does not have,
+// and updates ones whose color or description is different from `OWNERS.json`.
+// This returns the names of created and updated labels.
+func ProvisionLabels(ctx context.Context, client forge.Client, owner, name string, statusLabels *setting.StatusLabels) (created []string, updated []string, err error) {
+	current, err := client.ListRepositoryLabels(ctx, owner, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Label names are case-insensitive on GitHub.
+	existing := make(map[string]*forge.Label)
+	for _, l := range current {
+		existing[strings.ToLower(l.Name)] = l
+	}
+
+	created = make([]string, 0)
+	updated = make([]string, 0)
+	for _, l := range statusLabels.All() {
+		want := &forge.Label{
+			Name:        l.Name,
+			Color:       l.Color,
+			Description: l.Description,
+		}
+
+		found, ok := existing[strings.ToLower(l.Name)]
+		if !ok {
+			if err := client.CreateLabel(ctx, owner, name, want); err != nil {
+				return created, updated, fmt.Errorf("could not create the label `%v`: %v", l.Name, err)
+			}
+			log.Printf("info: create the label `%v` in %v/%v\n", l.Name, owner, name)
+			created = append(created, l.Name)
+			continue
+		}
+
+		if found.Name == want.Name && strings.EqualFold(found.Color, want.Color) && found.Description == want.Description {
+			continue
+		}
+
+		if err := client.UpdateLabel(ctx, owner, name, found.Name, want); err != nil {
+			return created, updated, fmt.Errorf("could not update the label `%v`: %v", found.Name, err)
+		}
+		log.Printf("info: update the label `%v` in %v/%v\n", l.Name, owner, name)
+		updated = append(updated, l.Name)
+	}
+
+	return created, updated, nil
+}
+
+type ProvisionLabelsCommand struct {
+	BotName string
+	Client  forge.Client
+	Owner   string
+	Name    string
+	Cmd     *input.ProvisionLabelsCommand
+	Info    *setting.RepositoryInfo
+}
+
+func (c *ProvisionLabelsCommand) ProvisionLabels(ctx context.Context, ev *forge.CommentEvent) (bool, error) {
+	log.Printf("info: Start: provision labels by %v\n", ev.CommentID)
+	defer log.Printf("info: End: provision labels by %v\n", ev.CommentID)
+
+	if c.BotName != c.Cmd.BotName() {
+		log.Printf("info: this command works only if target user is actual our bot.")
+		return false, nil
+	}
+
+	sender := ev.Sender
+	if !c.Info.IsReviewer(sender) {
+		log.Printf("info: %v is not an reviewer registred to this bot.\n", sender)
+		return rejectReviewerOnly(sender, c.Info)
+	}
+
+	created, updated, err := ProvisionLabels(ctx, c.Client, c.Owner, c.Name, c.Info.Labels)
+	if err != nil {
+		log.Printf("warn: could not provision labels in %v/%v: %v\n", c.Owner, c.Name, err)
+		return false, err
+	}
+
+	var comment string
+	if len(created) == 0 && len(updated) == 0 {
+		comment = ":label: All status labels are up to date."
+	} else {
+		comment = ":label: Status labels have been provisioned."
+		if len(created) > 0 {
+			comment += "\n* Created: " + quoteNames(created)
+		}
+		if len(updated) > 0 {
+			comment += "\n* Updated: " + quoteNames(updated)
+		}
+	}
+
+	if ok := operation.AddComment(ctx, c.Client, c.Owner, c.Name, ev.Number, comment); !ok {
+		log.Println("info: could not create the comment about provisioned labels.")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,217 @@
+package epic
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+
+	"github.com/voyagegroup/popuko/notify"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// ReconcileQueue makes the queue consistent with the state of the forge.
+// This recovers the queue which stalls by the lost webhook (e.g. this bot was down when CI had reported its result).
+// Labels of pull requests updated within `interval` before `now` are left as is
+// because their deliveries (e.g. the approval) may not have been processed yet.
+func ReconcileQueue(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, qHandle *queue.AutoMergeQueueHandle, now time.Time, interval time.Duration) {
+	owner := qHandle.Owner()
+	name := qHandle.Name()
+	log.Printf("info: Start: reconcile the queue of %v/%v\n", owner, name)
+	defer log.Printf("info: End: reconcile the queue of %v/%v\n", owner, name)
+
+	repoInfo := GetRepositoryInfo(ctx, client, owner, name, "")
+	if repoInfo == nil {
+		log.Println("debug: cannot get repositoryInfo")
+		return
+	}
+
+	if !repoInfo.EnableAutoMerge {
+		log.Println("info: this repository does not enable merging into master automatically.")
+		return
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+
+	mutated := false
+	if q.HasActive() {
+		if reconcileActiveItem(ctx, client, notifier, reporter, owner, name, repoInfo, q) {
+			mutated = true
+		}
+	}
+
+	for _, item := range q.Awaiting() {
+		if reconcileAwaitingItem(ctx, client, reporter, repoInfo.Labels, owner, name, q, item) {
+			mutated = true
+		}
+	}
+
+	reconcileLabels(ctx, client, repoInfo.Labels, owner, name, q, now.Add(-interval))
+
+	if !q.HasActive() {
+		tryNextItem(ctx, client, notifier, reporter, owner, name, q, repoInfo)
+		return
+	}
+
+	if mutated {
+		q.Save()
+	}
+}
+
+// reconcileActiveItem finishes the active item if its CI has completed or it becomes invalid.
+func reconcileActiveItem(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, owner, name string, repoInfo *setting.RepositoryInfo, q *queue.AutoMergeQueue) (mutated bool) {
+	active := q.GetActive()
+	prNum := active.PullRequest
+
+	pr, err := client.GetPullRequest(ctx, owner, name, prNum)
+	if err != nil {
+		log.Printf("info: could not fetch the pull request #%v: %v\n", prNum, err)
+		return false
+	}
+
+	if state := pr.State; state != "open" {
+		log.Printf("info: the active #%v has been resolved as `%v`\n", prNum, state)
+		q.RemoveActive()
+		return true
+	}
+
+	if accepted := active.PrHead; pr.HeadSHA != accepted && isSamePatch(ctx, client, owner, name, pr.BaseRef, accepted, pr.HeadSHA) {
+		if rebuildActiveItem(ctx, client, reporter, owner, name, repoInfo, active, pr) {
+			carryOverApproval(ctx, client, reporter, owner, name, q, prNum, accepted, pr.HeadSHA)
+			return true
+		}
+	}
+
+	if pr.HeadSHA != active.PrHead {
+		operation.CommentHeadIsDifferentFromAccepted(ctx, client, reporter, repoInfo.Labels, owner, name, prNum)
+		q.RemoveActive()
+		return true
+	}
+
+	if active.AutoBranchHead == nil {
+		log.Printf("warn: the active #%v does not have the head of the auto branch\n", prNum)
+		q.RemoveActive()
+		return true
+	}
+
+	sha := *active.AutoBranchHead
+	ok, completed, state := operation.GetCIResult(ctx, client, owner, name, sha)
+	if !ok || !completed {
+		log.Printf("info: CI for %v (#%v) has not completed yet\n", sha, prNum)
+		return false
+	}
+
+	log.Printf("info: CI for %v (#%v) has completed as `%v` without any webhook\n", sha, prNum, state)
+	info := StateChangeInfo{
+		Status:        state,
+		Owner:         owner,
+		Name:          name,
+		DefaultBranch: "",
+		SHA:           sha,
+	}
+	if retryActiveItem(ctx, client, reporter, owner, name, repoInfo, q, info) {
+		return true
+	}
+
+	mergeSucceedItem(ctx, client, notifier, reporter, owner, name, repoInfo, q, info)
+	q.RemoveActive()
+	return true
+}
+
+// reconcileAwaitingItem drops the item whose pull request is closed or has a new head.
+func reconcileAwaitingItem(ctx context.Context, client forge.Client, reporter *report.Reporter, labels *setting.StatusLabels, owner, name string, q *queue.AutoMergeQueue, item *queue.AutoMergeQueueItem) (mutated bool) {
+	prNum := item.PullRequest
+
+	pr, err := client.GetPullRequest(ctx, owner, name, prNum)
+	if err != nil {
+		log.Printf("info: could not fetch the pull request #%v: %v\n", prNum, err)
+		return false
+	}
+
+	if state := pr.State; state != "open" {
+		log.Printf("info: drop #%v from the queue because it has been resolved as `%v`\n", prNum, state)
+		q.RemoveAwaiting(prNum)
+		return true
+	}
+
+	if accepted := item.PrHead; pr.HeadSHA != accepted && isSamePatch(ctx, client, owner, name, pr.BaseRef, accepted, pr.HeadSHA) {
+		carryOverApproval(ctx, client, reporter, owner, name, q, prNum, accepted, pr.HeadSHA)
+		return true
+	}
+
+	if pr.HeadSHA != item.PrHead {
+		operation.CommentHeadIsDifferentFromAccepted(ctx, client, reporter, labels, owner, name, prNum)
+		q.RemoveAwaiting(prNum)
+		return true
+	}
+
+	return false
+}
+
+// reconcileLabels labels `S-awaiting-merge` (or its configured name) only to pull requests in the queue.
+// This does not remove the label from the pull request updated after `settled`.
+// It may be being approved, and the approval adds the label before it takes the queue.
+func reconcileLabels(ctx context.Context, client forge.Client, statusLabels *setting.StatusLabels, owner, name string, q *queue.AutoMergeQueue, settled time.Time) {
+	members := make(map[int]bool)
+	for _, item := range q.Awaiting() {
+		members[item.PullRequest] = true
+	}
+	if active := q.GetActive(); active != nil {
+		members[active.PullRequest] = true
+	}
+
+	labeled, err := client.ListOpenIssuesWithLabel(ctx, owner, name, statusLabels.AwaitingMerge.Name)
+	if err != nil {
+		log.Printf("warn: could not fetch issues labeled as `%v`: %v\n", statusLabels.AwaitingMerge.Name, err)
+		return
+	}
+
+	for _, issue := range labeled {
+		number := issue.Number
+		if members[number] {
+			delete(members, number)
+			continue
+		}
+
+		if !issue.IsPullRequest {
+			continue
+		}
+
+		if issue.UpdatedAt.After(settled) {
+			log.Printf("info: skip to reconcile the label of #%v because it has been updated recently\n", number)
+			continue
+		}
+
+		log.Printf("info: #%v is labeled as `%v` but it's not in the queue\n", number, statusLabels.AwaitingMerge.Name)
+		comment := ":question: This pull request is not in the approved queue. Please approve this again if it's still needed."
+		if ok := operation.AddComment(ctx, client, owner, name, number, comment); !ok {
+			log.Println("info: could not create the comment about the inconsistent label.")
+		}
+
+		labels := operation.AddAwaitingReviewLabel(statusLabels, issue.Labels)
+		if err := client.ReplaceLabels(ctx, owner, name, number, labels); err != nil {
+			log.Printf("warn: could not change labels of #%v: %v\n", number, err)
+		}
+	}
+
+	// The rest of members are not labeled.
+	for number := range members {
+		log.Printf("info: #%v is in the queue but it's not labeled as `%v`\n", number, statusLabels.AwaitingMerge.Name)
+		currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, number)
+		if currentLabels == nil {
+			continue
+		}
+
+		labels := operation.AddAwaitingMergeLabel(statusLabels, currentLabels)
+		if err := client.ReplaceLabels(ctx, owner, name, number, labels); err != nil {
+			log.Printf("warn: could not change labels of #%v: %v\n", number, err)
+		}
+	}
+}
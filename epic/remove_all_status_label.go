@@ -4,31 +4,26 @@ import (
 	"context"
 	"log"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
 	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/setting"
 )
 
-func RemoveAllStatusLabel(ctx context.Context, client *github.Client, repo *github.Repository, pr *github.PullRequest) {
-	owner := *repo.Owner.Login
-	name := *repo.Name
-	number := *pr.Number
+func RemoveAllStatusLabel(ctx context.Context, client forge.Client, statusLabels *setting.StatusLabels, repo forge.Repository, pr *forge.PullRequest) {
+	owner := repo.Owner
+	name := repo.Name
+	number := pr.Number
 
-	if pr.Merged == nil {
-		// This value should be boolean, not be nil.
-		log.Printf("error: we cannot get the merge status of #%v. We abort the process for safetyness & to save the API limit.\n", number)
-		return
-	}
-
-	currentLabels := operation.GetLabelsByIssue(ctx, client.Issues, owner, name, number)
+	currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, number)
 	if currentLabels == nil {
 		log.Printf("warn: could not get all labels of #%v\n", number)
 		return
 	}
 
-	labels := operation.RemoveStatusLabelFromList(currentLabels)
-	_, _, err := client.Issues.ReplaceLabelsForIssue(ctx, owner, name, number, labels)
+	labels := operation.RemoveStatusLabelFromList(statusLabels, currentLabels)
+	err := client.ReplaceLabels(ctx, owner, name, number, labels)
 	if err != nil {
-		log.Printf("warn: could not remove all `S-***` labels from #%v\n", number)
+		log.Printf("warn: could not remove all status labels from #%v\n", number)
 		return
 	}
 
@@ -0,0 +1,105 @@
+package epic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// retryActiveItem rebuilds the auto branch for the failed active item and runs CI again
+// if the repository allows to retry it (`auto_merge.retries`).
+// This returns true if the active item is retried and should be kept as active.
+func retryActiveItem(
+	ctx context.Context,
+	client forge.Client,
+	reporter *report.Reporter,
+	owner string,
+	name string,
+	repoInfo *setting.RepositoryInfo,
+	q *queue.AutoMergeQueue,
+	info StateChangeInfo) bool {
+
+	active := q.GetActive()
+	if active == nil || info.Status == "success" {
+		return false
+	}
+
+	if !canRetry(active, repoInfo.AutoMergeRetries) {
+		return false
+	}
+
+	prNum := active.PullRequest
+
+	if repoInfo.HasRetryContexts() {
+		ok, failures := operation.GetFailingContexts(ctx, client, owner, name, info.SHA)
+		if !ok {
+			return false
+		}
+
+		if !areAllRetriable(repoInfo, failures) {
+			log.Printf("info: the failures of #%v (%v) are not retriable\n", prNum, failures)
+			return false
+		}
+	}
+
+	prInfo, err := client.GetPullRequest(ctx, owner, name, prNum)
+	if err != nil {
+		log.Println("info: could not fetch the pull request information.")
+		return false
+	}
+
+	if prInfo.State != "open" {
+		log.Printf("info: the pull request #%v has been resolved the state\n", prNum)
+		return false
+	}
+
+	if prInfo.HeadSHA != active.PrHead {
+		log.Printf("info: the head of #%v has been changed after it was accepted\n", prNum)
+		return false
+	}
+
+	retries := active.Retries + 1
+	comment := fmt.Sprintf(":repeat: The result of what tried to merge this pull request is `%v`. Retry it (%v/%v).", info.Status, retries, repoInfo.AutoMergeRetries)
+	commentStatus(ctx, client, reporter, owner, name, prNum, report.KindRetrying, comment, repoInfo.AutoBranchName)
+
+	ok, commit := operation.TryWithDefaultBranch(ctx, client, reporter, owner, name, prInfo, repoInfo.AutoBranchName)
+	if !ok {
+		log.Printf("info: we cannot retry #%v with the latest `master`.", prNum)
+		return false
+	}
+
+	now := time.Now()
+	active.AutoBranchHead = &commit
+	active.StartedAt = &now
+	active.Retries = retries
+	log.Printf("info: retry #%v (%v/%v)\n", prNum, retries, repoInfo.AutoMergeRetries)
+
+	return true
+}
+
+func canRetry(item *queue.AutoMergeQueueItem, max int) bool {
+	return item.Retries < max
+}
+
+// areAllRetriable returns true only if every failing context is retriable.
+// A real failure must not be retried even if a flaky context fails together.
+func areAllRetriable(repoInfo *setting.RepositoryInfo, failures []string) bool {
+	if len(failures) == 0 {
+		return false
+	}
+
+	for _, context := range failures {
+		if !repoInfo.IsRetriableContext(context) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package epic
+
+import (
+	"testing"
+
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/setting"
+)
+
+func TestCanRetry(t *testing.T) {
+	type Testcase struct {
+		retries  int
+		max      int
+		expected bool
+	}
+	list := []Testcase{
+		Testcase{0, 0, false},
+		Testcase{0, 1, true},
+		Testcase{1, 1, false},
+		Testcase{1, 3, true},
+	}
+	for _, tc := range list {
+		item := &queue.AutoMergeQueueItem{Retries: tc.retries}
+		if actual := canRetry(item, tc.max); actual != tc.expected {
+			t.Errorf("canRetry(%v, %v) should be %v but %v", tc.retries, tc.max, tc.expected, actual)
+			return
+		}
+	}
+}
+
+func TestAreAllRetriable(t *testing.T) {
+	o := &setting.OwnersFile{
+		AutoMergeRetries:       1,
+		AutoMergeRetryContexts: []string{"ci/integration-*"},
+	}
+	ok, info := o.ToRepoInfo()
+	if !ok {
+		t.Errorf("should be success to convert from OwnersFile")
+		return
+	}
+
+	type Testcase struct {
+		failures []string
+		expected bool
+	}
+	list := []Testcase{
+		Testcase{[]string{}, false},
+		Testcase{[]string{"ci/integration-mysql"}, true},
+		Testcase{[]string{"ci/integration-mysql", "ci/integration-redis"}, true},
+		Testcase{[]string{"ci/integration-mysql", "ci/unit"}, false},
+		Testcase{[]string{"ci/unit"}, false},
+	}
+	for _, tc := range list {
+		if actual := areAllRetriable(info, tc.failures); actual != tc.expected {
+			t.Errorf("areAllRetriable(%v) should be %v but %v", tc.failures, tc.expected, actual)
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package epic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/setting"
+	"github.com/voyagegroup/popuko/store"
+)
+
+type reviewerAvailability struct {
+	info *setting.RepositoryInfo
+	now  time.Time
+	// The number of opened pull requests assigned per user.
+	// This is nil if we don't have to check `max_open_reviews`.
+	load map[string]int
+}
+
+func newReviewerAvailability(ctx context.Context, client forge.Client, owner, name string, info *setting.RepositoryInfo) *reviewerAvailability {
+	a := &reviewerAvailability{
+		info: info,
+		now:  time.Now(),
+	}
+
+	if info.HasReviewLimit() {
+		if ok, load := countAssignedPullRequests(ctx, client, owner, name); ok {
+			a.load = load
+		}
+	}
+
+	return a
+}
+
+// check returns whether `name` can accept a new review. If not, `reason` explains why.
+func (a *reviewerAvailability) check(name string) (ok bool, reason string) {
+	availability := a.info.Availability(name)
+	if availability == nil {
+		return true, ""
+	}
+
+	if away, period := availability.IsAway(a.now); away {
+		return false, fmt.Sprintf(":palm_tree: `%v` is away until %v.", name, period.Until)
+	}
+
+	if max := availability.MaxOpenReviews; max > 0 && a.load != nil && a.load[name] >= max {
+		return false, fmt.Sprintf(":inbox_tray: `%v` has too many reviews (%v/%v).", name, a.load[name], max)
+	}
+
+	return true, ""
+}
+
+func (a *reviewerAvailability) filter(list []string) []string {
+	result := make([]string, 0, len(list))
+	for _, name := range list {
+		if ok, reason := a.check(name); !ok {
+			log.Printf("info: skip `%v`: %v\n", name, reason)
+			continue
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// LoadAvailability overrides the availability in `info` by the one saved via REST API.
+func LoadAvailability(st *store.FileStore, owner, name string, info *setting.RepositoryInfo) {
+	h := st.Get(owner, name)
+	if h == nil {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	list := make(map[string]*setting.Availability)
+	if ok := h.Load(&list); !ok {
+		return
+	}
+
+	info.OverrideAvailability(list)
+}
@@ -0,0 +1,102 @@
+package epic
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+)
+
+// We cache the members of the team to save the API limit
+// because we need them on every command.
+const teamMembersCacheTTL = 10 * time.Minute
+
+type teamMembersEntry struct {
+	members   []string
+	fetchedAt time.Time
+}
+
+type teamMembersCache struct {
+	mux     sync.Mutex
+	ttl     time.Duration
+	entries map[string]*teamMembersEntry
+}
+
+func newTeamMembersCache(ttl time.Duration) *teamMembersCache {
+	return &teamMembersCache{
+		mux:     sync.Mutex{},
+		ttl:     ttl,
+		entries: make(map[string]*teamMembersEntry),
+	}
+}
+
+func (c *teamMembersCache) get(org, slug string, now time.Time) (bool, []string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	e, ok := c.entries[org+"/"+slug]
+	if !ok {
+		return false, nil
+	}
+
+	if now.Sub(e.fetchedAt) > c.ttl {
+		return false, nil
+	}
+
+	return true, e.members
+}
+
+func (c *teamMembersCache) set(org, slug string, members []string, now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.entries[org+"/"+slug] = &teamMembersEntry{
+		members:   members,
+		fetchedAt: now,
+	}
+}
+
+var teamCache = newTeamMembersCache(teamMembersCacheTTL)
+
+func fetchTeamMembers(ctx context.Context, client forge.Client, org, slug string) (bool, []string) {
+	now := time.Now()
+	if ok, members := teamCache.get(org, slug, now); ok {
+		return true, members
+	}
+
+	members, err := client.ListTeamMembers(ctx, org, slug)
+	if err != nil {
+		log.Printf("warn: could not fetch the members of `%v/%v`: %v\n", org, slug, err)
+		return false, nil
+	}
+
+	log.Printf("debug: the members of `%v/%v`: %v\n", org, slug, members)
+	teamCache.set(org, slug, members, now)
+	return true, members
+}
+
+func teamResolver(ctx context.Context, client forge.Client) func(org, slug string) (bool, []string) {
+	return func(org, slug string) (bool, []string) {
+		return fetchTeamMembers(ctx, client, org, slug)
+	}
+}
+
+// pickReviewerRandomly picks a member of `members` randomly except `excluded`.
+func pickReviewerRandomly(members []string, excluded string) (bool, string) {
+	candidates := make([]string, 0, len(members))
+	for _, m := range members {
+		if m == excluded {
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+
+	if len(candidates) == 0 {
+		return false, ""
+	}
+
+	return true, candidates[rand.Intn(len(candidates))]
+}
@@ -0,0 +1,40 @@
+package epic
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_teamMembersCache(t *testing.T) {
+	c := newTeamMembersCache(time.Minute)
+	now := time.Now()
+
+	if ok, _ := c.get("org", "team", now); ok {
+		t.Errorf("should not hit the cache before set")
+		return
+	}
+
+	c.set("org", "team", []string{"alice"}, now)
+
+	if ok, members := c.get("org", "team", now.Add(30*time.Second)); !ok || len(members) != 1 {
+		t.Errorf("should hit the cache but %v, %v", ok, members)
+		return
+	}
+
+	if ok, _ := c.get("org", "team", now.Add(2*time.Minute)); ok {
+		t.Errorf("should not hit the expired cache")
+		return
+	}
+}
+
+func Test_pickReviewerRandomly(t *testing.T) {
+	if ok, m := pickReviewerRandomly([]string{"alice", "bob"}, "alice"); !ok || m != "bob" {
+		t.Errorf("should pick bob but %v, %v", ok, m)
+		return
+	}
+
+	if ok, _ := pickReviewerRandomly([]string{"alice"}, "alice"); ok {
+		t.Errorf("should not pick the excluded user")
+		return
+	}
+}
@@ -0,0 +1,109 @@
+package epic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+
+	"github.com/voyagegroup/popuko/notify"
+	"github.com/voyagegroup/popuko/operation"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+)
+
+// CheckActiveItemTimeout gives up the active item if its auto branch has not reported
+// any result within `auto_merge.timeout`, and then starts to try the next item.
+func CheckActiveItemTimeout(ctx context.Context, client forge.Client, notifier *notify.Notifier, reporter *report.Reporter, qHandle *queue.AutoMergeQueueHandle, now time.Time) {
+	owner := qHandle.Owner()
+	name := qHandle.Name()
+
+	// Peek the active item at first to avoid fetching `OWNERS.json` for an idle queue.
+	qHandle.Lock()
+	var active queue.AutoMergeQueueItem
+	{
+		q := qHandle.Load()
+		current := q.GetActive()
+		if current == nil {
+			qHandle.Unlock()
+			return
+		}
+
+		// The item pinned by the older version does not have the started time.
+		// We start to count from now.
+		if current.StartedAt == nil {
+			current.StartedAt = &now
+			q.Save()
+		}
+
+		active = *current
+	}
+	qHandle.Unlock()
+
+	repoInfo := GetRepositoryInfo(ctx, client, owner, name, "")
+	if repoInfo == nil {
+		log.Println("debug: cannot get repositoryInfo")
+		return
+	}
+
+	timeout := repoInfo.AutoMergeTimeout
+	if !repoInfo.EnableAutoMerge || !isTimedOut(&active, timeout, now) {
+		return
+	}
+
+	qHandle.Lock()
+	defer qHandle.Unlock()
+
+	q := qHandle.Load()
+
+	// The active item might be changed while we fetch `OWNERS.json`.
+	current := q.GetActive()
+	if current == nil || current.PullRequest != active.PullRequest || !isTimedOut(current, timeout, now) {
+		return
+	}
+
+	prNum := current.PullRequest
+	log.Printf("info: the active #%v in %v/%v has been timed out\n", prNum, owner, name)
+
+	comment := fmt.Sprintf(":hourglass_flowing_sand: CI did not report any result for the auto branch within %v. We gave up to merge this.", timeout)
+	t := &report.Transition{
+		Kind:    report.KindTimedOut,
+		Message: comment,
+		SHA:     current.PrHead,
+	}
+	if ok := reporter.Report(ctx, client, owner, name, prNum, t); !ok {
+		log.Println("info: could not create the comment about the timeout.")
+	}
+
+	notifier.Notify(&notify.Event{
+		Kind:    notify.EventQueueStalled,
+		Owner:   owner,
+		Name:    name,
+		Number:  prNum,
+		SHA:     current.PrHead,
+		Message: fmt.Sprintf("CI did not report any result for the auto branch within %v.", timeout),
+	})
+
+	currentLabels := operation.GetLabelsByIssue(ctx, client, owner, name, prNum)
+	if currentLabels != nil {
+		labels := operation.AddTimedOutWithUpstreamLabel(repoInfo.Labels, currentLabels)
+		if err := client.ReplaceLabels(ctx, owner, name, prNum, labels); err != nil {
+			log.Println("warn: could not change labels of the issue")
+		}
+	}
+
+	q.RemoveActive()
+	q.Save()
+
+	tryNextItem(ctx, client, notifier, reporter, owner, name, q, repoInfo)
+}
+
+func isTimedOut(item *queue.AutoMergeQueueItem, timeout time.Duration, now time.Time) bool {
+	if timeout <= 0 || item.StartedAt == nil {
+		return false
+	}
+
+	return now.Sub(*item.StartedAt) >= timeout
+}
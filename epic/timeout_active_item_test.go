@@ -0,0 +1,32 @@
+package epic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/voyagegroup/popuko/queue"
+)
+
+func Test_isTimedOut(t *testing.T) {
+	now := time.Now()
+	started := now.Add(-time.Hour)
+	item := &queue.AutoMergeQueueItem{
+		StartedAt: &started,
+	}
+
+	if isTimedOut(item, 0, now) {
+		t.Errorf("should not be timed out if the timeout is disabled")
+	}
+
+	if isTimedOut(item, 2*time.Hour, now) {
+		t.Errorf("should not be timed out before the timeout")
+	}
+
+	if !isTimedOut(item, 30*time.Minute, now) {
+		t.Errorf("should be timed out after the timeout")
+	}
+
+	if isTimedOut(&queue.AutoMergeQueueItem{}, time.Minute, now) {
+		t.Errorf("should not be timed out without the started time")
+	}
+}
@@ -0,0 +1,192 @@
+package event
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a delivery which this bot has received, with the result of processing it.
+type Record struct {
+	Delivery
+
+	// This is empty while the delivery is not processed yet.
+	Result      string     `json:"result,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// The result of the delivery which has been processed without any errors.
+const ResultSuccess = "success"
+
+// History records deliveries into `<root>/deliveries/<id>.json`
+// to skip redeliveries and to replay them later.
+type History struct {
+	dir string
+	mux sync.Mutex
+}
+
+const historyDirName = "deliveries"
+
+func NewHistory(root string) *History {
+	p, err := filepath.Abs(filepath.Join(root, historyDirName))
+	if err != nil {
+		log.Printf("error: cannot get the path to the delivery history: %v\n", err)
+		return nil
+	}
+
+	if err := os.MkdirAll(p, os.ModePerm); err != nil {
+		log.Printf("error: cannot create the delivery history dir: %v\n", err)
+		return nil
+	}
+
+	return &History{
+		dir: p,
+	}
+}
+
+// Add records `d` if it has not been recorded yet.
+// `added` is false if `d` is a duplicate of the recorded one.
+func (h *History) Add(d *Delivery) (ok, added bool) {
+	if !validID(d.ID) {
+		log.Printf("warn: `%v` is invalid as the delivery id\n", d.ID)
+		return false, false
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if _, err := os.Stat(h.file(d.ID)); err == nil {
+		return true, false
+	}
+
+	if ok := h.save(&Record{Delivery: *d}); !ok {
+		return false, false
+	}
+
+	return true, true
+}
+
+// Get returns the record of the delivery `id`. This returns nil if there is none.
+func (h *History) Get(id string) *Record {
+	if !validID(id) {
+		return nil
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	return h.load(id)
+}
+
+// Finish records the result of the delivery `id`.
+func (h *History) Finish(id string, result string, now time.Time) {
+	if !validID(id) {
+		return
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	r := h.load(id)
+	if r == nil {
+		log.Printf("warn: the delivery %v is not recorded\n", id)
+		return
+	}
+
+	r.Result = result
+	r.ProcessedAt = &now
+	h.save(r)
+}
+
+// Remove forgets the delivery `id` so that its redelivery is accepted
+// (e.g. we could not queue it).
+func (h *History) Remove(id string) {
+	if !validID(id) {
+		return
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if err := os.Remove(h.file(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("error: cannot remove the delivery %v: %v\n", id, err)
+	}
+}
+
+// Prune removes records which have not been updated since `before`.
+func (h *History) Prune(before time.Time) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	files, err := ioutil.ReadDir(h.dir)
+	if err != nil {
+		log.Printf("error: cannot read the delivery history dir: %v\n", err)
+		return
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" || !f.ModTime().Before(before) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(h.dir, f.Name())); err != nil {
+			log.Printf("error: cannot remove %v: %v\n", f.Name(), err)
+		}
+	}
+}
+
+func (h *History) load(id string) *Record {
+	b, err := ioutil.ReadFile(h.file(id))
+	if err != nil {
+		return nil
+	}
+
+	var r Record
+	if err := json.Unmarshal(b, &r); err != nil {
+		log.Printf("error: cannot decode the delivery %v: %v\n", id, err)
+		return nil
+	}
+
+	return &r
+}
+
+func (h *History) save(r *Record) bool {
+	b, err := json.Marshal(r)
+	if err != nil {
+		log.Printf("error: cannot encode the delivery %v: %v\n", r.ID, err)
+		return false
+	}
+
+	file := h.file(r.ID)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		log.Printf("error: cannot write the delivery %v: %v\n", r.ID, err)
+		return false
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		log.Printf("error: cannot rename %v to %v: %v\n", tmp, file, err)
+		return false
+	}
+
+	return true
+}
+
+func (h *History) file(id string) string {
+	return filepath.Join(h.dir, id+".json")
+}
+
+// validID checks `id` is secure string as a file name.
+func validID(id string) bool {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return false
+	}
+
+	return path.Base(id) == id && filepath.Base(id) == id
+}
@@ -0,0 +1,75 @@
+package event
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHistorySkipsDuplicatedDelivery(t *testing.T) {
+	root, err := ioutil.TempDir("", "popuko-event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	h := NewHistory(root)
+	if h == nil {
+		t.Errorf("should be success to create the history")
+		return
+	}
+
+	d := &Delivery{ID: "72d3162e-cc78-11e3-81ab-4c9367dc0958", Type: "status", Payload: []byte(`{}`)}
+	if ok, added := h.Add(d); !ok || !added {
+		t.Errorf("should add the new delivery: ok=%v, added=%v", ok, added)
+		return
+	}
+
+	if ok, added := h.Add(d); !ok || added {
+		t.Errorf("should skip the duplicated delivery: ok=%v, added=%v", ok, added)
+		return
+	}
+
+	// The removed delivery is accepted again (e.g. its redelivery after we could not queue it).
+	h.Remove(d.ID)
+	if ok, added := h.Add(d); !ok || !added {
+		t.Errorf("should add the removed delivery again: ok=%v, added=%v", ok, added)
+		return
+	}
+
+	h.Finish(d.ID, ResultSuccess, time.Now())
+
+	r := h.Get(d.ID)
+	if r == nil {
+		t.Errorf("should get the record")
+		return
+	}
+
+	if r.Result != ResultSuccess || r.ProcessedAt == nil || r.Type != "status" {
+		t.Errorf("unexpected record: %+v", r)
+		return
+	}
+
+	h.Prune(time.Now().Add(time.Hour))
+	if r := h.Get(d.ID); r != nil {
+		t.Errorf("should be pruned")
+		return
+	}
+}
+
+func TestHistoryRejectsInvalidID(t *testing.T) {
+	root, err := ioutil.TempDir("", "popuko-event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	h := NewHistory(root)
+	for _, id := range []string{"", ".", "..", "../config", "a/b"} {
+		if ok, _ := h.Add(&Delivery{ID: id}); ok {
+			t.Errorf("`%v` should be rejected", id)
+			return
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery is a webhook delivery from GitHub which has been validated and persisted.
+type Delivery struct {
+	// The value of `X-GitHub-Delivery`.
+	ID string `json:"id"`
+	// The value of `X-GitHub-Event`.
+	Type string `json:"type"`
+
+	// The repository which this delivery comes from.
+	// These are empty if the event is not related to any repositories.
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+
+	Payload    json.RawMessage `json:"payload"`
+	ReceivedAt time.Time       `json:"received_at"`
+
+	// The id of the original delivery if this is replayed.
+	ReplayOf string `json:"replay_of,omitempty"`
+
+	seq int64
+}
+
+func (d *Delivery) repoKey() string {
+	return d.Owner + "/" + d.Name
+}
+
+// Handler processes a delivery. The delivery is removed from the queue after this returns.
+// The returned error is recorded as the result of the delivery.
+type Handler func(ctx context.Context, d *Delivery) error
+
+// Queue persists deliveries into `<root>/events/` and processes them by a worker pool.
+// Deliveries for the same repository are processed one by one in the received order.
+// Deliveries which have not been processed yet are restored on creating the queue again.
+type Queue struct {
+	dir     string
+	handler Handler
+	// This records results of deliveries if this is not nil.
+	history *History
+
+	mux  sync.Mutex
+	cond *sync.Cond
+	seq  int64
+	// Deliveries which are not processed yet per repository.
+	pending map[string][]*Delivery
+	// Repositories which are processed by some worker now.
+	busy map[string]bool
+	// Repositories which have pending deliveries and are not busy, in FIFO order.
+	ready  []string
+	closed bool
+
+	wg sync.WaitGroup
+}
+
+const dirName = "events"
+
+func NewQueue(root string, handler Handler, history *History) *Queue {
+	p, err := filepath.Abs(filepath.Join(root, dirName))
+	if err != nil {
+		log.Printf("error: cannot get the path to the event queue: %v\n", err)
+		return nil
+	}
+
+	if err := os.MkdirAll(p, os.ModePerm); err != nil {
+		log.Printf("error: cannot create the event queue dir: %v\n", err)
+		return nil
+	}
+
+	q := &Queue{
+		dir:     p,
+		handler: handler,
+		history: history,
+		pending: make(map[string][]*Delivery),
+		busy:    make(map[string]bool),
+		ready:   make([]string, 0),
+	}
+	q.cond = sync.NewCond(&q.mux)
+
+	if ok := q.restore(); !ok {
+		return nil
+	}
+
+	return q
+}
+
+// restore loads deliveries which have not been processed before the last shutdown.
+func (q *Queue) restore() bool {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		log.Printf("error: cannot read the event queue dir: %v\n", err)
+		return false
+	}
+
+	list := make([]*Delivery, 0, len(files))
+	for _, f := range files {
+		n := f.Name()
+		if f.IsDir() || filepath.Ext(n) != ".json" {
+			continue
+		}
+
+		seq, err := strconv.ParseInt(strings.TrimSuffix(n, ".json"), 10, 64)
+		if err != nil {
+			log.Printf("warn: %v is not an event file\n", n)
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(q.dir, n))
+		if err != nil {
+			log.Printf("error: cannot read %v: %v\n", n, err)
+			continue
+		}
+
+		var d Delivery
+		if err := json.Unmarshal(b, &d); err != nil {
+			log.Printf("error: cannot decode %v: %v\n", n, err)
+			continue
+		}
+		d.seq = seq
+		list = append(list, &d)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].seq < list[j].seq
+	})
+
+	for _, d := range list {
+		q.enqueue(d)
+		if d.seq > q.seq {
+			q.seq = d.seq
+		}
+	}
+
+	if len(list) > 0 {
+		log.Printf("info: restored %v unprocessed event(s)\n", len(list))
+	}
+	return true
+}
+
+// Push persists `d` and then adds it to the queue.
+// This returns false if we could not persist it. Then the caller should ask the sender to redeliver it.
+func (q *Queue) Push(d *Delivery) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	seq := time.Now().UnixNano()
+	if seq <= q.seq {
+		seq = q.seq + 1
+	}
+	d.seq = seq
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		log.Printf("error: cannot encode the delivery %v: %v\n", d.ID, err)
+		return false
+	}
+
+	// Write into the temporary file at first to avoid to restore a broken delivery.
+	file := q.file(d)
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		log.Printf("error: cannot write the delivery %v: %v\n", d.ID, err)
+		return false
+	}
+
+	if err := os.Rename(tmp, file); err != nil {
+		log.Printf("error: cannot rename %v to %v: %v\n", tmp, file, err)
+		return false
+	}
+
+	q.seq = seq
+	q.enqueue(d)
+	return true
+}
+
+// enqueue must be called with holding the lock.
+func (q *Queue) enqueue(d *Delivery) {
+	key := d.repoKey()
+	list := q.pending[key]
+	if len(list) == 0 && !q.busy[key] {
+		q.ready = append(q.ready, key)
+		q.cond.Signal()
+	}
+	q.pending[key] = append(list, d)
+}
+
+// Start launches `workers` goroutines to process deliveries.
+func (q *Queue) Start(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// Close stops workers after they finish current deliveries.
+// Pending deliveries are kept on the disk and processed after restarting.
+func (q *Queue) Close() {
+	q.mux.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mux.Unlock()
+
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for {
+		d := q.take()
+		if d == nil {
+			return
+		}
+
+		result := q.process(d)
+		q.done(d)
+
+		if q.history != nil {
+			q.history.Finish(d.ID, result, time.Now())
+		}
+	}
+}
+
+// take returns the next delivery of a repository which is not busy.
+// This returns nil if the queue has been closed.
+func (q *Queue) take() *Delivery {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	for len(q.ready) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return nil
+	}
+
+	key := q.ready[0]
+	q.ready = q.ready[1:]
+
+	list := q.pending[key]
+	d := list[0]
+	q.pending[key] = list[1:]
+	q.busy[key] = true
+
+	return d
+}
+
+func (q *Queue) process(d *Delivery) (result string) {
+	defer func() {
+		// A broken delivery must not stop the worker and must not be retried forever.
+		if err := recover(); err != nil {
+			log.Printf("error: panic on processing the delivery %v (%v): %v\n", d.ID, d.Type, err)
+			result = fmt.Sprintf("panic: %v", err)
+		}
+	}()
+
+	log.Printf("info: Start: process the delivery %v (%v) for %v\n", d.ID, d.Type, d.repoKey())
+	defer log.Printf("info: End: process the delivery %v (%v) for %v\n", d.ID, d.Type, d.repoKey())
+
+	if err := q.handler(context.Background(), d); err != nil {
+		return err.Error()
+	}
+	return ResultSuccess
+}
+
+func (q *Queue) done(d *Delivery) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if err := os.Remove(q.file(d)); err != nil {
+		log.Printf("error: cannot remove the delivery %v: %v\n", d.ID, err)
+	}
+
+	key := d.repoKey()
+	delete(q.busy, key)
+	if len(q.pending[key]) == 0 {
+		delete(q.pending, key)
+		return
+	}
+
+	q.ready = append(q.ready, key)
+	q.cond.Signal()
+}
+
+func (q *Queue) file(d *Delivery) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d.json", d.seq))
+}
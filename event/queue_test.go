@@ -0,0 +1,117 @@
+package event
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, ch <-chan string, n int) []string {
+	result := make([]string, 0, n)
+	for len(result) < n {
+		select {
+		case id := <-ch:
+			result = append(result, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out: processed %v", result)
+		}
+	}
+	return result
+}
+
+func TestQueueRestoresUnprocessedDeliveries(t *testing.T) {
+	root, err := ioutil.TempDir("", "popuko-event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	first := NewQueue(root, func(ctx context.Context, d *Delivery) error {
+		t.Errorf("should not process any delivery before starting")
+		return nil
+	}, nil)
+	if first == nil {
+		t.Errorf("should be success to create the queue")
+		return
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if ok := first.Push(&Delivery{ID: id, Type: "status", Owner: "o", Name: "n"}); !ok {
+			t.Errorf("should be success to push %v", id)
+			return
+		}
+	}
+
+	ch := make(chan string, 3)
+	second := NewQueue(root, func(ctx context.Context, d *Delivery) error {
+		ch <- d.ID
+		return nil
+	}, nil)
+	second.Start(2)
+	defer second.Close()
+
+	actual := waitFor(t, ch, 3)
+	for i, expected := range []string{"a", "b", "c"} {
+		if actual[i] != expected {
+			t.Errorf("should be processed in the received order but %v", actual)
+			return
+		}
+	}
+}
+
+func TestQueueSerializesPerRepository(t *testing.T) {
+	root, err := ioutil.TempDir("", "popuko-event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	var mux sync.Mutex
+	running := make(map[string]bool)
+	ch := make(chan string, 8)
+	q := NewQueue(root, func(ctx context.Context, d *Delivery) error {
+		mux.Lock()
+		if running[d.Name] {
+			t.Errorf("%v should not be processed concurrently", d.Name)
+		}
+		running[d.Name] = true
+		mux.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mux.Lock()
+		running[d.Name] = false
+		mux.Unlock()
+
+		ch <- d.Name + "/" + d.ID
+		return nil
+	}, nil)
+	q.Start(4)
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		q.Push(&Delivery{ID: id, Owner: "o", Name: "x"})
+		q.Push(&Delivery{ID: id, Owner: "o", Name: "y"})
+	}
+
+	actual := waitFor(t, ch, 8)
+	q.Close()
+
+	next := map[string]int{"x": 1, "y": 1}
+	for _, v := range actual {
+		name := v[:1]
+		if expected := name + "/" + string('0'+rune(next[name])); v != expected {
+			t.Errorf("should be %v but %v", expected, v)
+			return
+		}
+		next[name]++
+	}
+
+	files, _ := ioutil.ReadDir(q.dir)
+	if len(files) != 0 {
+		t.Errorf("processed deliveries should be removed but %v files remain", len(files))
+		return
+	}
+}
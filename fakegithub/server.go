@@ -0,0 +1,1165 @@
+// Package fakegithub provides an in-process fake of GitHub REST API for end-to-end tests.
+//
+// This models only the subset of the API which this bot uses:
+// repositories, refs, pull requests, labels, comments, reactions, statuses, check suites, reviews,
+// branch protections and contents.
+// Requests for the other endpoints are recorded by `Unhandled()` and fail with 404.
+package fakegithub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	pathpkg "path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+)
+
+type Server struct {
+	server *httptest.Server
+
+	mux       sync.Mutex
+	repos     map[string]*Repository
+	sequence  int
+	unhandled []string
+	// The account which calls the API. Comments created by the API are authored by it.
+	login string
+}
+
+type Repository struct {
+	Owner         string
+	Name          string
+	DefaultBranch string
+
+	// `heads/<branch>` => sha
+	refs map[string]string
+	// path => content in the default branch
+	files        map[string]string
+	pullRequests map[int]*PullRequest
+	labels       map[int][]string
+	// number => the last time when its labels were changed
+	updatedAt map[int]time.Time
+	// The labels defined in the repository.
+	repoLabels []*github.Label
+	comments   map[int][]*github.IssueComment
+	// comment id => reactions
+	reactions map[int64][]string
+	// sha => context => state
+	statuses map[string]map[string]string
+	// sha => app name => check suite
+	checkSuites map[string]map[string]*github.CheckSuite
+	// sha => check runs
+	checkRuns map[string][]*github.CheckRun
+	// number => submitted reviews
+	reviews map[int][]*github.PullRequestReview
+	// branch => protection
+	protections map[string]*github.Protection
+	// user => the permission to the repository ("none" if it's not set)
+	permissions map[string]string
+	// sha => patch which the commit introduces
+	patches map[string]string
+}
+
+type PullRequest struct {
+	Number    int
+	Title     string
+	Body      string
+	Draft     bool
+	User      string
+	HeadRef   string
+	HeadSHA   string
+	BaseRef   string
+	State     string
+	Merged    bool
+	Mergeable bool
+	Assignees []string
+	Files     []string
+
+	// The sha of `refs/pull/<number>/merge`.
+	MergeSHA string
+}
+
+func NewServer() *Server {
+	s := &Server{
+		repos: make(map[string]*Repository),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// SetLogin sets the account which calls the API.
+func (s *Server) SetLogin(login string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.login = login
+}
+
+// Client returns the client which sends requests to this server.
+func (s *Server) Client() *github.Client {
+	client := github.NewClient(nil)
+	u, _ := url.Parse(s.server.URL + "/")
+	client.BaseURL = u
+	client.UploadURL = u
+	return client
+}
+
+// Unhandled returns requests which this server does not support.
+func (s *Server) Unhandled() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return append([]string(nil), s.unhandled...)
+}
+
+// NewSHA returns a new unique commit hash.
+func (s *Server) NewSHA() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.newSHA()
+}
+
+func (s *Server) newSHA() string {
+	s.sequence++
+	return fmt.Sprintf("%040x", s.sequence)
+}
+
+func (s *Server) AddRepository(owner, name, defaultBranch string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repos[owner+"/"+name] = &Repository{
+		Owner:         owner,
+		Name:          name,
+		DefaultBranch: defaultBranch,
+		refs: map[string]string{
+			"heads/" + defaultBranch: s.newSHA(),
+		},
+		files:        make(map[string]string),
+		pullRequests: make(map[int]*PullRequest),
+		labels:       make(map[int][]string),
+		updatedAt:    make(map[int]time.Time),
+		comments:     make(map[int][]*github.IssueComment),
+		reactions:    make(map[int64][]string),
+		statuses:     make(map[string]map[string]string),
+		checkSuites:  make(map[string]map[string]*github.CheckSuite),
+		checkRuns:    make(map[string][]*github.CheckRun),
+		reviews:      make(map[int][]*github.PullRequestReview),
+		protections:  make(map[string]*github.Protection),
+		permissions:  make(map[string]string),
+		patches:      make(map[string]string),
+	}
+}
+
+func (s *Server) SetFile(owner, name, path, content string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).files[path] = content
+}
+
+// AddPullRequest adds an opened and mergeable pull request `<user>:<headRef>` into the default branch.
+func (s *Server) AddPullRequest(owner, name string, number int, user, headRef string) *PullRequest {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	pr := &PullRequest{
+		Number:    number,
+		User:      user,
+		HeadRef:   headRef,
+		HeadSHA:   s.newSHA(),
+		BaseRef:   r.DefaultBranch,
+		State:     "open",
+		Mergeable: true,
+		MergeSHA:  s.newSHA(),
+	}
+	r.pullRequests[number] = pr
+	r.refs["heads/"+headRef] = pr.HeadSHA
+
+	copied := *pr
+	return &copied
+}
+
+// PushToPullRequest pushes a new commit to the pull request and returns the new head.
+func (s *Server) PushToPullRequest(owner, name string, number int) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	pr := r.pullRequests[number]
+	pr.HeadSHA = s.newSHA()
+	pr.MergeSHA = s.newSHA()
+	r.refs["heads/"+pr.HeadRef] = pr.HeadSHA
+	return pr.HeadSHA
+}
+
+// SetPullRequestState changes the state of the pull request to `state` ("open" or "closed").
+func (s *Server) SetPullRequestState(owner, name string, number int, state string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).pullRequests[number].State = state
+}
+
+// SetTitle changes the title of the pull request.
+func (s *Server) SetTitle(owner, name string, number int, title string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).pullRequests[number].Title = title
+}
+
+// SetBody changes the description of the pull request.
+func (s *Server) SetBody(owner, name string, number int, body string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).pullRequests[number].Body = body
+}
+
+// SetDraft changes whether the pull request is a draft.
+func (s *Server) SetDraft(owner, name string, number int, draft bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).pullRequests[number].Draft = draft
+}
+
+// AddReview submits the review for the current head of the pull request.
+// `state` is "APPROVED", "CHANGES_REQUESTED", "COMMENTED" or "DISMISSED".
+func (s *Server) AddReview(owner, name string, number int, user, state string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	s.sequence++
+	r.reviews[number] = append(r.reviews[number], &github.PullRequestReview{
+		ID:       github.Int64(int64(s.sequence)),
+		User:     &github.User{Login: github.String(user)},
+		State:    github.String(state),
+		CommitID: github.String(r.pullRequests[number].HeadSHA),
+	})
+}
+
+// SetPermission sets the permission of `user` to the repository (e.g. "write").
+func (s *Server) SetPermission(owner, name, user, permission string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).permissions[user] = permission
+}
+
+// SetPatch sets the patch of `README.md` which `sha` introduces against any base.
+// If it's not set, each commit has the distinct patch.
+func (s *Server) SetPatch(owner, name, sha, patch string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).patches[sha] = patch
+}
+
+// SetBranchProtection protects `branch` by `protection`. nil removes the protection.
+func (s *Server) SetBranchProtection(owner, name, branch string, protection *github.Protection) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	if protection == nil {
+		delete(r.protections, branch)
+		return
+	}
+	r.protections[branch] = protection
+}
+
+// SetMergeable changes whether the pull request can be merged into its base.
+func (s *Server) SetMergeable(owner, name string, number int, mergeable bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).pullRequests[number].Mergeable = mergeable
+}
+
+// PushToBranch pushes a new commit to `branch` and returns the new head.
+func (s *Server) PushToBranch(owner, name, branch string) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sha := s.newSHA()
+	s.repo(owner, name).refs["heads/"+branch] = sha
+	return sha
+}
+
+// PullRequest returns the copy of the current state of the pull request.
+func (s *Server) PullRequest(owner, name string, number int) *PullRequest {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	pr, ok := s.repo(owner, name).pullRequests[number]
+	if !ok {
+		return nil
+	}
+
+	copied := *pr
+	return &copied
+}
+
+// PullRequestPayload returns the pull request as same as the payload of `pull_request` webhook.
+func (s *Server) PullRequestPayload(owner, name string, number int) *github.PullRequest {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	return s.pullRequestJSON(r, r.pullRequests[number])
+}
+
+func (s *Server) SetLabels(owner, name string, number int, labels []string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	r.labels[number] = append([]string(nil), labels...)
+	r.updatedAt[number] = time.Now()
+}
+
+// SetUpdatedAt overwrites the last time when the issue was updated.
+func (s *Server) SetUpdatedAt(owner, name string, number int, t time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.repo(owner, name).updatedAt[number] = t
+}
+
+func (s *Server) Labels(owner, name string, number int) []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return append([]string(nil), s.repo(owner, name).labels[number]...)
+}
+
+func (s *Server) AddRepositoryLabel(owner, name, label, color, description string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	r.repoLabels = append(r.repoLabels, &github.Label{
+		Name:        github.String(label),
+		Color:       github.String(color),
+		Description: github.String(description),
+	})
+}
+
+// RepositoryLabels returns the labels defined in the repository.
+func (s *Server) RepositoryLabels(owner, name string) []*github.Label {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	list := make([]*github.Label, 0)
+	for _, l := range s.repo(owner, name).repoLabels {
+		copied := *l
+		list = append(list, &copied)
+	}
+	return list
+}
+
+// AddComment creates the comment by `user` and returns its id.
+func (s *Server) AddComment(owner, name string, number int, user, body string) int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	s.sequence++
+	comment := &github.IssueComment{
+		ID:   github.Int64(int64(s.sequence)),
+		User: &github.User{Login: github.String(user)},
+		Body: github.String(body),
+	}
+	r.comments[number] = append(r.comments[number], comment)
+	return comment.GetID()
+}
+
+func (s *Server) Comments(owner, name string, number int) []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	list := make([]string, 0)
+	for _, c := range s.repo(owner, name).comments[number] {
+		list = append(list, c.GetBody())
+	}
+	return list
+}
+
+// Reactions returns the reactions to the issue comment.
+func (s *Server) Reactions(owner, name string, id int64) []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return append([]string(nil), s.repo(owner, name).reactions[id]...)
+}
+
+// Ref returns the sha pointed by `ref` (e.g. `heads/master`). This returns "" if there is none.
+func (s *Server) Ref(owner, name, ref string) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.repo(owner, name).refs[ref]
+}
+
+// SetStatus creates a commit status for `sha`.
+func (s *Server) SetStatus(owner, name, sha, context, state string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	if r.statuses[sha] == nil {
+		r.statuses[sha] = make(map[string]string)
+	}
+	r.statuses[sha][context] = state
+}
+
+// SetCheckSuite creates or updates the check suite of `app` for `sha`.
+// `conclusion` is ignored unless `status` is "completed".
+func (s *Server) SetCheckSuite(owner, name, sha, app, status, conclusion string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.repo(owner, name)
+	if r.checkSuites[sha] == nil {
+		r.checkSuites[sha] = make(map[string]*github.CheckSuite)
+	}
+
+	suite := &github.CheckSuite{
+		HeadSHA: github.String(sha),
+		Status:  github.String(status),
+		App: &github.App{
+			Name: github.String(app),
+		},
+	}
+	if status == "completed" {
+		suite.Conclusion = github.String(conclusion)
+	}
+	r.checkSuites[sha][app] = suite
+}
+
+// repo must be called with holding the lock.
+func (s *Server) repo(owner, name string) *Repository {
+	r, ok := s.repos[owner+"/"+name]
+	if !ok {
+		panic(fmt.Sprintf("%v/%v is not added to the fake server", owner, name))
+	}
+	return r
+}
+
+func (s *Server) serveHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if strings.HasPrefix(req.URL.Path, "/raw/") {
+		s.serveRaw(rw, req)
+		return
+	}
+
+	// `/repos/<owner>/<name>/...`
+	p := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(p) < 3 || p[0] != "repos" {
+		s.notFound(rw, req)
+		return
+	}
+
+	r, ok := s.repos[p[1]+"/"+p[2]]
+	if !ok {
+		s.notFound(rw, req)
+		return
+	}
+
+	rest := p[3:]
+	switch {
+	case len(rest) == 0 && req.Method == "GET":
+		writeJSON(rw, http.StatusOK, s.repositoryJSON(r))
+	case len(rest) >= 1 && rest[0] == "contents" && req.Method == "GET":
+		s.serveContents(rw, req, r, strings.Join(rest[1:], "/"))
+	case len(rest) >= 2 && rest[0] == "git" && rest[1] == "refs":
+		s.serveRefs(rw, req, r, strings.Join(rest[2:], "/"))
+	case len(rest) >= 1 && rest[0] == "pulls":
+		s.servePulls(rw, req, r, rest[1:])
+	case len(rest) >= 1 && rest[0] == "issues":
+		s.serveIssues(rw, req, r, rest[1:])
+	case len(rest) == 2 && rest[0] == "compare" && req.Method == "GET":
+		s.serveCompare(rw, req, r, rest[1])
+	case len(rest) == 3 && rest[0] == "commits" && req.Method == "GET":
+		s.serveCommitStatus(rw, req, r, rest[1], rest[2])
+	case len(rest) >= 1 && rest[0] == "labels":
+		s.serveRepositoryLabels(rw, req, r, rest[1:])
+	case len(rest) == 3 && rest[0] == "collaborators" && rest[2] == "permission" && req.Method == "GET":
+		permission, ok := r.permissions[rest[1]]
+		if !ok {
+			permission = "none"
+		}
+		writeJSON(rw, http.StatusOK, &github.RepositoryPermissionLevel{
+			Permission: github.String(permission),
+			User:       &github.User{Login: github.String(rest[1])},
+		})
+	case len(rest) >= 3 && rest[0] == "branches" && rest[len(rest)-1] == "protection" && req.Method == "GET":
+		branch := strings.Join(rest[1:len(rest)-1], "/")
+		if p, ok := r.protections[branch]; ok {
+			writeJSON(rw, http.StatusOK, p)
+		} else {
+			writeJSON(rw, http.StatusNotFound, map[string]string{"message": "Branch not protected"})
+		}
+	default:
+		s.notFound(rw, req)
+	}
+}
+
+func (s *Server) notFound(rw http.ResponseWriter, req *http.Request) {
+	m := req.Method + " " + req.URL.Path
+	log.Printf("debug: fakegithub: unhandled request: %v\n", m)
+	s.unhandled = append(s.unhandled, m)
+	writeJSON(rw, http.StatusNotFound, map[string]string{"message": "Not Found"})
+}
+
+func (s *Server) serveRaw(rw http.ResponseWriter, req *http.Request) {
+	// `/raw/<owner>/<name>/<path>`
+	p := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/raw/"), "/", 3)
+	if len(p) != 3 {
+		s.notFound(rw, req)
+		return
+	}
+
+	r, ok := s.repos[p[0]+"/"+p[1]]
+	if !ok {
+		s.notFound(rw, req)
+		return
+	}
+
+	content, ok := r.files[p[2]]
+	if !ok {
+		s.notFound(rw, req)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(content))
+}
+
+func (s *Server) serveContents(rw http.ResponseWriter, req *http.Request, r *Repository, dir string) {
+	if ref := req.URL.Query().Get("ref"); ref != "" && ref != r.DefaultBranch {
+		s.notFound(rw, req)
+		return
+	}
+
+	dir = strings.Trim(dir, "/")
+	if dir == "." {
+		dir = ""
+	}
+	list := make([]*github.RepositoryContent, 0)
+	for path := range r.files {
+		parent := ""
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			parent = path[:i]
+		}
+		if parent != dir {
+			continue
+		}
+
+		list = append(list, &github.RepositoryContent{
+			Type:        github.String("file"),
+			Name:        github.String(pathpkg.Base(path)),
+			Path:        github.String(path),
+			DownloadURL: github.String(fmt.Sprintf("%v/raw/%v/%v/%v", s.server.URL, r.Owner, r.Name, path)),
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].GetPath() < list[j].GetPath()
+	})
+	writeJSON(rw, http.StatusOK, list)
+}
+
+func (s *Server) serveRefs(rw http.ResponseWriter, req *http.Request, r *Repository, ref string) {
+	switch req.Method {
+	case "GET":
+		sha, ok := s.resolveRef(r, ref)
+		if !ok {
+			s.notFound(rw, req)
+			return
+		}
+		writeJSON(rw, http.StatusOK, refJSON("refs/"+ref, sha))
+	case "POST":
+		var body struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+
+		name := strings.TrimPrefix(body.Ref, "refs/")
+		if _, ok := r.refs[name]; ok {
+			writeJSON(rw, http.StatusUnprocessableEntity, map[string]string{"message": "Reference already exists"})
+			return
+		}
+
+		r.refs[name] = body.SHA
+		writeJSON(rw, http.StatusCreated, refJSON(body.Ref, body.SHA))
+	case "DELETE":
+		if _, ok := r.refs[ref]; !ok {
+			writeJSON(rw, http.StatusUnprocessableEntity, map[string]string{"message": "Reference does not exist"})
+			return
+		}
+
+		delete(r.refs, ref)
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		s.notFound(rw, req)
+	}
+}
+
+// resolveRef resolves `heads/<branch>` or `pull/<number>/merge`.
+func (s *Server) resolveRef(r *Repository, ref string) (string, bool) {
+	if sha, ok := r.refs[ref]; ok {
+		return sha, true
+	}
+
+	p := strings.Split(ref, "/")
+	if len(p) == 3 && p[0] == "pull" && p[2] == "merge" {
+		number, _ := strconv.Atoi(p[1])
+		if pr, ok := r.pullRequests[number]; ok && pr.State == "open" {
+			return pr.MergeSHA, true
+		}
+	}
+
+	return "", false
+}
+
+func (s *Server) servePulls(rw http.ResponseWriter, req *http.Request, r *Repository, rest []string) {
+	if len(rest) == 0 {
+		if req.Method != "GET" {
+			s.notFound(rw, req)
+			return
+		}
+
+		state := req.URL.Query().Get("state")
+		base := req.URL.Query().Get("base")
+		list := make([]*github.PullRequest, 0)
+		for _, number := range sortedNumbers(r.pullRequests) {
+			pr := r.pullRequests[number]
+			if state != "all" && pr.State != "open" {
+				continue
+			}
+			if base != "" && pr.BaseRef != base {
+				continue
+			}
+
+			// GitHub does not return `mergeable` for the list.
+			item := s.pullRequestJSON(r, pr)
+			item.Mergeable = nil
+			list = append(list, item)
+		}
+		writeJSON(rw, http.StatusOK, list)
+		return
+	}
+
+	number, err := strconv.Atoi(rest[0])
+	pr, ok := r.pullRequests[number]
+	if err != nil || !ok {
+		s.notFound(rw, req)
+		return
+	}
+
+	switch {
+	case len(rest) == 1 && req.Method == "GET":
+		writeJSON(rw, http.StatusOK, s.pullRequestJSON(r, pr))
+	case len(rest) == 2 && rest[1] == "files" && req.Method == "GET":
+		list := make([]*github.CommitFile, 0, len(pr.Files))
+		for _, f := range pr.Files {
+			list = append(list, &github.CommitFile{Filename: github.String(f)})
+		}
+		writeJSON(rw, http.StatusOK, list)
+	case len(rest) == 2 && rest[1] == "reviews" && req.Method == "GET":
+		list := r.reviews[number]
+		if list == nil {
+			list = make([]*github.PullRequestReview, 0)
+		}
+		writeJSON(rw, http.StatusOK, list)
+	case len(rest) == 2 && rest[1] == "merge" && req.Method == "PUT":
+		s.merge(rw, req, r, pr)
+	case len(rest) == 2 && rest[1] == "requested_reviewers" && req.Method == "POST":
+		writeJSON(rw, http.StatusCreated, s.pullRequestJSON(r, pr))
+	default:
+		s.notFound(rw, req)
+	}
+}
+
+func (s *Server) merge(rw http.ResponseWriter, req *http.Request, r *Repository, pr *PullRequest) {
+	var body struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	if pr.State != "open" || !pr.Mergeable {
+		writeJSON(rw, http.StatusMethodNotAllowed, map[string]string{"message": "Pull Request is not mergeable"})
+		return
+	}
+
+	if body.SHA != "" && body.SHA != pr.HeadSHA {
+		writeJSON(rw, http.StatusConflict, map[string]string{"message": "Head branch was modified. Review and try the merge again."})
+		return
+	}
+
+	sha := s.newSHA()
+	r.refs["heads/"+pr.BaseRef] = sha
+	pr.State = "closed"
+	pr.Merged = true
+
+	writeJSON(rw, http.StatusOK, &github.PullRequestMergeResult{
+		SHA:     github.String(sha),
+		Merged:  github.Bool(true),
+		Message: github.String("Pull Request successfully merged"),
+	})
+}
+
+func (s *Server) serveIssues(rw http.ResponseWriter, req *http.Request, r *Repository, rest []string) {
+	if len(rest) == 0 {
+		if req.Method != "GET" {
+			s.notFound(rw, req)
+			return
+		}
+
+		s.listIssues(rw, req, r)
+		return
+	}
+
+	if rest[0] == "comments" {
+		s.serveIssueComments(rw, req, r, rest[1:])
+		return
+	}
+
+	number, err := strconv.Atoi(rest[0])
+	if err == nil && len(rest) == 3 && rest[1] == "labels" && req.Method == "DELETE" {
+		label := rest[2]
+		if !containsAll(r.labels[number], []string{label}) {
+			s.notFound(rw, req)
+			return
+		}
+
+		labels := make([]string, 0)
+		for _, l := range r.labels[number] {
+			if l != label {
+				labels = append(labels, l)
+			}
+		}
+		r.labels[number] = labels
+		r.updatedAt[number] = time.Now()
+		writeJSON(rw, http.StatusOK, labelsJSON(labels))
+		return
+	}
+
+	if err != nil || len(rest) != 2 {
+		s.notFound(rw, req)
+		return
+	}
+
+	switch {
+	case rest[1] == "labels" && req.Method == "GET":
+		writeJSON(rw, http.StatusOK, labelsJSON(r.labels[number]))
+	case rest[1] == "labels" && req.Method == "PUT":
+		var labels []string
+		if err := json.NewDecoder(req.Body).Decode(&labels); err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		r.labels[number] = labels
+		r.updatedAt[number] = time.Now()
+		writeJSON(rw, http.StatusOK, labelsJSON(labels))
+	case rest[1] == "labels" && req.Method == "POST":
+		var labels []string
+		if err := json.NewDecoder(req.Body).Decode(&labels); err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		for _, l := range labels {
+			if !containsAll(r.labels[number], []string{l}) {
+				r.labels[number] = append(r.labels[number], l)
+			}
+		}
+		r.updatedAt[number] = time.Now()
+		writeJSON(rw, http.StatusOK, labelsJSON(r.labels[number]))
+	case rest[1] == "comments" && req.Method == "POST":
+		var comment github.IssueComment
+		if err := json.NewDecoder(req.Body).Decode(&comment); err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		s.sequence++
+		comment.ID = github.Int64(int64(s.sequence))
+		comment.User = &github.User{Login: github.String(s.login)}
+		r.comments[number] = append(r.comments[number], &comment)
+		writeJSON(rw, http.StatusCreated, &comment)
+	case rest[1] == "comments" && req.Method == "GET":
+		list := r.comments[number]
+		if list == nil {
+			list = make([]*github.IssueComment, 0)
+		}
+		writeJSON(rw, http.StatusOK, list)
+	case rest[1] == "assignees" && req.Method == "POST":
+		pr, ok := r.pullRequests[number]
+		if !ok {
+			s.notFound(rw, req)
+			return
+		}
+
+		var body struct {
+			Assignees []string `json:"assignees"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		pr.Assignees = append(pr.Assignees, body.Assignees...)
+		writeJSON(rw, http.StatusCreated, s.issueJSON(r, number))
+	default:
+		s.notFound(rw, req)
+	}
+}
+
+func (s *Server) serveIssueComments(rw http.ResponseWriter, req *http.Request, r *Repository, rest []string) {
+	if len(rest) == 0 {
+		s.notFound(rw, req)
+		return
+	}
+
+	id, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil {
+		s.notFound(rw, req)
+		return
+	}
+
+	if len(rest) == 1 && req.Method == "PATCH" {
+		s.editComment(rw, req, r, id)
+		return
+	}
+
+	if len(rest) != 2 || rest[1] != "reactions" || req.Method != "POST" {
+		s.notFound(rw, req)
+		return
+	}
+
+	var reaction github.Reaction
+	if err := json.NewDecoder(req.Body).Decode(&reaction); err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	r.reactions[id] = append(r.reactions[id], reaction.GetContent())
+	writeJSON(rw, http.StatusCreated, &reaction)
+}
+
+func (s *Server) editComment(rw http.ResponseWriter, req *http.Request, r *Repository, id int64) {
+	var body github.IssueComment
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	for _, list := range r.comments {
+		for _, c := range list {
+			if c.GetID() == id {
+				c.Body = body.Body
+				writeJSON(rw, http.StatusOK, c)
+				return
+			}
+		}
+	}
+
+	s.notFound(rw, req)
+}
+
+func (s *Server) serveRepositoryLabels(rw http.ResponseWriter, req *http.Request, r *Repository, rest []string) {
+	switch {
+	case len(rest) == 0 && req.Method == "GET":
+		writeJSON(rw, http.StatusOK, r.repoLabels)
+	case len(rest) == 0 && req.Method == "POST":
+		var label github.Label
+		if err := json.NewDecoder(req.Body).Decode(&label); err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		r.repoLabels = append(r.repoLabels, &label)
+		writeJSON(rw, http.StatusCreated, &label)
+	case len(rest) >= 1 && req.Method == "PATCH":
+		var label github.Label
+		if err := json.NewDecoder(req.Body).Decode(&label); err != nil {
+			writeJSON(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+
+		for i, l := range r.repoLabels {
+			// The escaped name (e.g. `status%2Fqueued`) has been split.
+			if strings.EqualFold(l.GetName(), strings.Join(rest, "/")) {
+				r.repoLabels[i] = &label
+				writeJSON(rw, http.StatusOK, &label)
+				return
+			}
+		}
+		s.notFound(rw, req)
+	default:
+		s.notFound(rw, req)
+	}
+}
+
+func (s *Server) listIssues(rw http.ResponseWriter, req *http.Request, r *Repository) {
+	var required []string
+	if v := req.URL.Query().Get("labels"); v != "" {
+		required = strings.Split(v, ",")
+	}
+
+	list := make([]*github.Issue, 0)
+	for _, number := range sortedNumbers(r.pullRequests) {
+		if r.pullRequests[number].State != "open" {
+			continue
+		}
+
+		if !containsAll(r.labels[number], required) {
+			continue
+		}
+		list = append(list, s.issueJSON(r, number))
+	}
+	writeJSON(rw, http.StatusOK, list)
+}
+
+// serveCompare serves `compare/<base>...<head>`.
+func (s *Server) serveCompare(rw http.ResponseWriter, req *http.Request, r *Repository, basehead string) {
+	p := strings.SplitN(basehead, "...", 2)
+	if len(p) != 2 {
+		s.notFound(rw, req)
+		return
+	}
+
+	head := p[1]
+	patch, ok := r.patches[head]
+	if !ok {
+		patch = "@@ -1 +1 @@\n+" + head
+	}
+
+	writeJSON(rw, http.StatusOK, &github.CommitsComparison{
+		Status: github.String("ahead"),
+		Files: []github.CommitFile{
+			github.CommitFile{
+				Filename: github.String("README.md"),
+				Status:   github.String("modified"),
+				Patch:    github.String(patch),
+			},
+		},
+	})
+}
+
+func (s *Server) serveCommitStatus(rw http.ResponseWriter, req *http.Request, r *Repository, ref string, kind string) {
+	sha, ok := s.resolveRef(r, "heads/"+ref)
+	if !ok {
+		sha = ref
+	}
+
+	switch kind {
+	case "status":
+		statuses := make([]github.RepoStatus, 0)
+		state := "pending"
+		if len(r.statuses[sha]) > 0 {
+			state = "success"
+		}
+
+		for _, context := range sortedKeys(r.statuses[sha]) {
+			v := r.statuses[sha][context]
+			statuses = append(statuses, github.RepoStatus{
+				Context: github.String(context),
+				State:   github.String(v),
+			})
+
+			switch {
+			case v == "failure" || v == "error":
+				state = "failure"
+			case v == "pending" && state != "failure":
+				state = "pending"
+			}
+		}
+
+		writeJSON(rw, http.StatusOK, &github.CombinedStatus{
+			State:      github.String(state),
+			SHA:        github.String(sha),
+			TotalCount: github.Int(len(statuses)),
+			Statuses:   statuses,
+		})
+	case "check-suites":
+		suites := make([]*github.CheckSuite, 0)
+		for _, app := range sortedKeys(r.checkSuites[sha]) {
+			suites = append(suites, r.checkSuites[sha][app])
+		}
+		writeJSON(rw, http.StatusOK, &github.ListCheckSuiteResults{
+			Total:       github.Int(len(suites)),
+			CheckSuites: suites,
+		})
+	case "check-runs":
+		runs := r.checkRuns[sha]
+		if runs == nil {
+			runs = make([]*github.CheckRun, 0)
+		}
+		writeJSON(rw, http.StatusOK, &github.ListCheckRunsResults{
+			Total:     github.Int(len(runs)),
+			CheckRuns: runs,
+		})
+	default:
+		s.notFound(rw, req)
+	}
+}
+
+func (s *Server) repositoryJSON(r *Repository) *github.Repository {
+	return &github.Repository{
+		Name:          github.String(r.Name),
+		FullName:      github.String(r.Owner + "/" + r.Name),
+		DefaultBranch: github.String(r.DefaultBranch),
+		Owner: &github.User{
+			Login: github.String(r.Owner),
+		},
+	}
+}
+
+func (s *Server) pullRequestJSON(r *Repository, pr *PullRequest) *github.PullRequest {
+	assignees := make([]*github.User, 0, len(pr.Assignees))
+	for _, a := range pr.Assignees {
+		assignees = append(assignees, &github.User{Login: github.String(a)})
+	}
+
+	return &github.PullRequest{
+		Number:    github.Int(pr.Number),
+		Title:     github.String(pr.Title),
+		Body:      github.String(pr.Body),
+		Draft:     github.Bool(pr.Draft),
+		State:     github.String(pr.State),
+		Merged:    github.Bool(pr.Merged),
+		Mergeable: github.Bool(pr.Mergeable),
+		User: &github.User{
+			Login: github.String(pr.User),
+		},
+		Assignees: assignees,
+		Labels:    labelsJSON(r.labels[pr.Number]),
+		Head: &github.PullRequestBranch{
+			Ref:  github.String(pr.HeadRef),
+			SHA:  github.String(pr.HeadSHA),
+			Repo: s.repositoryJSON(r),
+		},
+		Base: &github.PullRequestBranch{
+			Ref:  github.String(pr.BaseRef),
+			SHA:  github.String(r.refs["heads/"+pr.BaseRef]),
+			Repo: s.repositoryJSON(r),
+		},
+	}
+}
+
+func (s *Server) issueJSON(r *Repository, number int) *github.Issue {
+	labels := make([]github.Label, 0)
+	for _, l := range labelsJSON(r.labels[number]) {
+		labels = append(labels, *l)
+	}
+
+	issue := &github.Issue{
+		Number: github.Int(number),
+		State:  github.String("open"),
+		Labels: labels,
+	}
+	if t, ok := r.updatedAt[number]; ok {
+		issue.UpdatedAt = &t
+	}
+	if pr, ok := r.pullRequests[number]; ok {
+		issue.State = github.String(pr.State)
+		issue.User = &github.User{Login: github.String(pr.User)}
+		issue.PullRequestLinks = &github.PullRequestLinks{
+			URL: github.String(fmt.Sprintf("%v/repos/%v/%v/pulls/%v", s.server.URL, r.Owner, r.Name, number)),
+		}
+	}
+	return issue
+}
+
+func refJSON(ref, sha string) *github.Reference {
+	return &github.Reference{
+		Ref: github.String(ref),
+		Object: &github.GitObject{
+			Type: github.String("commit"),
+			SHA:  github.String(sha),
+		},
+	}
+}
+
+func labelsJSON(labels []string) []*github.Label {
+	list := make([]*github.Label, 0, len(labels))
+	for _, l := range labels {
+		list = append(list, &github.Label{Name: github.String(l)})
+	}
+	return list
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
+
+func sortedNumbers(m map[int]*PullRequest) []int {
+	list := make([]int, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Ints(list)
+	return list
+}
+
+func sortedKeys(m interface{}) []string {
+	list := make([]string, 0)
+	switch m := m.(type) {
+	case map[string]string:
+		for k := range m {
+			list = append(list, k)
+		}
+	case map[string]*github.CheckSuite:
+		for k := range m {
+			list = append(list, k)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+func containsAll(list []string, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, v := range list {
+			if v == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
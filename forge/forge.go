@@ -0,0 +1,304 @@
+// Package forge abstracts the hosting service of repositories (e.g. GitHub or Gitea)
+// so that commands and the auto merge queue work on any of them.
+package forge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type Repository struct {
+	Owner string
+	Name  string
+	// This may be empty if the event does not contain it.
+	DefaultBranch string
+}
+
+// Repo returns the repository which the event comes from.
+func (r Repository) Repo() Repository {
+	return r
+}
+
+type PullRequest struct {
+	Number int
+	Title  string
+	Body   string
+	// "open" or "closed"
+	State  string
+	Draft  bool
+	Merged bool
+	// This is nil while the forge is checking whether the pull request is mergeable.
+	Mergeable *bool
+	// This is true if `Mergeable` is false but the forge may be still checking it
+	// (Gitea reports false also while checking conflicts).
+	MergeableUnsettled bool
+	User               string
+
+	HeadRef string
+	HeadSHA string
+	// The repository of the head branch. This differs from the base one if the pull request comes from a fork.
+	HeadOwner string
+	HeadName  string
+
+	BaseRef   string
+	BaseOwner string
+
+	Labels             []string
+	Assignees          []string
+	RequestedReviewers []string
+	RequestedTeams     []string
+}
+
+type Issue struct {
+	Number        int
+	User          string
+	IsPullRequest bool
+	Labels        []string
+	// The last time when the issue (including its labels) was updated.
+	UpdatedAt time.Time
+}
+
+type Comment struct {
+	ID   int64
+	User string
+	Body string
+}
+
+// Review is a submitted review of a pull request.
+type Review struct {
+	User string
+	// "approved", "changes_requested", "commented" or "dismissed".
+	State    string
+	CommitID string
+}
+
+// FileDiff is the change of a file between two commits.
+type FileDiff struct {
+	Filename string
+	// "added", "removed", "modified" or "renamed".
+	Status string
+	// The unified diff. This is empty if the forge does not provide it (e.g. a binary file).
+	Patch string
+	// The blob sha of the file after the change.
+	SHA string
+}
+
+// BranchProtection is the rules which the forge requires to merge into a protected branch.
+type BranchProtection struct {
+	// The number of approving reviews. 0 if reviews are not required.
+	RequiredApprovingReviews int
+	// Approving reviews for older commits than the head are not counted.
+	DismissStaleReviews bool
+	// Contexts of commit statuses (or names of check runs) which must succeed.
+	RequiredStatusChecks []string
+}
+
+type CommitStatus struct {
+	Context     string
+	State       string
+	Description string
+	TargetURL   string
+}
+
+// Label is the label defined in the repository.
+type Label struct {
+	Name string
+	// The hex color code without `#` (e.g. "0e8a16").
+	Color       string
+	Description string
+}
+
+type CombinedStatus struct {
+	// "pending", "success", "failure" or "error".
+	State    string
+	Statuses []*CommitStatus
+}
+
+type CheckSuite struct {
+	App        string
+	Status     string
+	Conclusion string
+}
+
+type CheckRun struct {
+	Name       string
+	Status     string
+	Conclusion string
+}
+
+// Client is the API of the forge which this bot uses.
+type Client interface {
+	GetDefaultBranch(ctx context.Context, owner, name string) (string, error)
+	// GetFile returns the content of `path` in `ref`.
+	GetFile(ctx context.Context, owner, name, path, ref string) ([]byte, error)
+
+	// AddComment returns the id of the created comment.
+	AddComment(ctx context.Context, owner, name string, number int, body string) (int64, error)
+	EditComment(ctx context.Context, owner, name string, id int64, body string) error
+	ListComments(ctx context.Context, owner, name string, number int) ([]*Comment, error)
+	// AddReaction adds the reaction (e.g. `ReactionOK`) to the comment.
+	AddReaction(ctx context.Context, owner, name string, kind CommentKind, id int64, reaction string) error
+	// GetLabels returns labels of the issue. This returns the non-nil slice on success.
+	GetLabels(ctx context.Context, owner, name string, number int) ([]string, error)
+	ReplaceLabels(ctx context.Context, owner, name string, number int, labels []string) error
+	// AddLabels and RemoveLabel change only the given labels of the issue.
+	// Use them instead of ReplaceLabels where other handlers may change labels concurrently.
+	AddLabels(ctx context.Context, owner, name string, number int, labels []string) error
+	RemoveLabel(ctx context.Context, owner, name string, number int, label string) error
+	AddAssignees(ctx context.Context, owner, name string, number int, users []string) error
+	ListOpenIssuesWithLabel(ctx context.Context, owner, name, label string) ([]*Issue, error)
+	ListRepositoryLabels(ctx context.Context, owner, name string) ([]*Label, error)
+	CreateLabel(ctx context.Context, owner, name string, label *Label) error
+	// UpdateLabel changes the label named `current` in the repository to `label`.
+	UpdateLabel(ctx context.Context, owner, name, current string, label *Label) error
+
+	GetPullRequest(ctx context.Context, owner, name string, number int) (*PullRequest, error)
+	// ListOpenPullRequests returns open pull requests into `base`. If `base` is empty, this returns all of them.
+	// `Mergeable` of the returned ones may be nil even if it has been computed.
+	ListOpenPullRequests(ctx context.Context, owner, name, base string) ([]*PullRequest, error)
+	ListChangedFiles(ctx context.Context, owner, name string, number int) ([]string, error)
+	RequestTeamReview(ctx context.Context, owner, name string, number int, team string) error
+	// CompareCommits returns changed files from the merge base of `base` and `head` to `head`.
+	// This returns ErrUnsupportedCompare if the forge does not provide patches of them.
+	CompareCommits(ctx context.Context, owner, name, base, head string) ([]*FileDiff, error)
+	// ListReviews returns submitted reviews of the pull request in the order of submission.
+	ListReviews(ctx context.Context, owner, name string, number int) ([]*Review, error)
+	// GetPermission returns the permission of `user` to the repository:
+	// "admin", "write", "read" or "none" ("owner" is also returned by Gitea).
+	GetPermission(ctx context.Context, owner, name, user string) (string, error)
+	// GetBranchProtection returns nil if `branch` is not protected
+	// (or the forge does not tell it to this bot).
+	GetBranchProtection(ctx context.Context, owner, name, branch string) (*BranchProtection, error)
+	// MergePullRequest merges the pull request only if its head is `sha`.
+	MergePullRequest(ctx context.Context, owner, name string, number int, sha string) error
+
+	// CreateTryBranch (re)creates `branch` to test the pull request with its base branch,
+	// and returns the sha of the branch.
+	// This returns ErrUnsupportedTryBranch if the forge cannot merge them.
+	CreateTryBranch(ctx context.Context, owner, name string, number int, branch string) (string, error)
+	// SupportsTryBranch returns false if CreateTryBranch always returns ErrUnsupportedTryBranch.
+	// Auto-Merging is not available on such a forge.
+	SupportsTryBranch() bool
+	DeleteBranch(ctx context.Context, owner, name, branch string) error
+
+	GetCombinedStatus(ctx context.Context, owner, name, ref string) (*CombinedStatus, error)
+	// These return the empty list if the forge does not support checks.
+	ListCheckSuites(ctx context.Context, owner, name, ref string) ([]*CheckSuite, error)
+	ListCheckRuns(ctx context.Context, owner, name, ref string) ([]*CheckRun, error)
+
+	ListTeamMembers(ctx context.Context, org, slug string) ([]string, error)
+}
+
+// Event is one of `*CommentEvent`, `*PushEvent`, `*StatusEvent`, `*PullRequestEvent` and `*ReviewEvent`.
+type Event interface {
+	Repo() Repository
+}
+
+// CommentKind tells the kind of the comment because a forge has the distinct API for each of them.
+type CommentKind int
+
+const (
+	// CommentKindIssue is a comment on the conversation of an issue or a pull request.
+	CommentKindIssue CommentKind = iota
+	// CommentKindReviewComment is a comment on the diff of a pull request.
+	CommentKindReviewComment
+	// CommentKindReview is the body of a submitted review.
+	CommentKindReview
+)
+
+// Reactions which this bot adds to a command.
+const (
+	ReactionOK         = "+1"
+	ReactionRejected   = "-1"
+	ReactionParseError = "confused"
+)
+
+// CommentEvent is a comment on an issue or a pull request (including a review comment).
+type CommentEvent struct {
+	Repository
+
+	Action        string
+	Number        int
+	IsPullRequest bool
+	// The author of the issue.
+	IssueUser string
+	Kind      CommentKind
+	CommentID int64
+	Body      string
+	Sender    string
+}
+
+type PushEvent struct {
+	Repository
+
+	// e.g. `refs/heads/master`
+	Ref string
+	// The sha of `Ref` after the push.
+	After string
+	// The URL to compare the pushed commits.
+	Compare string
+}
+
+// StatusEvent is the change of a commit status or a check suite.
+type StatusEvent struct {
+	Repository
+
+	ID  int64
+	SHA string
+	// The state of the commit status or the conclusion of the check suite.
+	State string
+	// The context of the commit status or the name of the app of the check suite.
+	Context string
+	// This is false if the state will be changed later (e.g. "pending").
+	Completed bool
+	// Branches which contain `SHA`. This is nil if the forge does not tell it.
+	Branches []string
+}
+
+type PullRequestEvent struct {
+	Repository
+
+	Action string
+	Sender string
+	// The label which is added or removed by "labeled" or "unlabeled".
+	// This is empty if the forge does not tell it (e.g. "label_updated" of Gitea).
+	Label       string
+	PullRequest *PullRequest
+}
+
+type ReviewEvent struct {
+	Repository
+
+	Action   string
+	ReviewID int64
+	// "approved", "changes_requested" or "commented".
+	State    string
+	Body     string
+	CommitID string
+	Sender   string
+
+	PullRequest *PullRequest
+}
+
+// Webhook parses deliveries from the forge.
+type Webhook interface {
+	// ValidatePayload checks the signature of the request by `secret` and returns its payload.
+	ValidatePayload(req *http.Request, secret []byte) ([]byte, error)
+	EventType(req *http.Request) string
+	DeliveryID(req *http.Request) string
+	// ParseWebhook returns ErrUnsupportedEvent if this bot does not handle `eventType`.
+	ParseWebhook(eventType string, payload []byte) (Event, error)
+}
+
+var ErrUnsupportedEvent = errors.New("unsupported event")
+
+// ErrUnsupportedCompare is returned if the forge cannot compare commits with their patches.
+var ErrUnsupportedCompare = errors.New("unsupported compare")
+
+// ErrUnsupportedComment is returned if the forge cannot react to the kind of the comment.
+var ErrUnsupportedComment = errors.New("unsupported comment")
+
+// ErrUnsupportedTryBranch is returned if the forge cannot create the branch which merges the pull request into its base branch.
+var ErrUnsupportedTryBranch = errors.New("unsupported try branch")
@@ -0,0 +1,784 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The page size for Gitea's list APIs. Gitea caps it by `MAX_RESPONSE_ITEMS` (50 by default).
+const giteaPageSize = 50
+
+type giteaClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewGiteaClient returns the client which calls Gitea API.
+// `baseURL` is the root of the Gitea instance (e.g. `https://gitea.example.com/`).
+func NewGiteaClient(baseURL, token string, client *http.Client) Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &giteaClient{
+		baseURL: strings.TrimSuffix(baseURL, "/") + "/api/v1",
+		token:   token,
+		http:    client,
+	}
+}
+
+type giteaError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *giteaError) Error() string {
+	return fmt.Sprintf("%v %v: %v %v", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// do calls the API and decodes the response into `out` if it's not nil.
+func (c *giteaClient) do(ctx context.Context, method, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var msg struct {
+			Message string `json:"message"`
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		if err := json.Unmarshal(b, &msg); err != nil || msg.Message == "" {
+			msg.Message = string(b)
+		}
+		return &giteaError{
+			Method:     method,
+			Path:       path,
+			StatusCode: res.StatusCode,
+			Message:    msg.Message,
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = ioutil.ReadAll(res.Body)
+		return err
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func repoPath(owner, name string) string {
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+}
+
+// pagePath appends the query for `page` to `path`.
+func pagePath(path string, page int) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%v%vpage=%v&limit=%v", path, sep, page, giteaPageSize)
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaLabel struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+type giteaRepository struct {
+	Name          string    `json:"name"`
+	Owner         giteaUser `json:"owner"`
+	DefaultBranch string    `json:"default_branch"`
+}
+
+type giteaBranch struct {
+	Ref  string           `json:"ref"`
+	SHA  string           `json:"sha"`
+	Repo *giteaRepository `json:"repo"`
+}
+
+type giteaTeam struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type giteaPullRequest struct {
+	Number             int          `json:"number"`
+	Title              string       `json:"title"`
+	Body               string       `json:"body"`
+	State              string       `json:"state"`
+	Merged             bool         `json:"merged"`
+	Mergeable          bool         `json:"mergeable"`
+	User               *giteaUser   `json:"user"`
+	Head               giteaBranch  `json:"head"`
+	Base               giteaBranch  `json:"base"`
+	Assignees          []*giteaUser `json:"assignees"`
+	RequestedReviewers []*giteaUser `json:"requested_reviewers"`
+	RequestedTeams     []*giteaTeam `json:"requested_reviewers_teams"`
+	Labels             []giteaLabel `json:"labels"`
+}
+
+type giteaIssue struct {
+	Number      int          `json:"number"`
+	User        *giteaUser   `json:"user"`
+	Labels      []giteaLabel `json:"labels"`
+	Assignees   []*giteaUser `json:"assignees"`
+	PullRequest *struct{}    `json:"pull_request"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+func (c *giteaClient) GetDefaultBranch(ctx context.Context, owner, name string) (string, error) {
+	var repo giteaRepository
+	if err := c.do(ctx, "GET", repoPath(owner, name), nil, &repo); err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+func (c *giteaClient) GetFile(ctx context.Context, owner, name, path, ref string) ([]byte, error) {
+	p := repoPath(owner, name) + "/raw/" + escapeFilePath(path) + "?ref=" + url.QueryEscape(ref)
+
+	var b []byte
+	if err := c.do(ctx, "GET", p, nil, &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func escapeFilePath(path string) string {
+	list := strings.Split(path, "/")
+	for i, s := range list {
+		list[i] = url.PathEscape(s)
+	}
+	return strings.Join(list, "/")
+}
+
+type giteaComment struct {
+	ID   int64      `json:"id"`
+	User *giteaUser `json:"user"`
+	Body string     `json:"body"`
+}
+
+func (c *giteaClient) AddComment(ctx context.Context, owner, name string, number int, body string) (int64, error) {
+	in := map[string]string{
+		"body": body,
+	}
+
+	var comment giteaComment
+	if err := c.do(ctx, "POST", fmt.Sprintf("%v/issues/%v/comments", repoPath(owner, name), number), in, &comment); err != nil {
+		return 0, err
+	}
+	return comment.ID, nil
+}
+
+func (c *giteaClient) EditComment(ctx context.Context, owner, name string, id int64, body string) error {
+	in := map[string]string{
+		"body": body,
+	}
+	return c.do(ctx, "PATCH", fmt.Sprintf("%v/issues/comments/%v", repoPath(owner, name), id), in, nil)
+}
+
+// ListComments returns all comments at once because Gitea does not paginate comments of an issue.
+func (c *giteaClient) ListComments(ctx context.Context, owner, name string, number int) ([]*Comment, error) {
+	var list []giteaComment
+	if err := c.do(ctx, "GET", fmt.Sprintf("%v/issues/%v/comments", repoPath(owner, name), number), nil, &list); err != nil {
+		return nil, err
+	}
+
+	result := make([]*Comment, 0, len(list))
+	for _, comment := range list {
+		result = append(result, &Comment{
+			ID:   comment.ID,
+			User: comment.User.login(),
+			Body: comment.Body,
+		})
+	}
+	return result, nil
+}
+
+func (c *giteaClient) AddReaction(ctx context.Context, owner, name string, kind CommentKind, id int64, reaction string) error {
+	// Gitea stores comments on the diff as same as the normal comments.
+	if kind == CommentKindReview {
+		return ErrUnsupportedComment
+	}
+
+	in := map[string]string{
+		"content": reaction,
+	}
+	return c.do(ctx, "POST", fmt.Sprintf("%v/issues/comments/%v/reactions", repoPath(owner, name), id), in, nil)
+}
+
+func (c *giteaClient) GetLabels(ctx context.Context, owner, name string, number int) ([]string, error) {
+	var list []giteaLabel
+	if err := c.do(ctx, "GET", fmt.Sprintf("%v/issues/%v/labels", repoPath(owner, name), number), nil, &list); err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(list))
+	for _, l := range list {
+		result = append(result, l.Name)
+	}
+	return result, nil
+}
+
+// ReplaceLabels resolves label names to their ids because Gitea accepts only ids.
+// Unlike GitHub, this fails if the repository does not have some of `labels`.
+func (c *giteaClient) ReplaceLabels(ctx context.Context, owner, name string, number int, labels []string) error {
+	ids, err := c.labelIDs(ctx, owner, name, labels)
+	if err != nil {
+		return err
+	}
+
+	in := struct {
+		Labels []int64 `json:"labels"`
+	}{
+		Labels: ids,
+	}
+	return c.do(ctx, "PUT", fmt.Sprintf("%v/issues/%v/labels", repoPath(owner, name), number), in, nil)
+}
+
+func (c *giteaClient) AddLabels(ctx context.Context, owner, name string, number int, labels []string) error {
+	ids, err := c.labelIDs(ctx, owner, name, labels)
+	if err != nil {
+		return err
+	}
+
+	in := struct {
+		Labels []int64 `json:"labels"`
+	}{
+		Labels: ids,
+	}
+	return c.do(ctx, "POST", fmt.Sprintf("%v/issues/%v/labels", repoPath(owner, name), number), in, nil)
+}
+
+func (c *giteaClient) RemoveLabel(ctx context.Context, owner, name string, number int, label string) error {
+	ids, err := c.labelIDs(ctx, owner, name, []string{label})
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, "DELETE", fmt.Sprintf("%v/issues/%v/labels/%v", repoPath(owner, name), number, ids[0]), nil, nil)
+}
+
+// labelIDs resolves names of labels to their ids because Gitea API changes labels of an issue by ids.
+func (c *giteaClient) labelIDs(ctx context.Context, owner, name string, labels []string) ([]int64, error) {
+	all, err := c.listLabels(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int64)
+	for _, l := range all {
+		ids[l.Name] = l.ID
+	}
+
+	result := make([]int64, 0, len(labels))
+	for _, l := range labels {
+		id, ok := ids[l]
+		if !ok {
+			return nil, fmt.Errorf("%v/%v does not have the label `%v`", owner, name, l)
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
+
+func (c *giteaClient) listLabels(ctx context.Context, owner, name string) ([]giteaLabel, error) {
+	result := make([]giteaLabel, 0)
+	for page := 1; ; page++ {
+		var list []giteaLabel
+		if err := c.do(ctx, "GET", pagePath(repoPath(owner, name)+"/labels", page), nil, &list); err != nil {
+			return nil, err
+		}
+
+		result = append(result, list...)
+
+		if len(list) < giteaPageSize {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (c *giteaClient) ListRepositoryLabels(ctx context.Context, owner, name string) ([]*Label, error) {
+	list, err := c.listLabels(ctx, owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Label, 0, len(list))
+	for _, l := range list {
+		result = append(result, &Label{
+			Name:        l.Name,
+			Color:       strings.TrimPrefix(l.Color, "#"),
+			Description: l.Description,
+		})
+	}
+	return result, nil
+}
+
+// giteaLabelOption is the body to create or edit a label. Gitea requires `#` for the color.
+func giteaLabelOption(label *Label) interface{} {
+	return struct {
+		Name        string `json:"name"`
+		Color       string `json:"color"`
+		Description string `json:"description"`
+	}{
+		Name:        label.Name,
+		Color:       "#" + label.Color,
+		Description: label.Description,
+	}
+}
+
+func (c *giteaClient) CreateLabel(ctx context.Context, owner, name string, label *Label) error {
+	return c.do(ctx, "POST", repoPath(owner, name)+"/labels", giteaLabelOption(label), nil)
+}
+
+// UpdateLabel resolves the label name to its id because Gitea edits a label by the id.
+func (c *giteaClient) UpdateLabel(ctx context.Context, owner, name, current string, label *Label) error {
+	list, err := c.listLabels(ctx, owner, name)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range list {
+		if l.Name == current {
+			return c.do(ctx, "PATCH", fmt.Sprintf("%v/labels/%v", repoPath(owner, name), l.ID), giteaLabelOption(label), nil)
+		}
+	}
+	return fmt.Errorf("%v/%v does not have the label `%v`", owner, name, current)
+}
+
+// AddAssignees merges `users` into the current assignees because Gitea only replaces them.
+func (c *giteaClient) AddAssignees(ctx context.Context, owner, name string, number int, users []string) error {
+	p := fmt.Sprintf("%v/issues/%v", repoPath(owner, name), number)
+
+	var issue giteaIssue
+	if err := c.do(ctx, "GET", p, nil, &issue); err != nil {
+		return err
+	}
+
+	assignees := make([]string, 0, len(issue.Assignees)+len(users))
+	seen := make(map[string]bool)
+	for _, u := range issue.Assignees {
+		assignees = append(assignees, u.Login)
+		seen[u.Login] = true
+	}
+	for _, u := range users {
+		if !seen[u] {
+			assignees = append(assignees, u)
+			seen[u] = true
+		}
+	}
+
+	in := map[string][]string{
+		"assignees": assignees,
+	}
+	return c.do(ctx, "PATCH", p, in, nil)
+}
+
+func (c *giteaClient) ListOpenIssuesWithLabel(ctx context.Context, owner, name, label string) ([]*Issue, error) {
+	result := make([]*Issue, 0)
+	base := repoPath(owner, name) + "/issues?state=open&labels=" + url.QueryEscape(label)
+	for page := 1; ; page++ {
+		var list []giteaIssue
+		if err := c.do(ctx, "GET", pagePath(base, page), nil, &list); err != nil {
+			return nil, err
+		}
+
+		for _, issue := range list {
+			labels := make([]string, 0, len(issue.Labels))
+			for _, l := range issue.Labels {
+				labels = append(labels, l.Name)
+			}
+
+			result = append(result, &Issue{
+				Number:        issue.Number,
+				User:          issue.User.login(),
+				IsPullRequest: issue.PullRequest != nil,
+				Labels:        labels,
+				UpdatedAt:     issue.UpdatedAt,
+			})
+		}
+
+		if len(list) < giteaPageSize {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (u *giteaUser) login() string {
+	if u == nil {
+		return ""
+	}
+	return u.Login
+}
+
+func (c *giteaClient) GetPullRequest(ctx context.Context, owner, name string, number int) (*PullRequest, error) {
+	var pr giteaPullRequest
+	if err := c.do(ctx, "GET", fmt.Sprintf("%v/pulls/%v", repoPath(owner, name), number), nil, &pr); err != nil {
+		return nil, err
+	}
+	return pr.toPullRequest(), nil
+}
+
+// ListOpenPullRequests filters pull requests by `base` on our side because Gitea API does not support it.
+func (c *giteaClient) ListOpenPullRequests(ctx context.Context, owner, name, base string) ([]*PullRequest, error) {
+	result := make([]*PullRequest, 0)
+	for page := 1; ; page++ {
+		var list []giteaPullRequest
+		if err := c.do(ctx, "GET", pagePath(repoPath(owner, name)+"/pulls?state=open", page), nil, &list); err != nil {
+			return nil, err
+		}
+
+		for i := range list {
+			if base != "" && list[i].Base.Ref != base {
+				continue
+			}
+			result = append(result, list[i].toPullRequest())
+		}
+
+		if len(list) < giteaPageSize {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (pr *giteaPullRequest) toPullRequest() *PullRequest {
+	assignees := make([]string, 0, len(pr.Assignees))
+	for _, u := range pr.Assignees {
+		assignees = append(assignees, u.login())
+	}
+
+	reviewers := make([]string, 0, len(pr.RequestedReviewers))
+	for _, u := range pr.RequestedReviewers {
+		reviewers = append(reviewers, u.login())
+	}
+
+	teams := make([]string, 0, len(pr.RequestedTeams))
+	for _, t := range pr.RequestedTeams {
+		teams = append(teams, t.Name)
+	}
+
+	labels := make([]string, 0, len(pr.Labels))
+	for _, l := range pr.Labels {
+		labels = append(labels, l.Name)
+	}
+
+	// Gitea always reports `mergeable`, but it's false also while checking conflicts.
+	// We regard it as fixed only for the opened one, and `false` as unsettled.
+	var mergeable *bool
+	if pr.State == "open" {
+		m := pr.Mergeable
+		mergeable = &m
+	}
+
+	result := &PullRequest{
+		Number:             pr.Number,
+		Title:              pr.Title,
+		Body:               pr.Body,
+		State:              pr.State,
+		Merged:             pr.Merged,
+		Mergeable:          mergeable,
+		MergeableUnsettled: pr.State == "open" && !pr.Mergeable,
+		User:               pr.User.login(),
+		HeadRef:            pr.Head.Ref,
+		HeadSHA:            pr.Head.SHA,
+		BaseRef:            pr.Base.Ref,
+		Labels:             labels,
+		Assignees:          assignees,
+		RequestedReviewers: reviewers,
+		RequestedTeams:     teams,
+	}
+
+	if repo := pr.Head.Repo; repo != nil {
+		result.HeadOwner = repo.Owner.Login
+		result.HeadName = repo.Name
+	}
+
+	if repo := pr.Base.Repo; repo != nil {
+		result.BaseOwner = repo.Owner.Login
+	}
+
+	return result
+}
+
+func (c *giteaClient) ListChangedFiles(ctx context.Context, owner, name string, number int) ([]string, error) {
+	files := make([]string, 0)
+	base := fmt.Sprintf("%v/pulls/%v/files", repoPath(owner, name), number)
+	for page := 1; ; page++ {
+		var list []struct {
+			Filename string `json:"filename"`
+		}
+		if err := c.do(ctx, "GET", pagePath(base, page), nil, &list); err != nil {
+			return nil, err
+		}
+
+		for _, f := range list {
+			files = append(files, f.Filename)
+		}
+
+		if len(list) < giteaPageSize {
+			break
+		}
+	}
+
+	return files, nil
+}
+
+func (c *giteaClient) RequestTeamReview(ctx context.Context, owner, name string, number int, team string) error {
+	in := map[string][]string{
+		"team_reviewers": []string{team},
+	}
+	return c.do(ctx, "POST", fmt.Sprintf("%v/pulls/%v/requested_reviewers", repoPath(owner, name), number), in, nil)
+}
+
+// CompareCommits is not supported because Gitea's compare API does not provide patches.
+func (c *giteaClient) CompareCommits(ctx context.Context, owner, name, base, head string) ([]*FileDiff, error) {
+	return nil, ErrUnsupportedCompare
+}
+
+// Gitea has the distinct names of review states.
+var giteaReviewStateNames = map[string]string{
+	"APPROVED":        "approved",
+	"REQUEST_CHANGES": "changes_requested",
+	"COMMENT":         "commented",
+}
+
+func (c *giteaClient) GetPermission(ctx context.Context, owner, name, user string) (string, error) {
+	var level struct {
+		Permission string `json:"permission"`
+	}
+	if err := c.do(ctx, "GET", fmt.Sprintf("%v/collaborators/%v/permission", repoPath(owner, name), url.PathEscape(user)), nil, &level); err != nil {
+		return "", err
+	}
+	return level.Permission, nil
+}
+
+func (c *giteaClient) ListReviews(ctx context.Context, owner, name string, number int) ([]*Review, error) {
+	reviews := make([]*Review, 0)
+	base := fmt.Sprintf("%v/pulls/%v/reviews", repoPath(owner, name), number)
+	for page := 1; ; page++ {
+		var list []struct {
+			User      *giteaUser `json:"user"`
+			State     string     `json:"state"`
+			CommitID  string     `json:"commit_id"`
+			Dismissed bool       `json:"dismissed"`
+		}
+		if err := c.do(ctx, "GET", pagePath(base, page), nil, &list); err != nil {
+			return nil, err
+		}
+
+		for _, r := range list {
+			state, ok := giteaReviewStateNames[r.State]
+			if !ok {
+				// e.g. "PENDING" which has not been submitted yet.
+				continue
+			}
+			if r.Dismissed {
+				state = "dismissed"
+			}
+
+			reviews = append(reviews, &Review{
+				User:     r.User.login(),
+				State:    state,
+				CommitID: r.CommitID,
+			})
+		}
+
+		if len(list) < giteaPageSize {
+			break
+		}
+	}
+
+	return reviews, nil
+}
+
+func (c *giteaClient) GetBranchProtection(ctx context.Context, owner, name, branch string) (*BranchProtection, error) {
+	var p struct {
+		RequiredApprovals     int      `json:"required_approvals"`
+		DismissStaleApprovals bool     `json:"dismiss_stale_approvals"`
+		EnableStatusCheck     bool     `json:"enable_status_check"`
+		StatusCheckContexts   []string `json:"status_check_contexts"`
+	}
+	err := c.do(ctx, "GET", fmt.Sprintf("%v/branch_protections/%v", repoPath(owner, name), url.PathEscape(branch)), nil, &p)
+	if err != nil {
+		var e *giteaError
+		if errors.As(err, &e) && e.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	result := &BranchProtection{
+		RequiredApprovingReviews: p.RequiredApprovals,
+		DismissStaleReviews:      p.DismissStaleApprovals,
+		RequiredStatusChecks:     make([]string, 0),
+	}
+	if p.EnableStatusCheck {
+		result.RequiredStatusChecks = append(result.RequiredStatusChecks, p.StatusCheckContexts...)
+	}
+	return result, nil
+}
+
+func (c *giteaClient) MergePullRequest(ctx context.Context, owner, name string, number int, sha string) error {
+	in := map[string]string{
+		"Do":             "merge",
+		"head_commit_id": sha,
+	}
+	return c.do(ctx, "POST", fmt.Sprintf("%v/pulls/%v/merge", repoPath(owner, name), number), in, nil)
+}
+
+// CreateTryBranch is not supported because Gitea API can neither provide the ref which merges the pull request
+// into its base branch (like GitHub's `refs/pull/<number>/merge`) nor create a merge commit.
+// Testing only the head would merge the pull request without testing the merged result.
+func (c *giteaClient) CreateTryBranch(ctx context.Context, owner, name string, number int, branch string) (string, error) {
+	return "", ErrUnsupportedTryBranch
+}
+
+func (c *giteaClient) SupportsTryBranch() bool {
+	return false
+}
+
+func (c *giteaClient) DeleteBranch(ctx context.Context, owner, name, branch string) error {
+	return c.do(ctx, "DELETE", repoPath(owner, name)+"/branches/"+escapeFilePath(branch), nil, nil)
+}
+
+func (c *giteaClient) GetCombinedStatus(ctx context.Context, owner, name, ref string) (*CombinedStatus, error) {
+	var combined struct {
+		State    string `json:"state"`
+		Statuses []struct {
+			Context     string `json:"context"`
+			Status      string `json:"status"`
+			Description string `json:"description"`
+			TargetURL   string `json:"target_url"`
+		} `json:"statuses"`
+	}
+	if err := c.do(ctx, "GET", repoPath(owner, name)+"/commits/"+escapeFilePath(ref)+"/status", nil, &combined); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*CommitStatus, 0, len(combined.Statuses))
+	for _, s := range combined.Statuses {
+		statuses = append(statuses, &CommitStatus{
+			Context:     s.Context,
+			State:       s.Status,
+			Description: s.Description,
+			TargetURL:   s.TargetURL,
+		})
+	}
+
+	return &CombinedStatus{
+		State:    giteaState(combined.State),
+		Statuses: statuses,
+	}, nil
+}
+
+// giteaState converts Gitea's commit state to the one of GitHub.
+// Gitea has "warning" in addition to GitHub's states.
+func giteaState(state string) string {
+	switch state {
+	case "":
+		return "pending"
+	case "warning":
+		return "failure"
+	default:
+		return state
+	}
+}
+
+func (c *giteaClient) ListCheckSuites(ctx context.Context, owner, name, ref string) ([]*CheckSuite, error) {
+	return []*CheckSuite{}, nil
+}
+
+func (c *giteaClient) ListCheckRuns(ctx context.Context, owner, name, ref string) ([]*CheckRun, error) {
+	return []*CheckRun{}, nil
+}
+
+func (c *giteaClient) ListTeamMembers(ctx context.Context, org, slug string) ([]string, error) {
+	var found struct {
+		Data []giteaTeam `json:"data"`
+	}
+	p := "/orgs/" + url.PathEscape(org) + "/teams/search?q=" + url.QueryEscape(slug)
+	if err := c.do(ctx, "GET", p, nil, &found); err != nil {
+		return nil, err
+	}
+
+	var team *giteaTeam
+	for i := range found.Data {
+		if strings.EqualFold(found.Data[i].Name, slug) {
+			team = &found.Data[i]
+			break
+		}
+	}
+	if team == nil {
+		return nil, fmt.Errorf("the team `%v/%v` is not found", org, slug)
+	}
+
+	members := make([]string, 0)
+	for page := 1; ; page++ {
+		var list []giteaUser
+		if err := c.do(ctx, "GET", pagePath(fmt.Sprintf("/teams/%v/members", team.ID), page), nil, &list); err != nil {
+			return nil, err
+		}
+
+		for _, u := range list {
+			members = append(members, u.Login)
+		}
+
+		if len(list) < giteaPageSize {
+			break
+		}
+	}
+
+	return members, nil
+}
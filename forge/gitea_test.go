@@ -0,0 +1,403 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTestGitea(t *testing.T, handler http.HandlerFunc) (Client, *httptest.Server) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "token secret" {
+			t.Errorf("%v %v is not authorized", req.Method, req.URL)
+		}
+		handler(rw, req)
+	}))
+	return NewGiteaClient(srv.URL+"/", "secret", srv.Client()), srv
+}
+
+func TestGiteaReplaceLabels(t *testing.T) {
+	var actual []int64
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/labels":
+			fmt.Fprint(rw, `[{"id": 1, "name": "S-awaiting-review"}, {"id": 2, "name": "S-awaiting-merge"}, {"id": 3, "name": "bug"}]`)
+		case "PUT /api/v1/repos/foo/bar/issues/1/labels":
+			var in struct {
+				Labels []int64 `json:"labels"`
+			}
+			json.NewDecoder(req.Body).Decode(&in)
+			actual = in.Labels
+			fmt.Fprint(rw, `[]`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	if err := client.ReplaceLabels(context.Background(), "foo", "bar", 1, []string{"bug", "S-awaiting-merge"}); err != nil {
+		t.Errorf("should replace labels: %v", err)
+		return
+	}
+
+	if expected := []int64{3, 2}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("should put %v but %v", expected, actual)
+		return
+	}
+
+	if err := client.ReplaceLabels(context.Background(), "foo", "bar", 1, []string{"unknown"}); err == nil {
+		t.Errorf("should fail for the unknown label")
+		return
+	}
+}
+
+func TestGiteaAddAndRemoveLabels(t *testing.T) {
+	var added []int64
+	removed := false
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/labels":
+			fmt.Fprint(rw, `[{"id": 1, "name": "S-awaiting-review"}, {"id": 2, "name": "S-needs-rebase"}]`)
+		case "POST /api/v1/repos/foo/bar/issues/1/labels":
+			var in struct {
+				Labels []int64 `json:"labels"`
+			}
+			json.NewDecoder(req.Body).Decode(&in)
+			added = in.Labels
+			fmt.Fprint(rw, `[]`)
+		case "DELETE /api/v1/repos/foo/bar/issues/1/labels/1":
+			removed = true
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	if err := client.RemoveLabel(context.Background(), "foo", "bar", 1, "S-awaiting-review"); err != nil || !removed {
+		t.Errorf("should remove the label: %v", err)
+		return
+	}
+
+	if err := client.AddLabels(context.Background(), "foo", "bar", 1, []string{"S-needs-rebase"}); err != nil {
+		t.Errorf("should add labels: %v", err)
+		return
+	}
+
+	if expected := []int64{2}; !reflect.DeepEqual(added, expected) {
+		t.Errorf("should post %v but %v", expected, added)
+		return
+	}
+}
+
+func TestGiteaAddAssignees(t *testing.T) {
+	var actual []string
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/issues/1":
+			fmt.Fprint(rw, `{"number": 1, "assignees": [{"login": "alice"}]}`)
+		case "PATCH /api/v1/repos/foo/bar/issues/1":
+			var in struct {
+				Assignees []string `json:"assignees"`
+			}
+			json.NewDecoder(req.Body).Decode(&in)
+			actual = in.Assignees
+			fmt.Fprint(rw, `{}`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	if err := client.AddAssignees(context.Background(), "foo", "bar", 1, []string{"bob", "alice"}); err != nil {
+		t.Errorf("should add assignees: %v", err)
+		return
+	}
+
+	if expected := []string{"alice", "bob"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("should patch %v but %v", expected, actual)
+		return
+	}
+}
+
+func TestGiteaAddReaction(t *testing.T) {
+	var actual map[string]string
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "POST /api/v1/repos/foo/bar/issues/comments/10/reactions":
+			json.NewDecoder(req.Body).Decode(&actual)
+			rw.WriteHeader(http.StatusCreated)
+			fmt.Fprint(rw, `{}`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	if err := client.AddReaction(context.Background(), "foo", "bar", CommentKindIssue, 10, ReactionOK); err != nil {
+		t.Errorf("should add the reaction: %v", err)
+		return
+	}
+
+	if actual["content"] != "+1" {
+		t.Errorf("should post `+1` but %v", actual)
+		return
+	}
+
+	if err := client.AddReaction(context.Background(), "foo", "bar", CommentKindReview, 10, ReactionOK); err != ErrUnsupportedComment {
+		t.Errorf("should not react to a review but %v", err)
+		return
+	}
+}
+
+func TestGiteaComments(t *testing.T) {
+	var edited map[string]string
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "POST /api/v1/repos/foo/bar/issues/1/comments":
+			rw.WriteHeader(http.StatusCreated)
+			fmt.Fprint(rw, `{"id": 10, "body": "hello", "user": {"login": "popuko"}}`)
+		case "PATCH /api/v1/repos/foo/bar/issues/comments/10":
+			json.NewDecoder(req.Body).Decode(&edited)
+			fmt.Fprint(rw, `{"id": 10}`)
+		case "GET /api/v1/repos/foo/bar/issues/1/comments":
+			fmt.Fprint(rw, `[{"id": 10, "body": "hello", "user": {"login": "popuko"}}]`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	id, err := client.AddComment(context.Background(), "foo", "bar", 1, "hello")
+	if err != nil || id != 10 {
+		t.Errorf("should return the id of the comment: %v, %v", id, err)
+		return
+	}
+
+	if err := client.EditComment(context.Background(), "foo", "bar", id, "world"); err != nil || edited["body"] != "world" {
+		t.Errorf("should edit the comment: %v, %v", edited, err)
+		return
+	}
+
+	list, err := client.ListComments(context.Background(), "foo", "bar", 1)
+	if err != nil || len(list) != 1 || list[0].ID != 10 || list[0].User != "popuko" || list[0].Body != "hello" {
+		t.Errorf("unexpected comments: %+v, %v", list, err)
+		return
+	}
+}
+
+func TestGiteaLabels(t *testing.T) {
+	var created, edited map[string]string
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/labels":
+			fmt.Fprint(rw, `[{"id": 3, "name": "S-awaiting-review", "color": "#ffffff", "description": ""}]`)
+		case "POST /api/v1/repos/foo/bar/labels":
+			json.NewDecoder(req.Body).Decode(&created)
+			rw.WriteHeader(http.StatusCreated)
+			fmt.Fprint(rw, `{}`)
+		case "PATCH /api/v1/repos/foo/bar/labels/3":
+			json.NewDecoder(req.Body).Decode(&edited)
+			fmt.Fprint(rw, `{}`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	list, err := client.ListRepositoryLabels(context.Background(), "foo", "bar")
+	if err != nil || len(list) != 1 || list[0].Color != "ffffff" {
+		t.Errorf("the color should not have `#`: %+v, %v", list, err)
+		return
+	}
+
+	if err := client.CreateLabel(context.Background(), "foo", "bar", &Label{Name: "S-awaiting-merge", Color: "0e8a16"}); err != nil || created["color"] != "#0e8a16" {
+		t.Errorf("should create the label with `#`: %v, %v", created, err)
+		return
+	}
+
+	if err := client.UpdateLabel(context.Background(), "foo", "bar", "S-awaiting-review", &Label{Name: "S-awaiting-review", Color: "fbca04"}); err != nil || edited["color"] != "#fbca04" {
+		t.Errorf("should edit the label by the id: %v, %v", edited, err)
+		return
+	}
+
+	if err := client.UpdateLabel(context.Background(), "foo", "bar", "unknown", &Label{Name: "unknown"}); err == nil {
+		t.Errorf("should fail for the unknown label")
+		return
+	}
+}
+
+func TestGiteaListOpenPullRequests(t *testing.T) {
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/pulls":
+			fmt.Fprint(rw, `[{"number": 1, "state": "open", "base": {"ref": "master"}, "labels": [{"name": "S-needs-rebase"}]}, {"number": 2, "state": "open", "base": {"ref": "develop"}}]`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	list, err := client.ListOpenPullRequests(context.Background(), "foo", "bar", "master")
+	if err != nil {
+		t.Errorf("should list pull requests: %v", err)
+		return
+	}
+
+	if len(list) != 1 || list[0].Number != 1 {
+		t.Errorf("should return only #1 into master but %+v", list)
+		return
+	}
+
+	if expected := []string{"S-needs-rebase"}; !reflect.DeepEqual(list[0].Labels, expected) {
+		t.Errorf("should have labels %v but %v", expected, list[0].Labels)
+		return
+	}
+}
+
+func TestGiteaCreateTryBranch(t *testing.T) {
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request: %v %v", req.Method, req.URL.Path)
+		rw.WriteHeader(http.StatusNotFound)
+	})
+	defer srv.Close()
+
+	if _, err := client.CreateTryBranch(context.Background(), "foo", "bar", 1, "auto"); err != ErrUnsupportedTryBranch {
+		t.Errorf("should not create the branch which does not test the merged result: %v", err)
+		return
+	}
+}
+
+func TestGiteaGetPullRequestWhileCheckingConflicts(t *testing.T) {
+	type Testcase struct {
+		mergeable bool
+		unsettled bool
+	}
+	list := []Testcase{
+		Testcase{true, false},
+		Testcase{false, true},
+	}
+
+	for _, test := range list {
+		requests := 0
+		client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+			requests++
+			fmt.Fprintf(rw, `{"number": 1, "state": "open", "mergeable": %v, "head": {"ref": "feature", "sha": "abc"}, "base": {"ref": "master"}}`, test.mergeable)
+		})
+
+		pr, err := client.GetPullRequest(context.Background(), "foo", "bar", 1)
+		srv.Close()
+		if err != nil {
+			t.Errorf("%+v: should fetch the pull request: %v", test, err)
+			continue
+		}
+
+		if pr.Mergeable == nil || *pr.Mergeable != test.mergeable || pr.MergeableUnsettled != test.unsettled {
+			t.Errorf("%+v: unexpected mergeable: %v (unsettled: %v)", test, pr.Mergeable, pr.MergeableUnsettled)
+		}
+
+		if requests != 1 {
+			t.Errorf("%+v: should fetch only once but %v times", test, requests)
+		}
+	}
+}
+
+func TestGiteaGetCombinedStatus(t *testing.T) {
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/commits/auto/status":
+			fmt.Fprint(rw, `{"state": "warning", "statuses": [{"context": "ci", "status": "warning", "target_url": "https://ci.example.com"}]}`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	combined, err := client.GetCombinedStatus(context.Background(), "foo", "bar", "auto")
+	if err != nil {
+		t.Errorf("should get the status: %v", err)
+		return
+	}
+
+	if combined.State != "failure" {
+		t.Errorf("`warning` should be regarded as `failure` but `%v`", combined.State)
+		return
+	}
+
+	if len(combined.Statuses) != 1 || combined.Statuses[0].Context != "ci" || combined.Statuses[0].TargetURL != "https://ci.example.com" {
+		t.Errorf("unexpected statuses: %+v", combined.Statuses)
+		return
+	}
+}
+
+func TestGiteaListReviews(t *testing.T) {
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/pulls/1/reviews":
+			fmt.Fprint(rw, `[
+				{"user": {"login": "alice"}, "state": "REQUEST_CHANGES", "commit_id": "abc"},
+				{"user": {"login": "bob"}, "state": "APPROVED", "commit_id": "abc", "dismissed": true},
+				{"user": {"login": "carol"}, "state": "PENDING", "commit_id": "abc"}
+			]`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	list, err := client.ListReviews(context.Background(), "foo", "bar", 1)
+	if err != nil {
+		t.Errorf("should list reviews: %v", err)
+		return
+	}
+
+	expected := []*Review{
+		&Review{User: "alice", State: "changes_requested", CommitID: "abc"},
+		&Review{User: "bob", State: "dismissed", CommitID: "abc"},
+	}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("should be %+v but %+v", expected, list)
+		return
+	}
+}
+
+func TestGiteaGetBranchProtection(t *testing.T) {
+	client, srv := newTestGitea(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch p := req.Method + " " + req.URL.Path; p {
+		case "GET /api/v1/repos/foo/bar/branch_protections/master":
+			fmt.Fprint(rw, `{"required_approvals": 2, "enable_status_check": true, "status_check_contexts": ["ci"]}`)
+		case "GET /api/v1/repos/foo/bar/branch_protections/develop":
+			rw.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(rw, `{"message": "Not Found"}`)
+		default:
+			t.Errorf("unexpected request: %v", p)
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer srv.Close()
+
+	p, err := client.GetBranchProtection(context.Background(), "foo", "bar", "master")
+	if err != nil || p == nil || p.RequiredApprovingReviews != 2 || !reflect.DeepEqual(p.RequiredStatusChecks, []string{"ci"}) {
+		t.Errorf("unexpected protection: %+v, %v", p, err)
+		return
+	}
+
+	p, err = client.GetBranchProtection(context.Background(), "foo", "bar", "develop")
+	if err != nil || p != nil {
+		t.Errorf("should return nil for the unprotected branch: %+v, %v", p, err)
+		return
+	}
+}
@@ -0,0 +1,217 @@
+package forge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type giteaWebhook struct{}
+
+// NewGiteaWebhook returns the parser of webhooks sent from Gitea.
+func NewGiteaWebhook() Webhook {
+	return giteaWebhook{}
+}
+
+const (
+	giteaEventHeader     = "X-Gitea-Event"
+	giteaDeliveryHeader  = "X-Gitea-Delivery"
+	giteaSignatureHeader = "X-Gitea-Signature"
+)
+
+// ValidatePayload checks `X-Gitea-Signature` which is the hex encoded HMAC-SHA256 of the body.
+func (giteaWebhook) ValidatePayload(req *http.Request, secret []byte) ([]byte, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secret) > 0 {
+		sig, err := hex.DecodeString(req.Header.Get(giteaSignatureHeader))
+		if err != nil {
+			return nil, errors.New("the signature is invalid")
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, errors.New("the signature does not match")
+		}
+	}
+
+	// Gitea sends the payload as the form value if the webhook's content type is `application/x-www-form-urlencoded`.
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(form.Get("payload")), nil
+	}
+
+	return body, nil
+}
+
+func (giteaWebhook) EventType(req *http.Request) string {
+	return req.Header.Get(giteaEventHeader)
+}
+
+func (giteaWebhook) DeliveryID(req *http.Request) string {
+	return req.Header.Get(giteaDeliveryHeader)
+}
+
+type giteaCommentPayload struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number      int        `json:"number"`
+		User        *giteaUser `json:"user"`
+		PullRequest *struct{}  `json:"pull_request"`
+	} `json:"issue"`
+	Comment struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+	} `json:"comment"`
+	IsPull     bool            `json:"is_pull"`
+	Repository giteaRepository `json:"repository"`
+	Sender     giteaUser       `json:"sender"`
+}
+
+type giteaPushPayload struct {
+	Ref        string          `json:"ref"`
+	After      string          `json:"after"`
+	CompareURL string          `json:"compare_url"`
+	Repository giteaRepository `json:"repository"`
+}
+
+type giteaStatusPayload struct {
+	ID         int64           `json:"id"`
+	SHA        string          `json:"sha"`
+	State      string          `json:"state"`
+	Context    string          `json:"context"`
+	Repository giteaRepository `json:"repository"`
+}
+
+type giteaPullRequestPayload struct {
+	Action      string           `json:"action"`
+	PullRequest giteaPullRequest `json:"pull_request"`
+	Repository  giteaRepository  `json:"repository"`
+	Sender      giteaUser        `json:"sender"`
+	Review      *struct {
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	} `json:"review"`
+}
+
+// Gitea sends reviews as the distinct event types instead of the state.
+var giteaReviewStates = map[string]string{
+	"pull_request_review_approved": "approved",
+	"pull_request_review_rejected": "changes_requested",
+	"pull_request_review_comment":  "commented",
+}
+
+func (giteaWebhook) ParseWebhook(eventType string, payload []byte) (Event, error) {
+	switch eventType {
+	case "issue_comment", "pull_request_comment":
+		var p giteaCommentPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+
+		return &CommentEvent{
+			Repository:    p.Repository.toRepository(),
+			Action:        p.Action,
+			Number:        p.Issue.Number,
+			IsPullRequest: p.IsPull || p.Issue.PullRequest != nil,
+			IssueUser:     p.Issue.User.login(),
+			CommentID:     p.Comment.ID,
+			Body:          p.Comment.Body,
+			Sender:        p.Sender.Login,
+		}, nil
+	case "push":
+		var p giteaPushPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+
+		return &PushEvent{
+			Repository: p.Repository.toRepository(),
+			Ref:        p.Ref,
+			After:      p.After,
+			Compare:    p.CompareURL,
+		}, nil
+	case "status":
+		var p giteaStatusPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+
+		state := giteaState(p.State)
+		return &StatusEvent{
+			Repository: p.Repository.toRepository(),
+			ID:         p.ID,
+			SHA:        p.SHA,
+			State:      state,
+			Context:    p.Context,
+			Completed:  state != "pending",
+			// Gitea does not tell branches which contain the commit.
+			Branches: nil,
+		}, nil
+	case "pull_request", "pull_request_label":
+		var p giteaPullRequestPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+
+		action := p.Action
+		if action == "synchronized" {
+			// Align with GitHub.
+			action = "synchronize"
+		}
+
+		return &PullRequestEvent{
+			Repository: p.Repository.toRepository(),
+			Action:     action,
+			Sender:     p.Sender.Login,
+			// Gitea sends "label_updated" or "label_cleared" without the changed label.
+			PullRequest: p.PullRequest.toPullRequest(),
+		}, nil
+	case "pull_request_review_approved", "pull_request_review_rejected", "pull_request_review_comment":
+		var p giteaPullRequestPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+
+		var body string
+		if p.Review != nil {
+			body = p.Review.Content
+		}
+
+		pr := p.PullRequest.toPullRequest()
+		return &ReviewEvent{
+			Repository: p.Repository.toRepository(),
+			// Gitea sends a review only when it's submitted.
+			Action: "submitted",
+			State:  giteaReviewStates[eventType],
+			Body:   body,
+			// Gitea does not tell the reviewed commit. We regard the review is for the current head.
+			CommitID:    pr.HeadSHA,
+			Sender:      p.Sender.Login,
+			PullRequest: pr,
+		}, nil
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+}
+
+func (r *giteaRepository) toRepository() Repository {
+	return Repository{
+		Owner:         r.Owner.Login,
+		Name:          r.Name,
+		DefaultBranch: r.DefaultBranch,
+	}
+}
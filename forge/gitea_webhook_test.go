@@ -0,0 +1,187 @@
+package forge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGiteaValidatePayload(t *testing.T) {
+	type Testcase struct {
+		signature string
+		ok        bool
+	}
+
+	body := `{"action": "created"}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+
+	list := []Testcase{
+		Testcase{
+			signature: hex.EncodeToString(mac.Sum(nil)),
+			ok:        true,
+		},
+		Testcase{
+			signature: "0000",
+			ok:        false,
+		},
+		Testcase{
+			signature: "",
+			ok:        false,
+		},
+	}
+
+	for i, test := range list {
+		req := httptest.NewRequest("POST", "/gitea", strings.NewReader(body))
+		req.Header.Set("X-Gitea-Signature", test.signature)
+
+		payload, err := NewGiteaWebhook().ValidatePayload(req, []byte("secret"))
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("#%v should be %v but %v", i, test.ok, err)
+			continue
+		}
+
+		if test.ok && string(payload) != body {
+			t.Errorf("#%v should return the body but `%s`", i, payload)
+		}
+	}
+}
+
+func TestGiteaParseCommentEvent(t *testing.T) {
+	payload := `{
+		"action": "created",
+		"issue": {"number": 3, "user": {"login": "author"}, "pull_request": {"merged": false}},
+		"comment": {"id": 10, "body": "@popuko r+"},
+		"repository": {"name": "bar", "owner": {"login": "foo"}, "default_branch": "master"},
+		"sender": {"login": "reviewer"},
+		"is_pull": true
+	}`
+
+	ev, err := NewGiteaWebhook().ParseWebhook("issue_comment", []byte(payload))
+	if err != nil {
+		t.Errorf("should parse the payload: %v", err)
+		return
+	}
+
+	actual, ok := ev.(*CommentEvent)
+	if !ok {
+		t.Errorf("should be CommentEvent but %T", ev)
+		return
+	}
+
+	expected := CommentEvent{
+		Repository: Repository{
+			Owner:         "foo",
+			Name:          "bar",
+			DefaultBranch: "master",
+		},
+		Action:        "created",
+		Number:        3,
+		IsPullRequest: true,
+		IssueUser:     "author",
+		CommentID:     10,
+		Body:          "@popuko r+",
+		Sender:        "reviewer",
+	}
+	if *actual != expected {
+		t.Errorf("should be %+v but %+v", expected, *actual)
+		return
+	}
+}
+
+func TestGiteaParseStatusEvent(t *testing.T) {
+	payload := `{
+		"id": 5,
+		"sha": "abc",
+		"state": "success",
+		"context": "ci",
+		"repository": {"name": "bar", "owner": {"login": "foo"}}
+	}`
+
+	ev, err := NewGiteaWebhook().ParseWebhook("status", []byte(payload))
+	if err != nil {
+		t.Errorf("should parse the payload: %v", err)
+		return
+	}
+
+	actual, ok := ev.(*StatusEvent)
+	if !ok {
+		t.Errorf("should be StatusEvent but %T", ev)
+		return
+	}
+
+	if !actual.Completed || actual.State != "success" || actual.SHA != "abc" || actual.Branches != nil {
+		t.Errorf("unexpected event: %+v", actual)
+		return
+	}
+}
+
+func TestGiteaParseReviewEvent(t *testing.T) {
+	payload := `{
+		"action": "reviewed",
+		"number": 3,
+		"pull_request": {"number": 3, "state": "open", "user": {"login": "author"}, "head": {"ref": "feature", "sha": "abc"}},
+		"repository": {"name": "bar", "owner": {"login": "foo"}},
+		"sender": {"login": "reviewer"},
+		"review": {"type": "pull_request_review_approved", "content": "LGTM"}
+	}`
+
+	ev, err := NewGiteaWebhook().ParseWebhook("pull_request_review_approved", []byte(payload))
+	if err != nil {
+		t.Errorf("should parse the payload: %v", err)
+		return
+	}
+
+	actual, ok := ev.(*ReviewEvent)
+	if !ok {
+		t.Errorf("should be ReviewEvent but %T", ev)
+		return
+	}
+
+	if actual.Action != "submitted" || actual.State != "approved" || actual.CommitID != "abc" || actual.Body != "LGTM" {
+		t.Errorf("unexpected event: %+v", actual)
+		return
+	}
+}
+
+func TestGiteaParseLabelEvent(t *testing.T) {
+	payload := `{
+		"action": "label_updated",
+		"number": 3,
+		"pull_request": {"number": 3, "state": "open", "labels": [{"name": "S-awaiting-merge"}], "head": {"ref": "feature", "sha": "abc"}},
+		"repository": {"name": "bar", "owner": {"login": "foo"}},
+		"sender": {"login": "reviewer"}
+	}`
+
+	ev, err := NewGiteaWebhook().ParseWebhook("pull_request_label", []byte(payload))
+	if err != nil {
+		t.Errorf("should parse the payload: %v", err)
+		return
+	}
+
+	actual, ok := ev.(*PullRequestEvent)
+	if !ok {
+		t.Errorf("should be PullRequestEvent but %T", ev)
+		return
+	}
+
+	if actual.Action != "label_updated" || actual.Sender != "reviewer" || actual.Label != "" {
+		t.Errorf("unexpected event: %+v", actual)
+		return
+	}
+
+	if expected := []string{"S-awaiting-merge"}; !reflect.DeepEqual(actual.PullRequest.Labels, expected) {
+		t.Errorf("should have labels %v but %v", expected, actual.PullRequest.Labels)
+		return
+	}
+}
+
+func TestGiteaParseUnsupportedEvent(t *testing.T) {
+	if _, err := NewGiteaWebhook().ParseWebhook("repository", []byte(`{}`)); err != ErrUnsupportedEvent {
+		t.Errorf("should be ErrUnsupportedEvent but %v", err)
+	}
+}
@@ -0,0 +1,563 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+)
+
+type gitHubClient struct {
+	client *github.Client
+}
+
+// NewGitHubClient returns the client which calls GitHub API by `client`.
+func NewGitHubClient(client *github.Client) Client {
+	return &gitHubClient{
+		client: client,
+	}
+}
+
+func (c *gitHubClient) GetDefaultBranch(ctx context.Context, owner, name string) (string, error) {
+	repo, _, err := c.client.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return "", err
+	}
+	return repo.GetDefaultBranch(), nil
+}
+
+func (c *gitHubClient) GetFile(ctx context.Context, owner, name, path, ref string) ([]byte, error) {
+	file, err := c.client.Repositories.DownloadContents(ctx, owner, name, path, &github.RepositoryContentGetOptions{
+		Ref: ref,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
+}
+
+func (c *gitHubClient) AddComment(ctx context.Context, owner, name string, number int, body string) (int64, error) {
+	comment, _, err := c.client.Issues.CreateComment(ctx, owner, name, number, &github.IssueComment{
+		Body: &body,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return comment.GetID(), nil
+}
+
+func (c *gitHubClient) EditComment(ctx context.Context, owner, name string, id int64, body string) error {
+	_, _, err := c.client.Issues.EditComment(ctx, owner, name, id, &github.IssueComment{
+		Body: &body,
+	})
+	return err
+}
+
+func (c *gitHubClient) ListComments(ctx context.Context, owner, name string, number int) ([]*Comment, error) {
+	result := make([]*Comment, 0)
+	opt := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		list, res, err := c.client.Issues.ListComments(ctx, owner, name, number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, comment := range list {
+			result = append(result, &Comment{
+				ID:   comment.GetID(),
+				User: comment.GetUser().GetLogin(),
+				Body: comment.GetBody(),
+			})
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return result, nil
+}
+
+func (c *gitHubClient) AddReaction(ctx context.Context, owner, name string, kind CommentKind, id int64, reaction string) error {
+	var err error
+	switch kind {
+	case CommentKindIssue:
+		_, _, err = c.client.Reactions.CreateIssueCommentReaction(ctx, owner, name, id, reaction)
+	case CommentKindReviewComment:
+		_, _, err = c.client.Reactions.CreatePullRequestCommentReaction(ctx, owner, name, id, reaction)
+	default:
+		// GitHub does not support reactions to a review.
+		err = ErrUnsupportedComment
+	}
+	return err
+}
+
+func (c *gitHubClient) GetLabels(ctx context.Context, owner, name string, number int) ([]string, error) {
+	result := make([]string, 0)
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+	for {
+		list, res, err := c.client.Issues.ListLabelsByIssue(ctx, owner, name, number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range list {
+			result = append(result, l.GetName())
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return result, nil
+}
+
+func (c *gitHubClient) ReplaceLabels(ctx context.Context, owner, name string, number int, labels []string) error {
+	_, _, err := c.client.Issues.ReplaceLabelsForIssue(ctx, owner, name, number, labels)
+	return err
+}
+
+func (c *gitHubClient) AddLabels(ctx context.Context, owner, name string, number int, labels []string) error {
+	_, _, err := c.client.Issues.AddLabelsToIssue(ctx, owner, name, number, labels)
+	return err
+}
+
+func (c *gitHubClient) RemoveLabel(ctx context.Context, owner, name string, number int, label string) error {
+	_, err := c.client.Issues.RemoveLabelForIssue(ctx, owner, name, number, label)
+	return err
+}
+
+func (c *gitHubClient) AddAssignees(ctx context.Context, owner, name string, number int, users []string) error {
+	_, _, err := c.client.Issues.AddAssignees(ctx, owner, name, number, users)
+	return err
+}
+
+func (c *gitHubClient) ListOpenIssuesWithLabel(ctx context.Context, owner, name, label string) ([]*Issue, error) {
+	result := make([]*Issue, 0)
+	opt := &github.IssueListByRepoOptions{
+		State:  "open",
+		Labels: []string{label},
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		list, res, err := c.client.Issues.ListByRepo(ctx, owner, name, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, issue := range list {
+			labels := make([]string, 0, len(issue.Labels))
+			for _, l := range issue.Labels {
+				labels = append(labels, l.GetName())
+			}
+
+			result = append(result, &Issue{
+				Number:        issue.GetNumber(),
+				User:          issue.GetUser().GetLogin(),
+				IsPullRequest: issue.IsPullRequest(),
+				Labels:        labels,
+				UpdatedAt:     issue.GetUpdatedAt(),
+			})
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return result, nil
+}
+
+func (c *gitHubClient) ListRepositoryLabels(ctx context.Context, owner, name string) ([]*Label, error) {
+	result := make([]*Label, 0)
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+	for {
+		list, res, err := c.client.Issues.ListLabels(ctx, owner, name, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range list {
+			result = append(result, &Label{
+				Name:        l.GetName(),
+				Color:       l.GetColor(),
+				Description: l.GetDescription(),
+			})
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return result, nil
+}
+
+func (c *gitHubClient) CreateLabel(ctx context.Context, owner, name string, label *Label) error {
+	_, _, err := c.client.Issues.CreateLabel(ctx, owner, name, &github.Label{
+		Name:        github.String(label.Name),
+		Color:       github.String(label.Color),
+		Description: github.String(label.Description),
+	})
+	return err
+}
+
+func (c *gitHubClient) UpdateLabel(ctx context.Context, owner, name, current string, label *Label) error {
+	// go-github does not escape the name (e.g. `status/queued`) in the path.
+	_, _, err := c.client.Issues.EditLabel(ctx, owner, name, url.PathEscape(current), &github.Label{
+		Name:        github.String(label.Name),
+		Color:       github.String(label.Color),
+		Description: github.String(label.Description),
+	})
+	return err
+}
+
+func (c *gitHubClient) GetPullRequest(ctx context.Context, owner, name string, number int) (*PullRequest, error) {
+	pr, _, err := c.client.PullRequests.Get(ctx, owner, name, number)
+	if err != nil {
+		return nil, err
+	}
+	return fromGitHubPullRequest(pr), nil
+}
+
+func (c *gitHubClient) ListOpenPullRequests(ctx context.Context, owner, name, base string) ([]*PullRequest, error) {
+	result := make([]*PullRequest, 0)
+	opt := &github.PullRequestListOptions{
+		State: "open",
+		Base:  base,
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		list, res, err := c.client.PullRequests.List(ctx, owner, name, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pr := range list {
+			result = append(result, fromGitHubPullRequest(pr))
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return result, nil
+}
+
+func (c *gitHubClient) ListChangedFiles(ctx context.Context, owner, name string, number int) ([]string, error) {
+	files := make([]string, 0)
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+	for {
+		list, res, err := c.client.PullRequests.ListFiles(ctx, owner, name, number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range list {
+			files = append(files, f.GetFilename())
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return files, nil
+}
+
+func (c *gitHubClient) RequestTeamReview(ctx context.Context, owner, name string, number int, team string) error {
+	_, _, err := c.client.PullRequests.RequestReviewers(ctx, owner, name, number, github.ReviewersRequest{
+		TeamReviewers: []string{team},
+	})
+	return err
+}
+
+// GitHub returns up to this number of files for the comparison.
+const gitHubMaxComparedFiles = 300
+
+func (c *gitHubClient) CompareCommits(ctx context.Context, owner, name, base, head string) ([]*FileDiff, error) {
+	comparison, _, err := c.client.Repositories.CompareCommits(ctx, owner, name, base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(comparison.Files) >= gitHubMaxComparedFiles {
+		return nil, fmt.Errorf("the comparison between %v and %v has too many files", base, head)
+	}
+
+	files := make([]*FileDiff, 0, len(comparison.Files))
+	for _, f := range comparison.Files {
+		files = append(files, &FileDiff{
+			Filename: f.GetFilename(),
+			Status:   f.GetStatus(),
+			Patch:    f.GetPatch(),
+			SHA:      f.GetSHA(),
+		})
+	}
+	return files, nil
+}
+
+func (c *gitHubClient) GetPermission(ctx context.Context, owner, name, user string) (string, error) {
+	level, _, err := c.client.Repositories.GetPermissionLevel(ctx, owner, name, user)
+	if err != nil {
+		return "", err
+	}
+	return level.GetPermission(), nil
+}
+
+func (c *gitHubClient) ListReviews(ctx context.Context, owner, name string, number int) ([]*Review, error) {
+	reviews := make([]*Review, 0)
+	opt := &github.ListOptions{
+		PerPage: 100,
+	}
+	for {
+		list, res, err := c.client.PullRequests.ListReviews(ctx, owner, name, number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range list {
+			reviews = append(reviews, &Review{
+				User:     r.GetUser().GetLogin(),
+				State:    strings.ToLower(r.GetState()),
+				CommitID: r.GetCommitID(),
+			})
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return reviews, nil
+}
+
+func (c *gitHubClient) GetBranchProtection(ctx context.Context, owner, name, branch string) (*BranchProtection, error) {
+	p, res, err := c.client.Repositories.GetBranchProtection(ctx, owner, name, branch)
+	if err != nil {
+		// GitHub also returns 404 if this bot does not have the admin privilege.
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	result := &BranchProtection{
+		RequiredStatusChecks: make([]string, 0),
+	}
+	if reviews := p.RequiredPullRequestReviews; reviews != nil {
+		result.RequiredApprovingReviews = reviews.RequiredApprovingReviewCount
+		result.DismissStaleReviews = reviews.DismissStaleReviews
+	}
+	if checks := p.RequiredStatusChecks; checks != nil {
+		result.RequiredStatusChecks = append(result.RequiredStatusChecks, checks.Contexts...)
+	}
+	return result, nil
+}
+
+func (c *gitHubClient) MergePullRequest(ctx context.Context, owner, name string, number int, sha string) error {
+	// XXX: By the behavior, github uses defautlt merge message
+	// if we specify `""` to `commitMessage`.
+	_, _, err := c.client.PullRequests.Merge(ctx, owner, name, number, "", &github.PullRequestOptions{
+		SHA: sha,
+	})
+	return err
+}
+
+func (c *gitHubClient) CreateTryBranch(ctx context.Context, owner, name string, number int, branch string) (string, error) {
+	refName := "refs/heads/" + branch
+
+	// Clean up the previous one. We continue to create it optimistically even if this fails.
+	c.client.Git.DeleteRef(ctx, owner, name, refName)
+
+	// see:
+	// https://github.com/voyagegroup/popuko/issues/93
+	// https://help.github.com/articles/checking-out-pull-requests-locally/
+	base := fmt.Sprintf("refs/pull/%d/merge", number)
+	ref, _, err := c.client.Git.GetRef(ctx, owner, name, base)
+	if err != nil {
+		return "", fmt.Errorf("cannot get reference about %v: %v", base, err)
+	}
+
+	ref, _, err = c.client.Git.CreateRef(ctx, owner, name, &github.Reference{
+		Ref:    &refName,
+		Object: ref.Object,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot create a new ref %v: %v", refName, err)
+	}
+
+	return ref.GetObject().GetSHA(), nil
+}
+
+func (c *gitHubClient) SupportsTryBranch() bool {
+	return true
+}
+
+func (c *gitHubClient) DeleteBranch(ctx context.Context, owner, name, branch string) error {
+	_, err := c.client.Git.DeleteRef(ctx, owner, name, "heads/"+branch)
+	return err
+}
+
+func (c *gitHubClient) GetCombinedStatus(ctx context.Context, owner, name, ref string) (*CombinedStatus, error) {
+	combined, _, err := c.client.Repositories.GetCombinedStatus(ctx, owner, name, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*CommitStatus, 0, len(combined.Statuses))
+	for _, s := range combined.Statuses {
+		statuses = append(statuses, &CommitStatus{
+			Context:     s.GetContext(),
+			State:       s.GetState(),
+			Description: s.GetDescription(),
+			TargetURL:   s.GetTargetURL(),
+		})
+	}
+
+	return &CombinedStatus{
+		State:    combined.GetState(),
+		Statuses: statuses,
+	}, nil
+}
+
+func (c *gitHubClient) ListCheckSuites(ctx context.Context, owner, name, ref string) ([]*CheckSuite, error) {
+	suites, _, err := c.client.Checks.ListCheckSuitesForRef(ctx, owner, name, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*CheckSuite, 0, len(suites.CheckSuites))
+	for _, s := range suites.CheckSuites {
+		result = append(result, &CheckSuite{
+			App:        s.GetApp().GetName(),
+			Status:     s.GetStatus(),
+			Conclusion: s.GetConclusion(),
+		})
+	}
+	return result, nil
+}
+
+func (c *gitHubClient) ListCheckRuns(ctx context.Context, owner, name, ref string) ([]*CheckRun, error) {
+	runs, _, err := c.client.Checks.ListCheckRunsForRef(ctx, owner, name, ref, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*CheckRun, 0, len(runs.CheckRuns))
+	for _, r := range runs.CheckRuns {
+		result = append(result, &CheckRun{
+			Name:       r.GetName(),
+			Status:     r.GetStatus(),
+			Conclusion: r.GetConclusion(),
+		})
+	}
+	return result, nil
+}
+
+func (c *gitHubClient) ListTeamMembers(ctx context.Context, org, slug string) ([]string, error) {
+	team, _, err := c.client.Teams.GetTeamBySlug(ctx, org, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0)
+	opt := &github.TeamListTeamMembersOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+	for {
+		list, res, err := c.client.Teams.ListTeamMembers(ctx, team.GetID(), opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range list {
+			members = append(members, u.GetLogin())
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opt.Page = res.NextPage
+	}
+
+	return members, nil
+}
+
+func fromGitHubPullRequest(pr *github.PullRequest) *PullRequest {
+	if pr == nil {
+		return nil
+	}
+
+	assignees := make([]string, 0, len(pr.Assignees))
+	for _, u := range pr.Assignees {
+		assignees = append(assignees, u.GetLogin())
+	}
+
+	reviewers := make([]string, 0, len(pr.RequestedReviewers))
+	for _, u := range pr.RequestedReviewers {
+		reviewers = append(reviewers, u.GetLogin())
+	}
+
+	teams := make([]string, 0, len(pr.RequestedTeams))
+	for _, t := range pr.RequestedTeams {
+		teams = append(teams, t.GetSlug())
+	}
+
+	labels := make([]string, 0, len(pr.Labels))
+	for _, l := range pr.Labels {
+		labels = append(labels, l.GetName())
+	}
+
+	return &PullRequest{
+		Number:             pr.GetNumber(),
+		Title:              pr.GetTitle(),
+		Body:               pr.GetBody(),
+		State:              pr.GetState(),
+		Draft:              pr.GetDraft(),
+		Merged:             pr.GetMerged(),
+		Mergeable:          pr.Mergeable,
+		User:               pr.GetUser().GetLogin(),
+		HeadRef:            pr.GetHead().GetRef(),
+		HeadSHA:            pr.GetHead().GetSHA(),
+		HeadOwner:          pr.GetHead().GetRepo().GetOwner().GetLogin(),
+		HeadName:           pr.GetHead().GetRepo().GetName(),
+		BaseRef:            pr.GetBase().GetRef(),
+		BaseOwner:          pr.GetBase().GetRepo().GetOwner().GetLogin(),
+		Labels:             labels,
+		Assignees:          assignees,
+		RequestedReviewers: reviewers,
+		RequestedTeams:     teams,
+	}
+}
@@ -0,0 +1,128 @@
+package forge
+
+import (
+	"net/http"
+
+	"github.com/google/go-github/v28/github"
+)
+
+type gitHubWebhook struct{}
+
+// NewGitHubWebhook returns the parser of webhooks sent from GitHub.
+func NewGitHubWebhook() Webhook {
+	return gitHubWebhook{}
+}
+
+func (gitHubWebhook) ValidatePayload(req *http.Request, secret []byte) ([]byte, error) {
+	return github.ValidatePayload(req, secret)
+}
+
+func (gitHubWebhook) EventType(req *http.Request) string {
+	return github.WebHookType(req)
+}
+
+func (gitHubWebhook) DeliveryID(req *http.Request) string {
+	return github.DeliveryID(req)
+}
+
+func (gitHubWebhook) ParseWebhook(eventType string, payload []byte) (Event, error) {
+	ev, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ev := ev.(type) {
+	case *github.IssueCommentEvent:
+		return &CommentEvent{
+			Repository:    fromGitHubRepository(ev.GetRepo()),
+			Action:        ev.GetAction(),
+			Number:        ev.GetIssue().GetNumber(),
+			IsPullRequest: ev.GetIssue().IsPullRequest(),
+			IssueUser:     ev.GetIssue().GetUser().GetLogin(),
+			CommentID:     ev.GetComment().GetID(),
+			Body:          ev.GetComment().GetBody(),
+			Sender:        ev.GetSender().GetLogin(),
+		}, nil
+	case *github.PullRequestReviewCommentEvent:
+		return &CommentEvent{
+			Repository:    fromGitHubRepository(ev.GetRepo()),
+			Action:        ev.GetAction(),
+			Number:        ev.GetPullRequest().GetNumber(),
+			IsPullRequest: true,
+			IssueUser:     ev.GetPullRequest().GetUser().GetLogin(),
+			Kind:          CommentKindReviewComment,
+			CommentID:     ev.GetComment().GetID(),
+			Body:          ev.GetComment().GetBody(),
+			Sender:        ev.GetSender().GetLogin(),
+		}, nil
+	case *github.PushEvent:
+		// The owner of the push event's repository has `name` instead of `login`.
+		repo := ev.GetRepo()
+		return &PushEvent{
+			Repository: Repository{
+				Owner:         repo.GetOwner().GetName(),
+				Name:          repo.GetName(),
+				DefaultBranch: repo.GetDefaultBranch(),
+			},
+			Ref:     ev.GetRef(),
+			After:   ev.GetAfter(),
+			Compare: ev.GetCompare(),
+		}, nil
+	case *github.StatusEvent:
+		branches := make([]string, 0, len(ev.Branches))
+		for _, b := range ev.Branches {
+			branches = append(branches, b.GetName())
+		}
+
+		return &StatusEvent{
+			Repository: fromGitHubRepository(ev.GetRepo()),
+			ID:         ev.GetID(),
+			SHA:        ev.GetSHA(),
+			State:      ev.GetState(),
+			Context:    ev.GetContext(),
+			Completed:  ev.GetState() != "pending",
+			Branches:   branches,
+		}, nil
+	case *github.CheckSuiteEvent:
+		suite := ev.GetCheckSuite()
+		return &StatusEvent{
+			Repository: fromGitHubRepository(ev.GetRepo()),
+			ID:         suite.GetID(),
+			SHA:        suite.GetHeadSHA(),
+			State:      suite.GetConclusion(),
+			Context:    suite.GetApp().GetName(),
+			Completed:  suite.GetStatus() == "completed",
+			Branches:   []string{suite.GetHeadBranch()},
+		}, nil
+	case *github.PullRequestEvent:
+		return &PullRequestEvent{
+			Repository:  fromGitHubRepository(ev.GetRepo()),
+			Action:      ev.GetAction(),
+			Sender:      ev.GetSender().GetLogin(),
+			Label:       ev.GetLabel().GetName(),
+			PullRequest: fromGitHubPullRequest(ev.GetPullRequest()),
+		}, nil
+	case *github.PullRequestReviewEvent:
+		review := ev.GetReview()
+		return &ReviewEvent{
+			Repository:  fromGitHubRepository(ev.GetRepo()),
+			Action:      ev.GetAction(),
+			ReviewID:    review.GetID(),
+			State:       review.GetState(),
+			Body:        review.GetBody(),
+			CommitID:    review.GetCommitID(),
+			Sender:      ev.GetSender().GetLogin(),
+			PullRequest: fromGitHubPullRequest(ev.GetPullRequest()),
+		}, nil
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+}
+
+func fromGitHubRepository(repo *github.Repository) Repository {
+	return Repository{
+		Owner:         repo.GetOwner().GetLogin(),
+		Name:          repo.GetName(),
+		DefaultBranch: repo.GetDefaultBranch(),
+	}
+}
@@ -1,6 +1,7 @@
 package input
 
 import (
+	"errors"
 	"log"
 	"strings"
 )
@@ -8,13 +9,44 @@ import (
 // ParseCommand is doing adhoc command parsing.
 // for the future, we should write an actual parser.
 func ParseCommand(raw string) (ok bool, cmd interface{}) {
+	cmd, err := parseCommand(raw)
+	if err != nil {
+		log.Printf("debug: parse error: %v\n", err)
+		return false, nil
+	}
+
+	return true, cmd
+}
+
+// SyntaxError returns the reason why `raw` is not a valid command.
+// This returns nil if `raw` is valid.
+func SyntaxError(raw string) error {
+	_, err := parseCommand(raw)
+	return err
+}
+
+// IsAddressedTo returns true if the first line of `raw` starts with `@<botName>`.
+// Such a comment is regarded as a command to the bot even if it's malformed.
+func IsAddressedTo(raw string, botName string) bool {
+	body := strings.TrimLeft(strings.Split(raw, "\n")[0], " \t")
+
+	mention := "@" + botName
+	if !strings.HasPrefix(body, mention) {
+		return false
+	}
+
+	rest := body[len(mention):]
+	return rest == "" || isWhitespace(rune(rest[0]))
+}
+
+func parseCommand(raw string) (interface{}, error) {
 	log.Printf("debug: input: %v\n", raw)
 	tmp := strings.Split(raw, "\n")
 
 	// If there are no possibility that the comment body is not formatted
 	// `@botname command`, stop to process.
 	if len(tmp) < 1 {
-		return false, nil
+		return nil, errors.New("the comment is empty")
 	}
 
 	body := tmp[0]
@@ -22,36 +54,50 @@ func ParseCommand(raw string) (ok bool, cmd interface{}) {
 
 	r := strings.NewReader(body)
 	p := newParser(r)
-	cmd, err := p.Parse()
-	if err != nil {
-		log.Printf("debug: parse error: %v\n", err)
-		return false, nil
-	}
-
-	return true, cmd
+	return p.Parse()
 }
 
 type AcceptChangesetCommand interface {
 	BotName() string
+	// Priority returns `<n>` of `p=<n>`. This does not change the order of the approved queue,
+	// but the item can bypass the closed tree if this is `merge.bypass_priority` or more.
+	Priority() int
 }
 
 type AcceptChangeByReviewerCommand struct {
-	botName string
+	botName  string
+	priority int
+}
+
+// NewAcceptChangeByReviewerCommand creates the command which is equal to `@<botName> r+`.
+func NewAcceptChangeByReviewerCommand(botName string) *AcceptChangeByReviewerCommand {
+	return &AcceptChangeByReviewerCommand{
+		botName: botName,
+	}
 }
 
 func (s *AcceptChangeByReviewerCommand) BotName() string {
 	return s.botName
 }
 
+func (s *AcceptChangeByReviewerCommand) Priority() int {
+	return s.priority
+}
+
 type AcceptChangeByOthersCommand struct {
 	botName  string
 	Reviewer []string
+	priority int
 }
 
 func (s *AcceptChangeByOthersCommand) BotName() string {
 	return s.botName
 }
 
+func (s *AcceptChangeByOthersCommand) Priority() int {
+	return s.priority
+}
+
 type AssignReviewerCommand struct {
 	Reviewer []string
 }
@@ -60,6 +106,33 @@ type CancelApprovedByReviewerCommand struct {
 	botName string
 }
 
+// NewCancelApprovedByReviewerCommand creates the command which is equal to `@<botName> r-`.
+func NewCancelApprovedByReviewerCommand(botName string) *CancelApprovedByReviewerCommand {
+	return &CancelApprovedByReviewerCommand{
+		botName: botName,
+	}
+}
+
 func (s *CancelApprovedByReviewerCommand) BotName() string {
 	return s.botName
 }
+
+// ProvisionLabelsCommand is `@<botName> labels` which creates or updates status labels in the repository.
+type ProvisionLabelsCommand struct {
+	botName string
+}
+
+func (s *ProvisionLabelsCommand) BotName() string {
+	return s.botName
+}
+
+// DependsCommand is `@<botName> depends=#<number>,<owner>/<repo>#<number>`
+// which declares pull requests to be merged before this one. `depends=none` clears them.
+type DependsCommand struct {
+	botName      string
+	Dependencies []*Reference
+}
+
+func (s *DependsCommand) BotName() string {
+	return s.botName
+}
@@ -216,6 +216,19 @@ func TestParseCommandValidCaseForAssignReviewerCommand(t *testing.T) {
 			input:    "   r? @reviewer-a   @reviewer-b",
 			expected: []string{"reviewer-a", "reviewer-b"},
 		},
+
+		TestCase{
+			input:    "r? @org/team",
+			expected: []string{"org/team"},
+		},
+		TestCase{
+			input:    "r? @org-a/team-a @reviewer",
+			expected: []string{"org-a/team-a", "reviewer"},
+		},
+		TestCase{
+			input:    "@org/team r?",
+			expected: []string{"org/team"},
+		},
 	}
 	for _, testcase := range list {
 		input := testcase.input
@@ -304,6 +317,139 @@ func TestParseCommandValidCaseForCancelApprovedByReviewerCommand(t *testing.T) {
 	}
 }
 
+func TestParseCommandValidCaseForProvisionLabelsCommand(t *testing.T) {
+	type TestCase struct {
+		input           string
+		expectedBotName string
+	}
+
+	list := []TestCase{
+		TestCase{
+			input:           "@bot labels",
+			expectedBotName: "bot",
+		},
+		TestCase{
+			input:           "   @bot-bot    labels  ",
+			expectedBotName: "bot-bot",
+		},
+		TestCase{
+			input:           "@labels labels",
+			expectedBotName: "labels",
+		},
+	}
+	for _, testcase := range list {
+		input := testcase.input
+
+		ok, cmd := ParseCommand(input)
+		if !ok {
+			t.Errorf("input: `%v` should be ok", input)
+			continue
+		}
+
+		v, ok := cmd.(*ProvisionLabelsCommand)
+		if !ok {
+			t.Errorf("input: `%v` should be ProvisionLabelsCommand", input)
+			continue
+		}
+
+		expected := testcase.expectedBotName
+		if actual := v.BotName(); actual != expected {
+			t.Errorf("input: `%v` should be the expected bot (`%v`) name but `%v`", input, expected, actual)
+			continue
+		}
+	}
+
+	// `labels` is also a valid user name.
+	if ok, cmd := ParseCommand("r? @labels"); !ok {
+		t.Errorf("`r? @labels` should be ok")
+	} else if v, ok := cmd.(*AssignReviewerCommand); !ok || v.Reviewer[0] != "labels" {
+		t.Errorf("`r? @labels` should assign `labels` but %+v", cmd)
+	}
+}
+
+func TestParseCommandValidCaseForDependsCommand(t *testing.T) {
+	type TestCase struct {
+		input    string
+		expected []Reference
+	}
+
+	list := []TestCase{
+		TestCase{
+			input:    "@bot depends=#1",
+			expected: []Reference{Reference{Number: 1}},
+		},
+		TestCase{
+			input:    "  @bot depends= #1 , voyagegroup/popuko#23  ",
+			expected: []Reference{Reference{Number: 1}, Reference{Owner: "voyagegroup", Name: "popuko", Number: 23}},
+		},
+		TestCase{
+			input:    "@bot depends=none",
+			expected: []Reference{},
+		},
+	}
+	for _, testcase := range list {
+		input := testcase.input
+
+		ok, cmd := ParseCommand(input)
+		if !ok {
+			t.Errorf("input: `%v` should be ok", input)
+			continue
+		}
+
+		v, ok := cmd.(*DependsCommand)
+		if !ok {
+			t.Errorf("input: `%v` should be DependsCommand", input)
+			continue
+		}
+
+		if v.BotName() != "bot" {
+			t.Errorf("input: `%v` should be the bot name `bot` but `%v`", input, v.BotName())
+			continue
+		}
+
+		if len(v.Dependencies) != len(testcase.expected) {
+			t.Errorf("input: `%v` should have %v dependencies but %v", input, len(testcase.expected), len(v.Dependencies))
+			continue
+		}
+
+		for i, actual := range v.Dependencies {
+			if *actual != testcase.expected[i] {
+				t.Errorf("input: `%v` should be %+v but %+v", input, testcase.expected[i], *actual)
+				continue
+			}
+		}
+	}
+}
+
+func TestParseDependencies(t *testing.T) {
+	body := `This needs the API in the server.
+
+Depends-on: #1
+depends on: voyagegroup/popuko#2, #3 and #x
+ Depends-On:owner/repo.js#4
+This also depends-on: #5`
+
+	expected := []Reference{
+		Reference{Number: 1},
+		Reference{Owner: "voyagegroup", Name: "popuko", Number: 2},
+		Reference{Number: 3},
+		Reference{Owner: "owner", Name: "repo.js", Number: 4},
+	}
+
+	actual := ParseDependencies(body)
+	if len(actual) != len(expected) {
+		t.Errorf("should have %v dependencies but %v", len(expected), len(actual))
+		return
+	}
+
+	for i, ref := range actual {
+		if *ref != expected[i] {
+			t.Errorf("#%v should be %+v but %+v", i, expected[i], *ref)
+			return
+		}
+	}
+}
+
 func TestParseCommandInvalidCase(t *testing.T) {
 	input := []string{
 		"Hello, I'm john.",
@@ -370,6 +516,45 @@ func TestParseCommandInvalidCase(t *testing.T) {
     r? @bot`,
 		`r?
     @bot`,
+
+		// labels
+		"@bot labels r+",
+		"@bot @bot2 labels",
+		"@bot label",
+		" @ bot labels",
+
+		// r? @org/team
+		"r? @org/",
+		"r? @org /team",
+		"r? @org/ team",
+		"r? @org/team/sub",
+
+		// p=priority
+		"@bot r+ p",
+		"@bot r+ p=",
+		"@bot r+ p = 1",
+		"@bot r+ p=high",
+		"@bot r+ q=1",
+		"@bot r+ p=1 p=2",
+		"@bot r=a p=1,b",
+		"@bot r- p=1",
+		"@bot r? p=1",
+
+		// depends
+		"@bot depends",
+		"@bot depends=",
+		"@bot depends =#1",
+		"@bot depends=1",
+		"@bot depends=#",
+		"@bot depends=#a",
+		"@bot depends=#0",
+		"@bot depends=#1,",
+		"@bot depends=#1 #2",
+		"@bot depends=owner#1",
+		"@bot depends=owner/#1",
+		"@bot depends=owner/repo/sub#1",
+		"@bot depends=none,#1",
+		"@bot @bot2 depends=#1",
 	}
 	for _, item := range input {
 		if ok, _ := ParseCommand(item); ok {
@@ -377,3 +562,75 @@ func TestParseCommandInvalidCase(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCommandPriority(t *testing.T) {
+	type TestCase struct {
+		input    string
+		expected int
+	}
+
+	list := []TestCase{
+		TestCase{"@bot r+", 0},
+		TestCase{"@bot r+ p=1", 1},
+		TestCase{"@bot r+   p=10  ", 10},
+		TestCase{"@bot r+ p=-1", -1},
+		TestCase{"@bot r=popuko p=2", 2},
+		TestCase{"@bot r=popuko, pipimi p=3", 3},
+	}
+	for _, testcase := range list {
+		input := testcase.input
+
+		ok, cmd := ParseCommand(input)
+		if !ok {
+			t.Errorf("input: `%v` should be ok", input)
+			continue
+		}
+
+		v, ok := cmd.(AcceptChangesetCommand)
+		if !ok {
+			t.Errorf("input: `%v` should be AcceptChangesetCommand", input)
+			continue
+		}
+
+		if actual := v.Priority(); actual != testcase.expected {
+			t.Errorf("input: `%v` should be the priority %v but %v", input, testcase.expected, actual)
+			continue
+		}
+	}
+}
+
+func TestIsAddressedTo(t *testing.T) {
+	type Testcase struct {
+		input    string
+		expected bool
+	}
+
+	list := []Testcase{
+		Testcase{"@bot r+", true},
+		Testcase{"  @bot r +", true},
+		Testcase{"@bot", true},
+		Testcase{"@bot\n r+", true},
+		Testcase{"@botanist r+", false},
+		Testcase{"r? @bot", false},
+		Testcase{"Hello @bot", false},
+		Testcase{"", false},
+	}
+	for _, item := range list {
+		if actual := IsAddressedTo(item.input, "bot"); actual != item.expected {
+			t.Errorf("`%v` should be %v but %v", item.input, item.expected, actual)
+			continue
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	if err := SyntaxError("@bot r+"); err != nil {
+		t.Errorf("`@bot r+` should be valid but %v", err)
+		return
+	}
+
+	if err := SyntaxError("@bot r +"); err == nil {
+		t.Errorf("`@bot r +` should be invalid")
+		return
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strconv"
 )
 
 type parser struct {
@@ -51,7 +52,25 @@ func (p *parser) parseAskToUser() (interface{}, error) {
 	}
 
 	tok, lit := p.scanIgnoreWhitespace()
-	if tok == CommandReject {
+	if tok == CommandDepends {
+		if len(person) > 1 {
+			return nil, fmt.Errorf("found person is %v, person should be only 1", len(person))
+		}
+
+		return p.parseDepends(person[0])
+	} else if tok == CommandLabels {
+		if len(person) > 1 {
+			return nil, fmt.Errorf("found person is %v, person should be only 1", len(person))
+		}
+
+		if tok, lit = p.scanIgnoreWhitespace(); tok != EOF {
+			return nil, fmt.Errorf("found %q, expected EOF", lit)
+		}
+
+		return &ProvisionLabelsCommand{
+			botName: person[0],
+		}, nil
+	} else if tok == CommandReject {
 		if len(person) > 1 {
 			return nil, fmt.Errorf("found person is %v, person should be only 1", len(person))
 		}
@@ -96,12 +115,10 @@ func (p *parser) parseAskToUser() (interface{}, error) {
 			}
 			reviewer = append(reviewer, lit)
 
-			tok, lit = p.scanIgnoreWhitespace()
-			if tok == EOF {
+			// The list of reviewers ends with EOF or options (e.g. `p=1`).
+			if tok, _ = p.scanIgnoreWhitespace(); tok != Comma {
 				p.unscan()
 				break
-			} else if tok != Comma {
-				return nil, fmt.Errorf("found %q, expected Comma", lit)
 			}
 		}
 
@@ -113,6 +130,21 @@ func (p *parser) parseAskToUser() (interface{}, error) {
 		return nil, fmt.Errorf("found %q, should not come its token", lit)
 	}
 
+	switch cmd := result.(type) {
+	case *AcceptChangeByReviewerCommand:
+		priority, err := p.parsePriority()
+		if err != nil {
+			return nil, err
+		}
+		cmd.priority = priority
+	case *AcceptChangeByOthersCommand:
+		priority, err := p.parsePriority()
+		if err != nil {
+			return nil, err
+		}
+		cmd.priority = priority
+	}
+
 	if tok, lit = p.scanIgnoreWhitespace(); tok != EOF {
 		return nil, fmt.Errorf("found %q, expected EOF", lit)
 	}
@@ -120,6 +152,106 @@ func (p *parser) parseAskToUser() (interface{}, error) {
 	return result, nil
 }
 
+// parseDepends parses `depends=#<number>,<owner>/<repo>#<number>` or `depends=none`.
+func (p *parser) parseDepends(botName string) (interface{}, error) {
+	if tok, lit := p.scan(); tok != Equal {
+		return nil, fmt.Errorf("found %q, expected Equal", lit)
+	}
+
+	result := &DependsCommand{
+		botName:      botName,
+		Dependencies: make([]*Reference, 0, 1),
+	}
+
+	if tok, lit := p.scanIgnoreWhitespace(); tok == Ident && lit == "none" {
+		if tok, lit = p.scanIgnoreWhitespace(); tok != EOF {
+			return nil, fmt.Errorf("found %q, expected EOF", lit)
+		}
+		return result, nil
+	}
+	p.unscan()
+
+	for {
+		ref, err := p.parseReference()
+		if err != nil {
+			return nil, err
+		}
+		result.Dependencies = append(result.Dependencies, ref)
+
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok == EOF {
+			break
+		} else if tok != Comma {
+			return nil, fmt.Errorf("found %q, expected Comma", lit)
+		}
+	}
+
+	return result, nil
+}
+
+// parseReference parses `#<number>` or `<owner>/<repo>#<number>`.
+func (p *parser) parseReference() (*Reference, error) {
+	ref := &Reference{}
+
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok == Ident {
+		ref.Owner = lit
+
+		if tok, lit = p.scan(); tok != Slash {
+			return nil, fmt.Errorf("found %q, expected Slash", lit)
+		}
+
+		if tok, lit = p.scan(); tok != Ident {
+			return nil, fmt.Errorf("found %q, expected Ident", lit)
+		}
+		ref.Name = lit
+
+		tok, lit = p.scan()
+	}
+
+	if tok != Hash {
+		return nil, fmt.Errorf("found %q, expected Hash", lit)
+	}
+
+	if tok, lit = p.scan(); tok != Ident {
+		return nil, fmt.Errorf("found %q, expected Ident", lit)
+	}
+
+	number, err := strconv.Atoi(lit)
+	if err != nil || number < 1 {
+		return nil, fmt.Errorf("found %q, expected the number of the pull request", lit)
+	}
+	ref.Number = number
+
+	return ref, nil
+}
+
+// parsePriority parses the optional `p=<number>` after `r+` or `r=<reviewer>`.
+// This returns 0 if it's omitted.
+func (p *parser) parsePriority() (int, error) {
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != Ident || lit != "p" {
+		p.unscan()
+		return 0, nil
+	}
+
+	if tok, lit = p.scan(); tok != Equal {
+		return 0, fmt.Errorf("found %q, expected Equal", lit)
+	}
+
+	tok, lit = p.scan()
+	if tok != Ident {
+		return 0, fmt.Errorf("found %q, expected Ident", lit)
+	}
+
+	priority, err := strconv.Atoi(lit)
+	if err != nil {
+		return 0, fmt.Errorf("found %q, expected the number", lit)
+	}
+
+	return priority, nil
+}
+
 func (p *parser) parseAskReview() (interface{}, error) {
 	if tok, lit := p.scan(); tok != Question {
 		return nil, fmt.Errorf("found %q, expected Question", lit)
@@ -155,12 +287,26 @@ func (p *parser) parseUserIDCall() (string, error) {
 	}
 
 	tok, lit := p.scan()
+	// `labels` and `depends` are also valid user names.
+	if tok != Ident && tok != CommandLabels && tok != CommandDepends {
+		p.unscan()
+		return "", fmt.Errorf("found %q, expected Ident", lit)
+	}
+
+	// `@org/team` style refers to a team in the organization.
+	if tok, _ := p.scan(); tok != Slash {
+		p.unscan()
+		return lit, nil
+	}
+
+	org := lit
+	tok, lit = p.scan()
 	if tok != Ident {
 		p.unscan()
 		return "", fmt.Errorf("found %q, expected Ident", lit)
 	}
 
-	return lit, nil
+	return org + "/" + lit, nil
 }
 
 func (p *parser) scan() (token, string) {
@@ -189,5 +335,5 @@ func (p *parser) unscan() {
 }
 
 func isCommand(t token) bool {
-	return (t == CommandReview) || (t == CommandReject)
+	return (t == CommandReview) || (t == CommandReject) || (t == CommandLabels) || (t == CommandDepends)
 }
@@ -0,0 +1,48 @@
+package input
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Reference refers to a pull request by `#<number>` or `<owner>/<repo>#<number>`.
+// `Owner` and `Name` are empty for `#<number>` which refers to the same repository.
+type Reference struct {
+	Owner  string
+	Name   string
+	Number int
+}
+
+// e.g. `Depends-on: #1, voyagegroup/popuko#2`
+var dependsOnLine = regexp.MustCompile(`(?im)^\s*depends[- ]on\s*:(.*)$`)
+
+var referencePattern = regexp.MustCompile(`^(?:([\w.-]+)/([\w.-]+))?#(\d+)$`)
+
+// ParseDependencies returns pull requests declared by `Depends-on:` lines in the description of a pull request.
+// Invalid references are ignored.
+func ParseDependencies(body string) []*Reference {
+	list := make([]*Reference, 0)
+	for _, line := range dependsOnLine.FindAllStringSubmatch(body, -1) {
+		for _, field := range strings.FieldsFunc(line[1], func(r rune) bool {
+			return r == ',' || isWhitespace(r)
+		}) {
+			m := referencePattern.FindStringSubmatch(field)
+			if m == nil {
+				continue
+			}
+
+			number, err := strconv.Atoi(m[3])
+			if err != nil || number < 1 {
+				continue
+			}
+
+			list = append(list, &Reference{
+				Owner:  m[1],
+				Name:   m[2],
+				Number: number,
+			})
+		}
+	}
+	return list
+}
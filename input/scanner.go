@@ -22,10 +22,14 @@ const (
 
 	// Misc characters
 	Comma // ,
+	Slash // /
+	Hash  // #
 
 	// Keywords
-	CommandReview // r
-	CommandReject // r-
+	CommandReview  // r
+	CommandReject  // r-
+	CommandLabels  // labels
+	CommandDepends // depends
 
 	Equal    // =
 	Question // ?
@@ -61,6 +65,10 @@ func (s *scanner) Scan() (tok token, literal string) {
 		return EOF, ""
 	case ',':
 		return Comma, literal
+	case '/':
+		return Slash, literal
+	case '#':
+		return Hash, literal
 	case '=':
 		return Equal, literal
 	case '?':
@@ -115,6 +123,10 @@ func (s *scanner) scanIdentifier() (tok token, literal string) {
 		return CommandReview, literal
 	case "r-":
 		return CommandReject, literal
+	case "labels":
+		return CommandLabels, literal
+	case "depends":
+		return CommandDepends, literal
 	}
 
 	return Ident, literal
@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"errors"
 
+	"github.com/voyagegroup/popuko/epic"
+	"github.com/voyagegroup/popuko/event"
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/notify"
 	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
 	"github.com/voyagegroup/popuko/setting"
+	"github.com/voyagegroup/popuko/store"
 )
 
 var config *setting.Settings
@@ -22,6 +32,10 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplayCommand(os.Args[2:]))
+	}
+
 	var configDir string
 	{
 		c := "Specify the config dir as absolute path. default: $" + setting.XdgConfigHomeEnvKey + "/" + setting.HomeDirName
@@ -105,26 +119,176 @@ func main() {
 		return
 	}
 
-	q := queue.NewAutoMergeQRepo(root)
-	if q == nil {
-		log.Println("Fail to initialize the merge queue")
+	notifier := notify.NewNotifier(config.Notifications)
+	if notifier == nil {
+		log.Println("Fail to initialize the notifications")
 		return
 	}
+	log.Printf("notification destinations: %v\n", len(config.Notifications))
 
-	server := AppServer{
-		githubClient:  github,
-		autoMergeRepo: q,
-		setting:       config,
+	server := &AppServer{
+		client:     forge.NewGitHubClient(github),
+		webhook:    forge.NewGitHubWebhook(),
+		botName:    config.BotNameForGithub(),
+		hookSecret: config.WebHookSecret(),
+		acceptRepo: config.AcceptRepo,
+		restPrefix: prefixRestAPI,
+		setting:    config,
+		notifier:   notifier,
+	}
+	if ok := setupAppServer(server, root); !ok {
+		return
 	}
 
-	http.HandleFunc(prefixWebHookPath, server.handleGithubHook)
+	startAppServer(server)
+	servers := []*AppServer{server}
+	http.HandleFunc(prefixWebHookPath, server.handleWebhook)
 	http.HandleFunc("/", server.handleRESTApiRequest)
 
+	if config.EnableGitea() {
+		log.Printf("botname for Gitea: %v\n", "@"+config.BotNameForGitea())
+		gitea := &AppServer{
+			client:     forge.NewGiteaClient(config.Gitea.BaseURL, config.GiteaToken(), nil),
+			webhook:    forge.NewGiteaWebhook(),
+			botName:    config.BotNameForGitea(),
+			hookSecret: config.GiteaWebHookSecret(),
+			acceptRepo: config.AcceptGiteaRepo,
+			restPrefix: prefixGiteaRestAPI,
+			setting:    config,
+			notifier:   notifier,
+		}
+		// Separate the states from GitHub's because the same repository name may exist on both.
+		if ok := setupAppServer(gitea, filepath.Join(root, "gitea")); !ok {
+			return
+		}
+
+		startAppServer(gitea)
+		servers = append(servers, gitea)
+		http.HandleFunc(prefixGiteaWebHookPath, gitea.handleWebhook)
+		http.HandleFunc(prefixGiteaWebHookPath+"/", gitea.handleRESTApiRequest)
+	}
+
+	httpServer := &http.Server{Addr: config.PortStr()}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		shutdownOnSignal(httpServer, servers)
+	}()
+
 	if useTLS {
-		http.ListenAndServeTLS(config.PortStr(), certPath, keyPath, nil)
+		err = httpServer.ListenAndServeTLS(certPath, keyPath)
 	} else {
-		http.ListenAndServe(config.PortStr(), nil)
+		err = httpServer.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		log.Printf("error: %v\n", err)
+		return
+	}
+
+	<-stopped
+	log.Println("info: shutdown completed")
+}
+
+// The time to wait for the HTTP server to finish handling requests on the shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// shutdownOnSignal stops `httpServer` on SIGINT or SIGTERM,
+// and waits for `servers` to finish their deliveries, notifications and mergeability checks in progress.
+// Pending deliveries are kept on the disk and processed after restarting.
+func shutdownOnSignal(httpServer *http.Server, servers []*AppServer) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("info: shutdown by %v\n", <-sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("warn: could not stop the HTTP server gracefully: %v\n", err)
+	}
+
+	for _, srv := range servers {
+		srv.eventQueue.Close()
+	}
+
+	// Deliveries may have started checking the mergeability, and these checks may notify.
+	for _, srv := range servers {
+		srv.unmergeableDetector.Shutdown()
 	}
+	for _, srv := range servers {
+		srv.notifier.Wait()
+	}
+}
+
+// setupAppServer initializes the storages of `srv` under `root`.
+func setupAppServer(srv *AppServer, root string) bool {
+	q := queue.NewAutoMergeQRepo(root)
+	if q == nil {
+		log.Println("Fail to initialize the merge queue")
+		return false
+	}
+	srv.autoMergeRepo = q
+
+	assignStore := store.NewFileStore(root, "assign")
+	if assignStore == nil {
+		log.Println("Fail to initialize the storage for assigning reviewers")
+		return false
+	}
+	srv.assignStore = assignStore
+
+	availabilityStore := store.NewFileStore(root, "availability")
+	if availabilityStore == nil {
+		log.Println("Fail to initialize the storage for reviewers' availability")
+		return false
+	}
+	srv.availabilityStore = availabilityStore
+
+	deliveryHistory := event.NewHistory(root)
+	if deliveryHistory == nil {
+		log.Println("Fail to initialize the delivery history")
+		return false
+	}
+	srv.deliveryHistory = deliveryHistory
+
+	mergeabilityStore := store.NewFileStore(root, "mergeability")
+	if mergeabilityStore == nil {
+		log.Println("Fail to initialize the storage for the mergeability of pull requests")
+		return false
+	}
+	config := srv.setting
+
+	statusStore := store.NewFileStore(root, "status")
+	if statusStore == nil {
+		log.Println("Fail to initialize the storage for status comments")
+		return false
+	}
+	srv.reporter = report.NewReporter(statusStore, config.StickyStatusComment, srv.botName)
+
+	srv.unmergeableDetector = epic.NewUnmergeableDetector(srv.client, srv.notifier, srv.reporter, mergeabilityStore,
+		config.UnmergeableCheckBurstCount(), config.UnmergeableCheckInterval())
+
+	// Deliveries which have not been processed before the last shutdown are restored here.
+	eventQueue := event.NewQueue(root, srv.processDelivery, deliveryHistory)
+	if eventQueue == nil {
+		log.Println("Fail to initialize the event queue")
+		return false
+	}
+	srv.eventQueue = eventQueue
+
+	return true
+}
+
+// startAppServer starts the workers of webhooks and scheduled tasks for `srv`.
+func startAppServer(srv *AppServer) {
+	config := srv.setting
+
+	log.Printf("webhook workers: %v\n", config.WebHookWorkerCount())
+	srv.eventQueue.Start(config.WebHookWorkerCount())
+
+	log.Printf("reconcile queues every: %v\n", config.ReconcileInterval())
+	startScheduler("reconcile queues", config.ReconcileInterval(), srv.reconcileQueues)
+	startScheduler("check timeouts", timeoutCheckInterval, srv.checkActiveItemTimeouts)
+	startScheduler("resume held queues", mergeWindowCheckInterval, srv.resumeHeldQueues)
+	startScheduler("prune deliveries", deliveryPruneInterval, srv.pruneDeliveries)
 }
 
 func checkPath(path string) (fullpath string, err error) {
@@ -0,0 +1,194 @@
+// Package notify sends events of the queue to chats and generic webhooks.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// Kinds of events. These are used as `events` in `[[notification]]`.
+const (
+	EventMerged        = "merged"
+	EventTestsFailed   = "tests_failed"
+	EventMergeConflict = "merge_conflict"
+	EventHeadChanged   = "head_changed"
+	EventQueueStalled  = "queue_stalled"
+	// Sent once when the queue starts to be held by the merge windows or freezes in `OWNERS.json`.
+	EventTreeClosed = "tree_closed"
+)
+
+type Event struct {
+	Kind   string `json:"event"`
+	Owner  string `json:"owner"`
+	Name   string `json:"name"`
+	Number int    `json:"number,omitempty"`
+	SHA    string `json:"sha,omitempty"`
+	// The human readable summary of the event.
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+const defaultTemplate = "{{.Owner}}/{{.Name}}{{if .Number}}#{{.Number}}{{end}}: {{.Message}}"
+
+// The first interval to retry a failed delivery. This is doubled on each retry.
+const defaultBackoff = 2 * time.Second
+
+type target struct {
+	setting.NotificationSetting
+	tmpl *template.Template
+}
+
+// Notifier delivers events to destinations in `config.toml`.
+// A nil Notifier is valid and does nothing.
+type Notifier struct {
+	targets []*target
+	client  *http.Client
+	backoff time.Duration
+	wg      sync.WaitGroup
+}
+
+func NewNotifier(list []setting.NotificationSetting) *Notifier {
+	targets := make([]*target, 0, len(list))
+	for _, s := range list {
+		switch s.Type {
+		case setting.NotificationTypeSlack, setting.NotificationTypeWebhook:
+		default:
+			log.Printf("error: the notification type `%v` is unknown\n", s.Type)
+			return nil
+		}
+
+		if s.URL == "" {
+			log.Printf("error: the notification (%v) does not have `url`\n", s.Type)
+			return nil
+		}
+
+		text := s.Template
+		if text == "" {
+			text = defaultTemplate
+		}
+
+		tmpl, err := template.New(s.URL).Parse(text)
+		if err != nil {
+			log.Printf("error: the notification template is invalid: %v\n", err)
+			return nil
+		}
+
+		targets = append(targets, &target{
+			NotificationSetting: s,
+			tmpl:                tmpl,
+		})
+	}
+
+	return &Notifier{
+		targets: targets,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		backoff: defaultBackoff,
+	}
+}
+
+// Notify sends `ev` to destinations which accept it in background.
+func (n *Notifier) Notify(ev *Event) {
+	if n == nil {
+		return
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	for _, t := range n.targets {
+		if !t.AcceptRepo(ev.Owner, ev.Name) || !t.AcceptEvent(ev.Kind) {
+			continue
+		}
+
+		body, err := t.payload(ev)
+		if err != nil {
+			log.Printf("warn: could not render the notification `%v` for %v/%v: %v\n", ev.Kind, ev.Owner, ev.Name, err)
+			continue
+		}
+
+		n.wg.Add(1)
+		go func(t *target) {
+			defer n.wg.Done()
+			n.deliver(t, ev, body)
+		}(t)
+	}
+}
+
+// Wait blocks until all deliveries in background finish.
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
+
+func (t *target) payload(ev *Event) ([]byte, error) {
+	var text bytes.Buffer
+	if err := t.tmpl.Execute(&text, ev); err != nil {
+		return nil, err
+	}
+
+	switch t.Type {
+	case setting.NotificationTypeSlack:
+		return json.Marshal(map[string]string{
+			"text": text.String(),
+		})
+	default:
+		return json.Marshal(struct {
+			*Event
+			Text string `json:"text"`
+		}{
+			Event: ev,
+			Text:  text.String(),
+		})
+	}
+}
+
+func (n *Notifier) deliver(t *target, ev *Event, body []byte) {
+	wait := n.backoff
+	retries := t.RetryCount()
+	for i := 0; ; i++ {
+		retriable, err := n.post(t.URL, body)
+		if err == nil {
+			log.Printf("info: notified `%v` for %v/%v to %v\n", ev.Kind, ev.Owner, ev.Name, t.Type)
+			return
+		}
+
+		if !retriable || i >= retries {
+			log.Printf("warn: could not notify `%v` for %v/%v to %v: %v\n", ev.Kind, ev.Owner, ev.Name, t.Type, err)
+			return
+		}
+
+		log.Printf("info: retry to notify `%v` after %v: %v\n", ev.Kind, wait, err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post returns whether the delivery can be retried if it fails.
+func (n *Notifier) post(url string, body []byte) (retriable bool, err error) {
+	res, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("the destination replies `%v`", res.Status)
+	retriable = res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+	return retriable, err
+}
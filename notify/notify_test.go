@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/voyagegroup/popuko/setting"
+)
+
+type testReceiver struct {
+	mux      sync.Mutex
+	failures int
+	bodies   []map[string]interface{}
+}
+
+func (r *testReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var body map[string]interface{}
+	json.NewDecoder(req.Body).Decode(&body)
+	r.bodies = append(r.bodies, body)
+	rw.WriteHeader(http.StatusOK)
+}
+
+func TestNotifySlack(t *testing.T) {
+	r := &testReceiver{
+		failures: 2,
+	}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	n := NewNotifier([]setting.NotificationSetting{
+		setting.NotificationSetting{
+			Type:         setting.NotificationTypeSlack,
+			URL:          srv.URL,
+			Repositories: []string{"foo/bar"},
+			Events:       []string{EventMerged},
+			Template:     "{{.Kind}} #{{.Number}}",
+		},
+	})
+	n.backoff = 0
+
+	n.Notify(&Event{Kind: EventMerged, Owner: "foo", Name: "bar", Number: 1})
+	n.Notify(&Event{Kind: EventTestsFailed, Owner: "foo", Name: "bar", Number: 2})
+	n.Notify(&Event{Kind: EventMerged, Owner: "foo", Name: "baz", Number: 3})
+	n.Wait()
+
+	if len(r.bodies) != 1 {
+		t.Errorf("should deliver only the accepted event after retries: %v", r.bodies)
+		return
+	}
+
+	if text := r.bodies[0]["text"]; text != "merged #1" {
+		t.Errorf("unexpected text: %v", text)
+		return
+	}
+}
+
+func TestNotifyWebhookGiveUp(t *testing.T) {
+	r := &testReceiver{
+		failures: 10,
+	}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	retries := 2
+	n := NewNotifier([]setting.NotificationSetting{
+		setting.NotificationSetting{
+			Type:    setting.NotificationTypeWebhook,
+			URL:     srv.URL,
+			Retries: &retries,
+		},
+	})
+	n.backoff = 0
+
+	n.Notify(&Event{Kind: EventQueueStalled, Owner: "foo", Name: "bar"})
+	n.Wait()
+
+	if r.failures != 7 {
+		t.Errorf("should try 3 times but %v", 10-r.failures)
+		return
+	}
+}
+
+func TestNotifyWebhookPayload(t *testing.T) {
+	r := &testReceiver{}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	n := NewNotifier([]setting.NotificationSetting{
+		setting.NotificationSetting{
+			Type: setting.NotificationTypeWebhook,
+			URL:  srv.URL,
+		},
+	})
+
+	n.Notify(&Event{Kind: EventMergeConflict, Owner: "foo", Name: "bar", Number: 4, Message: "conflict"})
+	n.Wait()
+
+	if len(r.bodies) != 1 {
+		t.Errorf("should deliver the event: %v", r.bodies)
+		return
+	}
+
+	body := r.bodies[0]
+	if body["event"] != EventMergeConflict || body["number"] != float64(4) || body["text"] != "foo/bar#4: conflict" {
+		t.Errorf("unexpected payload: %v", body)
+		return
+	}
+}
+
+func TestNewNotifierInvalid(t *testing.T) {
+	type Testcase struct {
+		input setting.NotificationSetting
+	}
+	list := []Testcase{
+		Testcase{
+			input: setting.NotificationSetting{Type: "mail", URL: "http://example.com"},
+		},
+		Testcase{
+			input: setting.NotificationSetting{Type: setting.NotificationTypeSlack},
+		},
+		Testcase{
+			input: setting.NotificationSetting{Type: setting.NotificationTypeSlack, URL: "http://example.com", Template: "{{"},
+		},
+	}
+
+	for i, test := range list {
+		if n := NewNotifier([]setting.NotificationSetting{test.input}); n != nil {
+			t.Errorf("#%v should be rejected", i)
+		}
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	var n *Notifier
+	n.Notify(&Event{Kind: EventMerged})
+	n.Wait()
+}
@@ -2,63 +2,46 @@ package operation
 
 import (
 	"context"
-	"fmt"
 	"log"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
 )
 
-func createAutoBranch(ctx context.Context, svc *github.GitService, owner string, repo string, number int, branchName string) (ok bool, ref *github.Reference) {
-	refName := "refs/heads/" + branchName
-
-	log.Printf("info: clean up %v by deleting it\n", refName)
-	if _, err := svc.DeleteRef(ctx, owner, repo, refName); err != nil {
-		log.Printf("info: could not clean up %v by %v, but we continue to create %v optimistically\n", refName, err, refName)
-	}
-
-	// see:
-	// https://github.com/voyagegroup/popuko/issues/93
-	// https://help.github.com/articles/checking-out-pull-requests-locally/
-	base := fmt.Sprintf("refs/pull/%d/merge", number)
-	log.Printf("debug: `ref` is: %v\n", base)
-	ref, _, err := svc.GetRef(ctx, owner, repo, base)
-	if err != nil {
-		log.Printf("warn: cannot get reference about %v\n", base)
-		return
-	}
-
-	branchRef := github.Reference{
-		Ref:    &refName,
-		URL:    nil, // XXX: This field is unused on creating ref.
-		Object: ref.Object,
+func TryWithDefaultBranch(ctx context.Context, client forge.Client, reporter *report.Reporter, owner string, name string, info *forge.PullRequest, autoBranch string) (bool, string) {
+	number := info.Number
+
+	sha, err := client.CreateTryBranch(ctx, owner, name, number, autoBranch)
+	if err == forge.ErrUnsupportedTryBranch {
+		log.Println("info: the forge cannot create the auto branch")
+		c := ":no_entry_sign: Auto-Merging is not supported on this forge because it cannot test this merged into its base branch. " +
+			"Please merge this manually, or disable `auto_merge.enabled` in `OWNERS.json`."
+		t := &report.Transition{
+			Kind:    report.KindBlocked,
+			Message: c,
+			SHA:     info.HeadSHA,
+		}
+		if ok := reporter.Report(ctx, client, owner, name, number, t); !ok {
+			log.Println("info: could not create the comment about the unsupported auto branch.")
+		}
+		return false, ""
 	}
-
-	ref, _, err = svc.CreateRef(ctx, owner, repo, &branchRef)
 	if err != nil {
-		log.Printf("warn: cannot create a new ref %v\n", refName)
-		return
-	}
-
-	return true, ref
-}
-
-func TryWithDefaultBranch(ctx context.Context, client *github.Client, owner string, name string, info *github.PullRequest, autoBranch string) (bool, string) {
-	number := *info.Number
-
-	ok, ref := createAutoBranch(ctx, client.Git, owner, name, number, autoBranch)
-	if !ok {
-		log.Println("info: cannot create the auto branch")
+		log.Printf("info: cannot create the auto branch: %v\n", err)
 		return false, ""
 	}
 	log.Println("info: create the auto branch")
 
-	sha := *ref.Object.SHA
-
 	{
-		number := *info.Number
-		headSha := *info.Head.SHA
+		headSha := info.HeadSHA
 		c := ":hourglass: " + headSha + " has been merged into the auto branch " + sha
-		if ok := AddComment(ctx, client.Issues, owner, name, number, c); !ok {
+		t := &report.Transition{
+			Kind:    report.KindTesting,
+			Message: c,
+			SHA:     headSha,
+		}
+		if ok := reporter.Report(ctx, client, owner, name, number, t); !ok {
 			log.Println("info: could not create the comment to declare to merge this.")
 		}
 	}
@@ -66,15 +49,15 @@ func TryWithDefaultBranch(ctx context.Context, client *github.Client, owner stri
 	return true, sha
 }
 
-func DeleteBranchByPullRequest(ctx context.Context, svc *github.GitService, pr *github.PullRequest) (bool, error) {
-	owner := *pr.Head.Repo.Owner.Login
+func DeleteBranchByPullRequest(ctx context.Context, client forge.Client, pr *forge.PullRequest) (bool, error) {
+	owner := pr.HeadOwner
 	log.Printf("debug: branch owner: %v\n", owner)
-	repo := *pr.Head.Repo.Name
+	repo := pr.HeadName
 	log.Printf("debug: repo: %v\n", repo)
-	branch := *pr.Head.Ref
+	branch := pr.HeadRef
 	log.Printf("debug: head ref: %v\n", branch)
 
-	_, err := svc.DeleteRef(ctx, owner, repo, "heads/"+branch)
+	err := client.DeleteBranch(ctx, owner, repo, branch)
 	if err != nil {
 		log.Println("info: could not delete the merged branch.")
 		return false, err
@@ -83,26 +66,27 @@ func DeleteBranchByPullRequest(ctx context.Context, svc *github.GitService, pr *
 	return true, nil
 }
 
-func MergePullRequest(ctx context.Context, client *github.Client, owner string, name string, info *github.PullRequest, acceptedSha string) bool {
-	number := *info.Number
+func MergePullRequest(ctx context.Context, client forge.Client, reporter *report.Reporter, labels *setting.StatusLabels, owner string, name string, info *forge.PullRequest, acceptedSha string) bool {
+	number := info.Number
 
 	// Even if we checks the head at here, the new commits may be pushed from user
-	// before we merge it actually. To prevent to such case, we also set `github.PullRequestOptions.SHA`.
-	if acceptedSha != *info.Head.SHA {
-		CommentHeadIsDifferentFromAccepted(ctx, client.Issues, owner, name, number)
+	// before we merge it actually. To prevent to such case, we also pass the sha to the forge.
+	if acceptedSha != info.HeadSHA {
+		CommentHeadIsDifferentFromAccepted(ctx, client, reporter, labels, owner, name, number)
 		return false
 	}
-	option := &github.PullRequestOptions{
-		SHA: acceptedSha, // To ensure that we only accept the accepted changeset.
-	}
 
-	// XXX: By the behavior, github uses defautlt merge message
-	// if we specify `""` to `commitMessage`.
-	_, _, err := client.PullRequests.Merge(ctx, owner, name, number, "", option)
+	// To ensure that we only accept the accepted changeset.
+	err := client.MergePullRequest(ctx, owner, name, number, acceptedSha)
 	if err != nil {
 		log.Println("warn: could not merge pull request")
 		comment := ":skull:　Could not merge this pull request by:\n```\n" + err.Error() + "\n```"
-		if ok := AddComment(ctx, client.Issues, owner, name, number, comment); !ok {
+		t := &report.Transition{
+			Kind:    report.KindMergeFailed,
+			Message: comment,
+			SHA:     acceptedSha,
+		}
+		if ok := reporter.Report(ctx, client, owner, name, number, t); !ok {
 			log.Println("warn: could not create the comment to express no merging the pull request")
 		}
 		return false
@@ -111,17 +95,9 @@ func MergePullRequest(ctx context.Context, client *github.Client, owner string,
 	return true
 }
 
-func IsIncludeAutoBranch(branches []*github.Branch, auto string) bool {
+func IsIncludeAutoBranch(branches []string, auto string) bool {
 	for _, b := range branches {
-		if b == nil {
-			continue
-		}
-
-		if b.Name == nil {
-			continue
-		}
-
-		if *b.Name == auto {
+		if b == auto {
 			return true
 		}
 	}
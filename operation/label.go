@@ -3,51 +3,39 @@ package operation
 import (
 	"context"
 	"log"
-	"strings"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/setting"
 )
 
-const (
-	STATUS_LABEL_PREFIX             string = "S-"
-	LABEL_AWAITING_REVIEW           string = "S-awaiting-review"
-	LABEL_AWAITING_MERGE            string = "S-awaiting-merge"
-	LABEL_NEEDS_REBASE              string = "S-needs-rebase"
-	LABEL_FAILS_TESTS_WITH_UPSTREAM string = "S-fails-tests-with-upstream"
-)
+func AddAwaitingReviewLabel(labels *setting.StatusLabels, list []string) []string {
+	return changeStatusLabel(labels, list, labels.AwaitingReview.Name)
+}
 
-func AddAwaitingReviewLabel(list []*github.Label) []string {
-	return changeStatusLabel(list, LABEL_AWAITING_REVIEW)
+func AddAwaitingMergeLabel(labels *setting.StatusLabels, list []string) []string {
+	return changeStatusLabel(labels, list, labels.AwaitingMerge.Name)
 }
 
-func AddAwaitingMergeLabel(list []*github.Label) []string {
-	return changeStatusLabel(list, LABEL_AWAITING_MERGE)
+func AddNeedRebaseLabel(labels *setting.StatusLabels, list []string) []string {
+	return changeStatusLabel(labels, list, labels.NeedsRebase.Name)
 }
 
-func AddNeedRebaseLabel(list []*github.Label) []string {
-	return changeStatusLabel(list, LABEL_NEEDS_REBASE)
+func AddFailsTestsWithUpsreamLabel(labels *setting.StatusLabels, list []string) []string {
+	return changeStatusLabel(labels, list, labels.FailsTestsWithUpstream.Name)
 }
 
-func AddFailsTestsWithUpsreamLabel(list []*github.Label) []string {
-	return changeStatusLabel(list, LABEL_FAILS_TESTS_WITH_UPSTREAM)
+func AddTimedOutWithUpstreamLabel(labels *setting.StatusLabels, list []string) []string {
+	return changeStatusLabel(labels, list, labels.TimedOutWithUpstream.Name)
 }
 
-func changeStatusLabel(list []*github.Label, new string) []string {
-	result := make([]string, 0, 0)
-	for _, item := range list {
-		label := *item.Name
-		if strings.Index(label, STATUS_LABEL_PREFIX) == 0 {
-			continue
-		} else {
-			result = append(result, label)
-		}
-	}
+func changeStatusLabel(labels *setting.StatusLabels, list []string, new string) []string {
+	result := RemoveStatusLabelFromList(labels, list)
 	result = append(result, new)
 	return result
 }
 
-func GetLabelsByIssue(ctx context.Context, issueSvc *github.IssuesService, owner string, name string, issue int) []*github.Label {
-	currentLabels, _, err := issueSvc.ListLabelsByIssue(ctx, owner, name, issue, nil)
+func GetLabelsByIssue(ctx context.Context, client forge.Client, owner string, name string, issue int) []string {
+	currentLabels, err := client.GetLabels(ctx, owner, name, issue)
 	if err != nil {
 		log.Println("info: could not get labels by the issue")
 		log.Printf("debug: %v\n", err)
@@ -57,9 +45,8 @@ func GetLabelsByIssue(ctx context.Context, issueSvc *github.IssuesService, owner
 	return currentLabels
 }
 
-func HasLabelInList(list []*github.Label, target string) bool {
-	for _, item := range list {
-		label := *item.Name
+func HasLabelInList(list []string, target string) bool {
+	for _, label := range list {
 		if label == target {
 			return true
 		}
@@ -67,11 +54,10 @@ func HasLabelInList(list []*github.Label, target string) bool {
 	return false
 }
 
-func RemoveStatusLabelFromList(list []*github.Label) []string {
+func RemoveStatusLabelFromList(labels *setting.StatusLabels, list []string) []string {
 	r := make([]string, 0)
-	for _, item := range list {
-		label := *item.Name
-		if !strings.HasPrefix(label, STATUS_LABEL_PREFIX) {
+	for _, label := range list {
+		if !labels.IsStatusLabel(label) {
 			r = append(r, label)
 		}
 	}
@@ -3,21 +3,21 @@ package operation
 import (
 	"context"
 	"log"
-	"strings"
 	"time"
 
-	"github.com/google/go-github/v28/github"
+	"github.com/voyagegroup/popuko/forge"
 )
 
-func IsMergeable(ctx context.Context, prSvc *github.PullRequestsService, owner, name string, issue int, pr *github.PullRequest) (bool, bool) {
-	return isMergeable(ctx, prSvc, owner, name, issue, pr, 0)
+func IsMergeable(ctx context.Context, client forge.Client, owner, name string, issue int, pr *forge.PullRequest) (bool, bool) {
+	return isMergeable(ctx, client, owner, name, issue, pr, 0)
 }
 
-func isMergeable(ctx context.Context, prSvc *github.PullRequestsService, owner, name string, issue int, pr *github.PullRequest, nest uint) (bool, bool) {
+func isMergeable(ctx context.Context, client forge.Client, owner, name string, issue int, pr *forge.PullRequest, nest uint) (bool, bool) {
 	mergeable := pr.Mergeable
 	if mergeable == nil {
 		// By the document https://developer.github.com/v3/pulls/#get-a-single-pull-request
 		// this state is still in checking if pr.Mergeable == nil.
+		// We also regard so on other forges.
 		if nest > 1 {
 			// We tried once.
 			// We conclude that the pull request is mergeable.
@@ -30,59 +30,54 @@ func isMergeable(ctx context.Context, prSvc *github.PullRequestsService, owner,
 		// sleep same time: https://github.com/barosl/homu/blob/2104e4b154d2fba15d515b478a5bd6105c1522f6/homu/main.py#L722
 		time.Sleep(5 * time.Second)
 
-		pr, _, err := prSvc.Get(ctx, owner, name, issue)
+		pr, err := client.GetPullRequest(ctx, owner, name, issue)
 		if err != nil || pr == nil {
 			log.Printf("info: could not get the info for #%v\n", issue)
 			log.Printf("debug: %v\n", err)
 			return false, false
 		}
-		return isMergeable(ctx, prSvc, owner, name, issue, pr, nest+1)
+		return isMergeable(ctx, client, owner, name, issue, pr, nest+1)
 	}
 
-	return true, *mergeable
-}
+	if !*mergeable && pr.MergeableUnsettled && nest <= 1 {
+		// The forge may be still checking conflicts. Unlike the unknown state,
+		// we conclude that it's not mergeable if it continues after retries.
+		time.Sleep(5 * time.Second)
 
-func IsRelatedToDefaultBranch(pr *github.PullRequest, owner, master string) bool {
-	base := pr.Base
-	if base == nil {
-		log.Printf("info: #%v's Base is `nil`\n", *pr.Number)
-		return false
+		pr, err := client.GetPullRequest(ctx, owner, name, issue)
+		if err != nil || pr == nil {
+			log.Printf("info: could not get the info for #%v\n", issue)
+			log.Printf("debug: %v\n", err)
+			return false, false
+		}
+		return isMergeable(ctx, client, owner, name, issue, pr, nest+1)
 	}
 
-	baseRef := base.Ref
-	if baseRef == nil {
-		log.Printf("info: #%v's Base.Ref is `nil`\n", *pr.Number)
-		return false
-	}
+	return true, *mergeable
+}
 
-	if *baseRef != master {
-		log.Printf("info: #%v's Base.Ref is not equals to `%v`\n", *pr.Number, master)
+func IsRelatedToDefaultBranch(pr *forge.PullRequest, owner, master string) bool {
+	if pr.BaseRef != master {
+		log.Printf("info: #%v's base ref is `%v` but our master is `%v`.\n", pr.Number, pr.BaseRef, master)
 		return false
 	}
 
-	baseLabel := base.Label
-	if baseLabel == nil {
-		log.Printf("info: #%v's Base.Label is `nil`\n", *pr.Number)
+	// Check the pr is not for the forked one.
+	if pr.BaseOwner != owner {
+		log.Printf("info: #%v is for `%v` which is not related to us\n", pr.Number, pr.BaseOwner)
 		return false
 	}
 
-	// Check the pr is from the forked one.
-	if strings.Contains(*baseLabel, ":") {
-		if !strings.HasPrefix(*baseLabel, owner) {
-			log.Printf("info: #%v is come from the forked but `%v` is not related to us\n", *pr.Number, *baseLabel)
-			return false
-		}
+	return true
+}
 
-		if !strings.HasSuffix(*baseLabel, master) {
-			log.Printf("info: #%v is come from the forked but `%v` is not related to our default branch (%v)\n", *pr.Number, *baseLabel, master)
-			return false
-		}
-	} else {
-		if *baseLabel != master {
-			log.Printf("info: #%v's base is `%v` but our master is `%v`.", *pr.Number, *baseLabel, master)
-			return false
-		}
+func GetChangedFiles(ctx context.Context, client forge.Client, owner, name string, number int) (bool, []string) {
+	files, err := client.ListChangedFiles(ctx, owner, name, number)
+	if err != nil {
+		log.Printf("info: could not get the changed files of #%v\n", number)
+		log.Printf("debug: %v\n", err)
+		return false, nil
 	}
 
-	return true
+	return true, files
 }
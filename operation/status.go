@@ -0,0 +1,104 @@
+package operation
+
+import (
+	"context"
+	"log"
+
+	"github.com/voyagegroup/popuko/forge"
+)
+
+// GetCIResult returns the result of CI for `sha` by both of commit statuses and check suites.
+//   - `completed` is false if some CI is still running or nothing has been reported yet.
+//   - `state` is "success" or the non successful state (e.g. "failure") if `completed` is true.
+func GetCIResult(ctx context.Context, client forge.Client, owner, name, sha string) (ok bool, completed bool, state string) {
+	combined, err := client.GetCombinedStatus(ctx, owner, name, sha)
+	if err != nil {
+		log.Printf("info: could not get the combined status for %v: %v\n", sha, err)
+		return false, false, ""
+	}
+
+	suites, err := client.ListCheckSuites(ctx, owner, name, sha)
+	if err != nil {
+		log.Printf("info: could not get check suites for %v: %v\n", sha, err)
+		return false, false, ""
+	}
+
+	completed, state = summarizeCIResult(combined, suites)
+	return true, completed, state
+}
+
+// We ignore a check suite which is still "queued" because GitHub creates a check suite
+// for each installed GitHub App even if it never runs for the commit.
+func summarizeCIResult(combined *forge.CombinedStatus, suites []*forge.CheckSuite) (completed bool, state string) {
+	hasStatus := combined != nil && len(combined.Statuses) > 0
+	if hasStatus {
+		switch s := combined.State; s {
+		case "pending":
+			return false, ""
+		case "success":
+		default:
+			return true, s
+		}
+	}
+
+	hasSuite := false
+	for _, suite := range suites {
+		switch suite.Status {
+		case "queued":
+			continue
+		case "completed":
+		default:
+			return false, ""
+		}
+
+		hasSuite = true
+		switch c := suite.Conclusion; c {
+		case "success", "neutral", "skipped":
+		default:
+			return true, c
+		}
+	}
+
+	if !hasStatus && !hasSuite {
+		return false, ""
+	}
+
+	return true, "success"
+}
+
+// GetFailingContexts returns names of commit statuses and check runs which did not succeed for `sha`.
+func GetFailingContexts(ctx context.Context, client forge.Client, owner, name, sha string) (bool, []string) {
+	result := make([]string, 0)
+
+	combined, err := client.GetCombinedStatus(ctx, owner, name, sha)
+	if err != nil {
+		log.Printf("info: could not get the combined status for %v: %v\n", sha, err)
+		return false, nil
+	}
+
+	for _, s := range combined.Statuses {
+		if state := s.State; state == "failure" || state == "error" {
+			result = append(result, s.Context)
+		}
+	}
+
+	runs, err := client.ListCheckRuns(ctx, owner, name, sha)
+	if err != nil {
+		log.Printf("info: could not get check runs for %v: %v\n", sha, err)
+		return false, nil
+	}
+
+	for _, run := range runs {
+		if run.Status != "completed" {
+			continue
+		}
+
+		switch run.Conclusion {
+		case "success", "neutral", "skipped":
+		default:
+			result = append(result, run.Name)
+		}
+	}
+
+	return true, result
+}
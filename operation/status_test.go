@@ -0,0 +1,95 @@
+package operation
+
+import (
+	"testing"
+
+	"github.com/voyagegroup/popuko/forge"
+)
+
+func newCombinedStatus(state string, total int) *forge.CombinedStatus {
+	statuses := make([]*forge.CommitStatus, 0, total)
+	for i := 0; i < total; i++ {
+		statuses = append(statuses, &forge.CommitStatus{
+			State: state,
+		})
+	}
+
+	return &forge.CombinedStatus{
+		State:    state,
+		Statuses: statuses,
+	}
+}
+
+func newCheckSuite(status, conclusion string) *forge.CheckSuite {
+	return &forge.CheckSuite{
+		Status:     status,
+		Conclusion: conclusion,
+	}
+}
+
+func Test_summarizeCIResult(t *testing.T) {
+	type Testcase struct {
+		combined  *forge.CombinedStatus
+		suites    []*forge.CheckSuite
+		completed bool
+		state     string
+	}
+	list := []Testcase{
+		Testcase{
+			combined:  newCombinedStatus("pending", 0),
+			completed: false,
+		},
+		Testcase{
+			combined:  newCombinedStatus("pending", 1),
+			completed: false,
+		},
+		Testcase{
+			combined:  newCombinedStatus("success", 1),
+			completed: true,
+			state:     "success",
+		},
+		Testcase{
+			combined:  newCombinedStatus("failure", 2),
+			completed: true,
+			state:     "failure",
+		},
+		Testcase{
+			combined: newCombinedStatus("success", 1),
+			suites: []*forge.CheckSuite{
+				newCheckSuite("in_progress", ""),
+			},
+			completed: false,
+		},
+		Testcase{
+			combined: newCombinedStatus("pending", 0),
+			suites: []*forge.CheckSuite{
+				newCheckSuite("queued", ""),
+				newCheckSuite("completed", "success"),
+			},
+			completed: true,
+			state:     "success",
+		},
+		Testcase{
+			combined: newCombinedStatus("pending", 0),
+			suites: []*forge.CheckSuite{
+				newCheckSuite("completed", "timed_out"),
+			},
+			completed: true,
+			state:     "timed_out",
+		},
+		Testcase{
+			combined: newCombinedStatus("pending", 0),
+			suites: []*forge.CheckSuite{
+				newCheckSuite("queued", ""),
+			},
+			completed: false,
+		},
+	}
+
+	for i, test := range list {
+		completed, state := summarizeCIResult(test.combined, test.suites)
+		if completed != test.completed || state != test.state {
+			t.Errorf("#%v should be (%v, `%v`) but (%v, `%v`)", i, test.completed, test.state, completed, state)
+		}
+	}
+}
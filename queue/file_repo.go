@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -161,6 +162,40 @@ func (s *fileRepository) readMergeQueue(owner, name string) []byte {
 	return b
 }
 
+// list returns `owner/name` pairs which have the queue file.
+func (s *fileRepository) list() [][2]string {
+	result := make([][2]string, 0)
+
+	owners, err := ioutil.ReadDir(s.rootPath)
+	if err != nil {
+		log.Printf("error: cannot read the queue dir: %v\n", err)
+		return result
+	}
+
+	for _, owner := range owners {
+		if !owner.IsDir() {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(s.rootPath, owner.Name()))
+		if err != nil {
+			log.Printf("error: cannot read the queue dir for %v: %v\n", owner.Name(), err)
+			continue
+		}
+
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+				continue
+			}
+
+			name := strings.TrimSuffix(f.Name(), ".json")
+			result = append(result, [2]string{owner.Name(), name})
+		}
+	}
+
+	return result
+}
+
 func exists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil
@@ -188,7 +223,7 @@ func validPathFragment(p string) bool {
 }
 
 // XXX: Update this field when change the data struct.
-const fileFmtVersion int32 = 0
+const fileFmtVersion int32 = 1
 
 type autoMergeQFile struct {
 	Version int32 `json:"version"`
@@ -196,6 +231,9 @@ type autoMergeQFile struct {
 		Queue   []*AutoMergeQueueItem `json:"queue"`
 		Current *AutoMergeQueueItem   `json:"current_active"`
 	} `json:"auto_merge"`
+	Approvals map[int]*Approval `json:"approvals,omitempty"`
+	// Dependencies declared by `depends=` per pull request.
+	Dependencies map[int][]*Dependency `json:"dependencies,omitempty"`
 }
 
 func decodeByteToAutoMergeQueue(b []byte) *AutoMergeQueue {
@@ -206,8 +244,10 @@ func decodeByteToAutoMergeQueue(b []byte) *AutoMergeQueue {
 	}
 
 	q := AutoMergeQueue{
-		q:       result.Auto.Queue,
-		current: result.Auto.Current,
+		q:            result.Auto.Queue,
+		current:      result.Auto.Current,
+		approvals:    result.Approvals,
+		dependencies: result.Dependencies,
 	}
 
 	return &q
@@ -223,6 +263,8 @@ func encodeAutoMergeQueueToByte(queue *AutoMergeQueue) []byte {
 			Queue:   queue.q,
 			Current: queue.current,
 		},
+		Approvals:    queue.approvals,
+		Dependencies: queue.dependencies,
 	}
 
 	b, err := json.MarshalIndent(c, "", "  ")
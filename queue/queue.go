@@ -4,11 +4,12 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 )
 
 type AutoMergeQRepo struct {
 	mux     sync.Mutex
-	repo    fileRepository
+	repo    *fileRepository
 	qHandle map[string]*AutoMergeQueueHandle
 }
 
@@ -20,7 +21,7 @@ func NewAutoMergeQRepo(root string) *AutoMergeQRepo {
 
 	return &AutoMergeQRepo{
 		mux:     sync.Mutex{},
-		repo:    *repo,
+		repo:    repo,
 		qHandle: make(map[string]*AutoMergeQueueHandle),
 	}
 }
@@ -47,6 +48,17 @@ func (s *AutoMergeQRepo) Get(owner string, name string) *AutoMergeQueueHandle {
 	return h
 }
 
+// List returns handles for all queues which have been saved.
+func (s *AutoMergeQRepo) List() []*AutoMergeQueueHandle {
+	list := make([]*AutoMergeQueueHandle, 0)
+	for _, pair := range s.repo.list() {
+		if h := s.Get(pair[0], pair[1]); h != nil {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
 func (s *AutoMergeQRepo) save(owner string, name string, v *AutoMergeQueue) {
 	if ok := s.repo.save(owner, name, v); !ok {
 		log.Printf("error: cannot save the queue information for %v/%v\n", owner, name)
@@ -61,6 +73,14 @@ type AutoMergeQueueHandle struct {
 	parent *AutoMergeQRepo
 }
 
+func (s *AutoMergeQueueHandle) Owner() string {
+	return s.owner
+}
+
+func (s *AutoMergeQueueHandle) Name() string {
+	return s.name
+}
+
 func (s *AutoMergeQueueHandle) Lock() {
 	s.mux.Lock()
 }
@@ -99,6 +119,10 @@ type AutoMergeQueue struct {
 
 	q       []*AutoMergeQueueItem
 	current *AutoMergeQueueItem
+
+	approvals map[int]*Approval
+
+	dependencies map[int][]*Dependency
 }
 
 func (s *AutoMergeQueue) Save() {
@@ -117,6 +141,19 @@ func (s *AutoMergeQueue) Push(item *AutoMergeQueueItem) bool {
 	return true
 }
 
+// PushFront inserts `item` at the front of the queue.
+// This is used to put back the item which has been taken from the queue.
+func (s *AutoMergeQueue) PushFront(item *AutoMergeQueueItem) bool {
+	for _, elm := range s.q {
+		if elm.PullRequest == item.PullRequest {
+			return false
+		}
+	}
+
+	s.q = append([]*AutoMergeQueueItem{item}, s.q...)
+	return true
+}
+
 func (s *AutoMergeQueue) TakeNext() (ok bool, item *AutoMergeQueueItem) {
 	if len(s.q) == 0 {
 		return true, nil
@@ -141,6 +178,13 @@ func (s *AutoMergeQueue) Front() *AutoMergeQueueItem {
 	return s.q[0]
 }
 
+// Awaiting returns the copy of the list of awaiting items.
+func (s *AutoMergeQueue) Awaiting() []*AutoMergeQueueItem {
+	list := make([]*AutoMergeQueueItem, len(s.q))
+	copy(list, s.q)
+	return list
+}
+
 func (s *AutoMergeQueue) IsAwaiting(pr int) (ok bool, item *AutoMergeQueueItem) {
 	for _, item := range s.q {
 		if item.PullRequest == pr {
@@ -158,7 +202,7 @@ func (s *AutoMergeQueue) RemoveAwaiting(pr int) (found bool) {
 		return true
 	}
 
-	n := make([]*AutoMergeQueueItem, 0, len(s.q)-1) // create small slice with huristic buffer size.
+	n := make([]*AutoMergeQueueItem, 0, len(s.q))
 	for _, item := range s.q {
 		if item.PullRequest == pr {
 			found = true
@@ -199,4 +243,101 @@ type AutoMergeQueueItem struct {
 	PrHead string `json:"pr_head_sha"`
 	// The head sha of the branch which trying to merge into the upstream
 	AutoBranchHead *string `json:"auto_head_sha"`
+	// The time when this bot has started to try the auto branch.
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// The number of retries of the auto branch after its failure.
+	Retries int `json:"retries,omitempty"`
+	// The priority given by `p=<n>`. This does not change the order of the queue.
+	// The item can be merged while the tree is closed if this is `merge.bypass_priority` or more.
+	Priority int `json:"priority,omitempty"`
+	// Whether this bot has told that this item is waiting for the merge window.
+	WaitingForWindow bool `json:"waiting_for_window,omitempty"`
+	// Pull requests which must be merged before this item.
+	DependsOn []*Dependency `json:"depends_on,omitempty"`
+}
+
+// AddApproval records that `reviewer` approves `head` of the pull request.
+// If `head` is different from the recorded one, this discards all approvals for the old head.
+func (s *AutoMergeQueue) AddApproval(pr int, head string, reviewer string) *Approval {
+	if s.approvals == nil {
+		s.approvals = make(map[int]*Approval)
+	}
+
+	a, ok := s.approvals[pr]
+	if !ok || a.PrHead != head {
+		a = &Approval{
+			PrHead:    head,
+			Reviewers: make([]string, 0, 1),
+		}
+		s.approvals[pr] = a
+	}
+
+	for _, r := range a.Reviewers {
+		if r == reviewer {
+			return a
+		}
+	}
+
+	a.Reviewers = append(a.Reviewers, reviewer)
+	return a
+}
+
+func (s *AutoMergeQueue) GetApproval(pr int) *Approval {
+	return s.approvals[pr]
+}
+
+func (s *AutoMergeQueue) RemoveApproval(pr int) (found bool) {
+	if _, found = s.approvals[pr]; found {
+		delete(s.approvals, pr)
+	}
+	return found
+}
+
+type Approval struct {
+	// The head sha of the pull request which is approved.
+	PrHead string `json:"pr_head_sha"`
+	// The distinct reviewers who approve `PrHead`.
+	Reviewers []string `json:"reviewers"`
+}
+
+// SetDependencies records pull requests which are declared by `depends=` as dependencies of `pr`.
+// An empty list removes them.
+func (s *AutoMergeQueue) SetDependencies(pr int, list []*Dependency) {
+	if len(list) == 0 {
+		delete(s.dependencies, pr)
+		return
+	}
+
+	if s.dependencies == nil {
+		s.dependencies = make(map[int][]*Dependency)
+	}
+	s.dependencies[pr] = list
+}
+
+func (s *AutoMergeQueue) GetDependencies(pr int) []*Dependency {
+	return s.dependencies[pr]
+}
+
+func (s *AutoMergeQueue) RemoveDependencies(pr int) (found bool) {
+	if _, found = s.dependencies[pr]; found {
+		delete(s.dependencies, pr)
+	}
+	return found
+}
+
+// Dependency refers to a pull request which must be merged before another one.
+type Dependency struct {
+	Owner  string `json:"owner"`
+	Name   string `json:"name"`
+	Number int    `json:"number"`
+}
+
+// String returns `<owner>/<name>#<number>`.
+func (d *Dependency) String() string {
+	return fmt.Sprintf("%v/%v#%v", d.Owner, d.Name, d.Number)
+}
+
+// Is returns true if `d` refers to #number in owner/name.
+func (d *Dependency) Is(owner, name string, number int) bool {
+	return d.Owner == owner && d.Name == name && d.Number == number
 }
@@ -70,3 +70,90 @@ func Test_AutoMergeQueue_RemoveAwaiting2(t *testing.T) {
 		return
 	}
 }
+
+// Should discard approvals for the old head.
+func Test_AutoMergeQueue_AddApproval(t *testing.T) {
+	const number int = 1
+
+	queue := AutoMergeQueue{}
+	queue.AddApproval(number, "a", "alice")
+	queue.AddApproval(number, "a", "alice")
+	if a := queue.AddApproval(number, "a", "bob"); len(a.Reviewers) != 2 {
+		t.Errorf("should have 2 distinct reviewers but %v", a.Reviewers)
+		return
+	}
+
+	if a := queue.AddApproval(number, "b", "bob"); len(a.Reviewers) != 1 || a.PrHead != "b" {
+		t.Errorf("should reset approvals for the new head but %+v", a)
+		return
+	}
+
+	if ok := queue.RemoveApproval(number); !ok {
+		t.Errorf("should be success to remove the approval")
+		return
+	}
+
+	if queue.GetApproval(number) != nil {
+		t.Errorf("queue.GetApproval() should be nil")
+		return
+	}
+}
+
+// Should keep the order of the arrival regardless of the priority.
+func Test_AutoMergeQueue_PushWithPriority(t *testing.T) {
+	queue := AutoMergeQueue{}
+	list := []*AutoMergeQueueItem{
+		&AutoMergeQueueItem{PullRequest: 1},
+		&AutoMergeQueueItem{PullRequest: 2, Priority: 1},
+		&AutoMergeQueueItem{PullRequest: 3},
+		&AutoMergeQueueItem{PullRequest: 4, Priority: -1},
+	}
+	for _, item := range list {
+		if ok := queue.Push(item); !ok {
+			t.Errorf("should be success to push #%v", item.PullRequest)
+			return
+		}
+	}
+
+	expected := []int{1, 2, 3, 4}
+	for i, item := range queue.Awaiting() {
+		if item.PullRequest != expected[i] {
+			t.Errorf("#%v should be #%v but #%v", i, expected[i], item.PullRequest)
+			return
+		}
+	}
+}
+
+// Should put back the item at the front of the queue.
+func Test_AutoMergeQueue_PushFront(t *testing.T) {
+	queue := AutoMergeQueue{}
+	list := []*AutoMergeQueueItem{
+		&AutoMergeQueueItem{PullRequest: 1, Priority: 1},
+		&AutoMergeQueueItem{PullRequest: 2},
+		&AutoMergeQueueItem{PullRequest: 3},
+	}
+	for _, item := range list {
+		if ok := queue.Push(item); !ok {
+			t.Errorf("should be success to push #%v", item.PullRequest)
+			return
+		}
+	}
+
+	if ok := queue.PushFront(&AutoMergeQueueItem{PullRequest: 4}); !ok {
+		t.Errorf("should be success to push #4")
+		return
+	}
+
+	if ok := queue.PushFront(&AutoMergeQueueItem{PullRequest: 2}); ok {
+		t.Errorf("should not push the duplicated item")
+		return
+	}
+
+	expected := []int{4, 1, 2, 3}
+	for i, item := range queue.Awaiting() {
+		if item.PullRequest != expected[i] {
+			t.Errorf("#%v should be #%v but #%v", i, expected[i], item.PullRequest)
+			return
+		}
+	}
+}
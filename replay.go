@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/voyagegroup/popuko/setting"
+)
+
+// runReplayCommand asks the running server to replay a recorded delivery or a payload in a file.
+//
+//	popuko replay [options] <delivery id>
+//	popuko replay [options] -event <type> -file <payload.json>
+func runReplayCommand(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configDir := fs.String("config-base-dir", "", "Specify the config dir as absolute path. default: $"+setting.XdgConfigHomeEnvKey+"/"+setting.HomeDirName)
+	server := fs.String("server", "", "The base URL of the running server. (default: http://127.0.0.1:<port in config.toml>)")
+	forgeName := fs.String("forge", "github", "The forge which sent the delivery: `github` or `gitea`.")
+	eventType := fs.String("event", "", "The event type of the payload (the value of `X-GitHub-Event` or `X-Gitea-Event`). This is required with `-file`.")
+	file := fs.String("file", "", "Specify the path to the payload to replay instead of the delivery id.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %v replay [options] <delivery id>\n       %v replay [options] -event <type> -file <payload.json>\n", os.Args[0], os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	ok, root := setting.HomeDir(*configDir)
+	if !ok {
+		log.Println("info: cannot find the config dir fot this.")
+		return 1
+	}
+
+	s := setting.LoadSettings(root)
+	if s == nil {
+		log.Println("Cannot find $XDG_CONFIG_HOME/popuko" + setting.RootConfigFile)
+		return 1
+	}
+
+	var restPrefix, eventHeader string
+	switch *forgeName {
+	case "github":
+		restPrefix = prefixRestAPI
+		eventHeader = "X-GitHub-Event"
+	case "gitea":
+		restPrefix = prefixGiteaRestAPI
+		eventHeader = "X-Gitea-Event"
+	default:
+		log.Printf("error: unknown forge `%v`\n", *forgeName)
+		return 2
+	}
+
+	base := *server
+	if base == "" {
+		base = "http://127.0.0.1" + s.PortStr()
+	}
+	base = strings.TrimSuffix(base, "/") + restPrefix + prefixDeliveryAPI
+
+	var req *http.Request
+	switch {
+	case *file != "" && fs.NArg() == 0:
+		if *eventType == "" {
+			log.Println("error: `-event` is required with `-file`")
+			return 1
+		}
+
+		body, err := os.Open(*file)
+		if err != nil {
+			log.Printf("error: cannot open %v: %v\n", *file, err)
+			return 1
+		}
+		defer body.Close()
+
+		req, err = http.NewRequest("POST", base+"replay", body)
+		if err != nil {
+			log.Printf("error: %v\n", err)
+			return 1
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(eventHeader, *eventType)
+	case *file == "" && fs.NArg() == 1:
+		var err error
+		req, err = http.NewRequest("POST", base+fs.Arg(0)+"/replay", nil)
+		if err != nil {
+			log.Printf("error: %v\n", err)
+			return 1
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.APIToken())
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("error: cannot send the request: %v\n", err)
+		return 1
+	}
+	defer res.Body.Close()
+
+	b, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusAccepted {
+		log.Printf("error: %v: %s\n", res.Status, b)
+		return 1
+	}
+
+	fmt.Printf("replayed as %s\n", b)
+	return 0
+}
@@ -0,0 +1,315 @@
+// Package report posts transitions of pull requests (e.g. approved, testing or merged) as comments.
+//
+// By default, each transition is posted as a new comment.
+// If the sticky status comment is enabled, this keeps one status comment per pull request
+// which is identified by a hidden marker, and edits it in place with the current state.
+// Only transitions which need attention are posted as new comments in that mode.
+package report
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/store"
+)
+
+// Kinds of transitions.
+const (
+	KindPartiallyApproved = "partially_approved"
+	KindApproved          = "approved"
+	KindQueued            = "queued"
+	KindTesting           = "testing"
+	KindRetrying          = "retrying"
+	KindMerged            = "merged"
+	KindTestsFailed       = "tests_failed"
+	KindMergeFailed       = "merge_failed"
+	KindMergeConflict     = "merge_conflict"
+	KindHeadChanged       = "head_changed"
+	KindCancelled         = "cancelled"
+	KindTimedOut          = "timed_out"
+	KindBlocked           = "blocked"
+	KindRebased           = "rebased"
+	KindWaiting           = "waiting"
+)
+
+var kindTitles = map[string]string{
+	KindPartiallyApproved: ":bookmark: Waiting for more approvals",
+	KindApproved:          ":pushpin: Approved",
+	KindQueued:            ":postbox: Queued",
+	KindTesting:           ":hourglass: Testing with the upstream",
+	KindRetrying:          ":repeat: Retrying",
+	KindMerged:            ":tada: Merged",
+	KindTestsFailed:       ":collision: Tests failed",
+	KindMergeFailed:       ":skull: Could not merge",
+	KindMergeConflict:     ":lock: Merge conflict",
+	KindHeadChanged:       ":no_entry_sign: Head changed after the approval",
+	KindCancelled:         ":outbox_tray: Cancelled",
+	KindTimedOut:          ":hourglass_flowing_sand: Timed out",
+	KindBlocked:           ":construction: Blocked",
+	KindRebased:           ":arrows_counterclockwise: Rebased without changes",
+	KindWaiting:           ":zzz: Waiting for the merge window",
+}
+
+// These transitions require some actions by humans.
+// They are posted as new comments even if the sticky status comment is enabled.
+var attentionKinds = map[string]bool{
+	KindTestsFailed:   true,
+	KindMergeFailed:   true,
+	KindMergeConflict: true,
+	KindHeadChanged:   true,
+	KindTimedOut:      true,
+	KindBlocked:       true,
+}
+
+// The hidden marker to identify the status comment.
+const Marker = "<!-- popuko:status -->"
+
+// The maximum number of entries in the timeline.
+const maxTimeline = 30
+
+type Transition struct {
+	Kind string
+	// The comment which is posted if the sticky status comment is disabled
+	// or the transition needs attention. The first line is recorded in the timeline.
+	Message string
+	// The head of the pull request. This may be empty if it's unknown.
+	SHA string
+	// The 1-origin position in the approved queue. 0 if it's not queued or unknown.
+	Position int
+	// The result of CI with their links. This is nil if the transition is not related to CI.
+	CI *forge.CombinedStatus
+}
+
+// Reporter posts transitions. A nil Reporter posts each transition as a new comment.
+type Reporter struct {
+	store  *store.FileStore
+	sticky bool
+	// The account of this bot. Only its comment is regarded as the status comment.
+	botName string
+	now     func() time.Time
+}
+
+func NewReporter(s *store.FileStore, sticky bool, botName string) *Reporter {
+	return &Reporter{
+		store:   s,
+		sticky:  sticky,
+		botName: botName,
+		now:     time.Now,
+	}
+}
+
+// statusDocument is saved in the store per repository.
+type statusDocument struct {
+	PullRequests map[int]*pullRequestStatus `json:"pull_requests"`
+}
+
+type pullRequestStatus struct {
+	CommentID int64            `json:"comment_id"`
+	Kind      string           `json:"kind"`
+	SHA       string           `json:"sha,omitempty"`
+	Position  int              `json:"position,omitempty"`
+	CIState   string           `json:"ci_state,omitempty"`
+	CILinks   []string         `json:"ci_links,omitempty"`
+	Timeline  []*timelineEntry `json:"timeline"`
+}
+
+type timelineEntry struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// Report posts `t` to the pull request. This returns false if we could not post it.
+func (r *Reporter) Report(ctx context.Context, client forge.Client, owner, name string, number int, t *Transition) bool {
+	if r == nil || !r.sticky {
+		return addComment(ctx, client, owner, name, number, t.Message)
+	}
+
+	ok := r.updateStatusComment(ctx, client, owner, name, number, t)
+	if !ok || attentionKinds[t.Kind] {
+		// We don't miss the transition even if we could not update the status comment.
+		return addComment(ctx, client, owner, name, number, t.Message)
+	}
+
+	return true
+}
+
+// Forget removes the state of the pull request which will not have transitions anymore (e.g. closed).
+// The status comment is left as is.
+func (r *Reporter) Forget(owner, name string, number int) {
+	if r == nil || !r.sticky {
+		return
+	}
+
+	h := r.store.Get(owner, name)
+	if h == nil {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	var doc statusDocument
+	if !h.Load(&doc) {
+		return
+	}
+
+	if _, ok := doc.PullRequests[number]; ok {
+		delete(doc.PullRequests, number)
+		h.Save(&doc)
+	}
+}
+
+func (r *Reporter) updateStatusComment(ctx context.Context, client forge.Client, owner, name string, number int, t *Transition) bool {
+	h := r.store.Get(owner, name)
+	if h == nil {
+		log.Printf("warn: cannot get the status of %v/%v\n", owner, name)
+		return false
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	var doc statusDocument
+	h.Load(&doc)
+	if doc.PullRequests == nil {
+		doc.PullRequests = make(map[int]*pullRequestStatus)
+	}
+
+	status, ok := doc.PullRequests[number]
+	if !ok {
+		status = &pullRequestStatus{}
+		doc.PullRequests[number] = status
+	}
+	status.apply(t, r.now())
+
+	body := status.render()
+	if status.CommentID == 0 {
+		// The status might have been lost. Find the existing one before creating it.
+		status.CommentID = findStatusComment(ctx, client, r.botName, owner, name, number)
+	}
+
+	if status.CommentID != 0 {
+		if err := client.EditComment(ctx, owner, name, status.CommentID, body); err != nil {
+			log.Printf("info: could not edit the status comment %v of #%v: %v\n", status.CommentID, number, err)
+			status.CommentID = 0
+		}
+	}
+
+	if status.CommentID == 0 {
+		id, err := client.AddComment(ctx, owner, name, number, body)
+		if err != nil {
+			log.Printf("info: could not create the status comment of #%v: %v\n", number, err)
+			h.Save(&doc)
+			return false
+		}
+		status.CommentID = id
+	}
+
+	h.Save(&doc)
+	return true
+}
+
+func (s *pullRequestStatus) apply(t *Transition, now time.Time) {
+	s.Kind = t.Kind
+	if t.SHA != "" {
+		s.SHA = t.SHA
+	}
+	s.Position = t.Position
+
+	if t.CI != nil {
+		s.CIState = t.CI.State
+		s.CILinks = ciLinks(t.CI)
+	}
+
+	message := strings.TrimSpace(strings.SplitN(t.Message, "\n", 2)[0])
+	s.Timeline = append(s.Timeline, &timelineEntry{
+		Time:    now,
+		Kind:    t.Kind,
+		Message: message,
+	})
+	if len(s.Timeline) > maxTimeline {
+		s.Timeline = s.Timeline[len(s.Timeline)-maxTimeline:]
+	}
+}
+
+func (s *pullRequestStatus) render() string {
+	var b strings.Builder
+
+	fmt.Fprintln(&b, Marker)
+	title, ok := kindTitles[s.Kind]
+	if !ok {
+		title = s.Kind
+	}
+	fmt.Fprintf(&b, "**Status:** %v\n\n", title)
+
+	if s.SHA != "" {
+		fmt.Fprintf(&b, "- Commit: %v\n", s.SHA)
+	}
+	if s.Position > 0 {
+		fmt.Fprintf(&b, "- Position in the approved queue: %v\n", s.Position)
+	}
+	if s.CIState != "" {
+		fmt.Fprintf(&b, "- Last CI result: `%v`\n", s.CIState)
+		for _, link := range s.CILinks {
+			fmt.Fprintf(&b, "    %v\n", link)
+		}
+	}
+
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "<details><summary>Timeline</summary>")
+	fmt.Fprintln(&b)
+	for _, e := range s.Timeline {
+		fmt.Fprintf(&b, "- %v: %v\n", e.Time.UTC().Format("2006-01-02 15:04 UTC"), e.Message)
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprint(&b, "</details>")
+
+	return b.String()
+}
+
+func ciLinks(status *forge.CombinedStatus) []string {
+	links := make([]string, 0, len(status.Statuses))
+	for _, s := range status.Statuses {
+		if s.TargetURL == "" {
+			continue
+		}
+
+		if s.Description == "" {
+			links = append(links, fmt.Sprintf("* %v", s.TargetURL))
+		} else {
+			links = append(links, fmt.Sprintf("* [%v](%v)", s.Description, s.TargetURL))
+		}
+	}
+	return links
+}
+
+// findStatusComment returns the comment which has the marker and is posted by `botName`.
+// Others can post the marker too (e.g. by quoting the status comment), but we must not edit their comments.
+func findStatusComment(ctx context.Context, client forge.Client, botName, owner, name string, number int) int64 {
+	list, err := client.ListComments(ctx, owner, name, number)
+	if err != nil {
+		log.Printf("info: could not list comments of #%v: %v\n", number, err)
+		return 0
+	}
+
+	for _, c := range list {
+		if strings.EqualFold(c.User, botName) && strings.HasPrefix(c.Body, Marker) {
+			return c.ID
+		}
+	}
+	return 0
+}
+
+func addComment(ctx context.Context, client forge.Client, owner, name string, number int, body string) bool {
+	if _, err := client.AddComment(ctx, owner, name, number, body); err != nil {
+		log.Printf("info: could not create the comment to %v/%v#%v\n", owner, name, number)
+		log.Printf("debug: error is:%v\n", err)
+		return false
+	}
+	return true
+}
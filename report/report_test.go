@@ -0,0 +1,82 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voyagegroup/popuko/forge"
+)
+
+func TestPullRequestStatusApply(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s := &pullRequestStatus{}
+	s.apply(&Transition{
+		Kind:     KindQueued,
+		Message:  ":postbox: This pull request is queued.",
+		SHA:      "abc",
+		Position: 2,
+	}, now)
+	s.apply(&Transition{
+		Kind:    KindTestsFailed,
+		Message: ":collision: The result is `failure`.\n\n* https://ci.example.com/1\n",
+		CI: &forge.CombinedStatus{
+			State: "failure",
+			Statuses: []*forge.CommitStatus{
+				{Description: "ci", TargetURL: "https://ci.example.com/1"},
+				{Description: "no link"},
+			},
+		},
+	}, now)
+
+	if s.SHA != "abc" {
+		t.Errorf("the sha should be kept if the transition does not have it: %v", s.SHA)
+		return
+	}
+
+	if s.Position != 0 {
+		t.Errorf("the position should be reset: %v", s.Position)
+		return
+	}
+
+	if len(s.CILinks) != 1 || s.CILinks[0] != "* [ci](https://ci.example.com/1)" {
+		t.Errorf("unexpected links: %v", s.CILinks)
+		return
+	}
+
+	if len(s.Timeline) != 2 || s.Timeline[1].Message != ":collision: The result is `failure`." {
+		t.Errorf("only the first line should be recorded in the timeline: %+v", s.Timeline[1])
+		return
+	}
+
+	body := s.render()
+	if !strings.HasPrefix(body, Marker) {
+		t.Errorf("the body should start with the marker: %v", body)
+		return
+	}
+
+	for _, expected := range []string{":collision: Tests failed", "Last CI result: `failure`", "2020-01-02 03:04 UTC: :postbox:"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("the body should contain `%v`: %v", expected, body)
+			return
+		}
+	}
+}
+
+func TestPullRequestStatusTimelineIsCapped(t *testing.T) {
+	s := &pullRequestStatus{}
+	for i := 0; i < maxTimeline+5; i++ {
+		s.apply(&Transition{Kind: KindRetrying, Message: string(rune('a' + i%26))}, time.Now())
+	}
+
+	if len(s.Timeline) != maxTimeline {
+		t.Errorf("the timeline should be capped to %v but %v", maxTimeline, len(s.Timeline))
+		return
+	}
+
+	if s.Timeline[0].Message != string(rune('a'+5)) {
+		t.Errorf("the oldest entries should be dropped: %v", s.Timeline[0].Message)
+		return
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/voyagegroup/popuko/epic"
+)
+
+// startScheduler runs `task` at once and every `interval` in a new goroutine.
+// If `interval` is 0, `task` runs only at once.
+func startScheduler(name string, interval time.Duration, task func(ctx context.Context)) {
+	go func() {
+		run := func() {
+			log.Printf("info: Start: scheduled task `%v`\n", name)
+			defer log.Printf("info: End: scheduled task `%v`\n", name)
+			task(context.Background())
+		}
+
+		run()
+
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
+
+func (srv *AppServer) reconcileQueues(ctx context.Context) {
+	now := time.Now()
+	interval := srv.setting.ReconcileInterval()
+	for _, qHandle := range srv.autoMergeRepo.List() {
+		owner := qHandle.Owner()
+		name := qHandle.Name()
+		if !srv.acceptRepo(owner, name) {
+			log.Printf("info: skip to reconcile the queue of unaccepted %v/%v\n", owner, name)
+			continue
+		}
+
+		epic.ReconcileQueue(ctx, srv.client, srv.notifier, srv.reporter, qHandle, now, interval)
+	}
+}
+
+// The interval to check whether the active item of each queue has been timed out.
+const timeoutCheckInterval = time.Minute
+
+func (srv *AppServer) checkActiveItemTimeouts(ctx context.Context) {
+	now := time.Now()
+	for _, qHandle := range srv.autoMergeRepo.List() {
+		if !srv.acceptRepo(qHandle.Owner(), qHandle.Name()) {
+			continue
+		}
+
+		epic.CheckActiveItemTimeout(ctx, srv.client, srv.notifier, srv.reporter, qHandle, now)
+	}
+}
+
+// The interval to check whether the merge window has opened for each held queue.
+const mergeWindowCheckInterval = time.Minute
+
+func (srv *AppServer) resumeHeldQueues(ctx context.Context) {
+	now := time.Now()
+	for _, qHandle := range srv.autoMergeRepo.List() {
+		if !srv.acceptRepo(qHandle.Owner(), qHandle.Name()) {
+			continue
+		}
+
+		epic.ResumeQueue(ctx, srv.client, srv.notifier, srv.reporter, qHandle, now)
+	}
+}
+
+// We keep the delivery history for a while to skip redeliveries and to replay them.
+const deliveryRetention = 14 * 24 * time.Hour
+
+const deliveryPruneInterval = time.Hour
+
+func (srv *AppServer) pruneDeliveries(ctx context.Context) {
+	srv.deliveryHistory.Prune(time.Now().Add(-deliveryRetention))
+}
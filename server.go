@@ -2,241 +2,499 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v28/github"
 	"golang.org/x/oauth2"
 
 	"github.com/voyagegroup/popuko/epic"
+	"github.com/voyagegroup/popuko/event"
+	"github.com/voyagegroup/popuko/forge"
 	"github.com/voyagegroup/popuko/input"
+	"github.com/voyagegroup/popuko/notify"
 	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
 	"github.com/voyagegroup/popuko/setting"
+	"github.com/voyagegroup/popuko/store"
 )
 
-// AppServer is just an this application.
+// AppServer is just an this application for a forge (e.g. GitHub or Gitea).
 type AppServer struct {
-	githubClient  *github.Client
+	client     forge.Client
+	webhook    forge.Webhook
+	botName    string
+	hookSecret []byte
+	acceptRepo func(owner, name string) bool
+	// The path prefix of REST APIs for this forge.
+	restPrefix string
+
 	autoMergeRepo *queue.AutoMergeQRepo
+	assignStore   *store.FileStore
 	setting       *setting.Settings
+
+	availabilityStore *store.FileStore
+	eventQueue        *event.Queue
+	deliveryHistory   *event.History
+	notifier          *notify.Notifier
+	reporter          *report.Reporter
+
+	unmergeableDetector *epic.UnmergeableDetector
 }
 
 const prefixWebHookPath = "/github"
+const prefixGiteaWebHookPath = "/gitea"
 
-func (srv *AppServer) handleGithubHook(rw http.ResponseWriter, req *http.Request) {
-	log.Println("info: Start: handle GitHub WebHook")
+func (srv *AppServer) handleWebhook(rw http.ResponseWriter, req *http.Request) {
+	log.Println("info: Start: handle WebHook")
 	log.Printf("info: Path is %v\n", req.URL.Path)
-	defer log.Println("info End: handle GitHub WebHook")
+	defer log.Println("info End: handle WebHook")
 
 	if req.Method != "POST" {
 		rw.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	payload, err := github.ValidatePayload(req, config.WebHookSecret())
+	payload, err := srv.webhook.ValidatePayload(req, srv.hookSecret)
 	if err != nil {
 		rw.WriteHeader(http.StatusPreconditionFailed)
 		io.WriteString(rw, err.Error())
 		return
 	}
 
-	event, err := github.ParseWebHook(github.WebHookType(req), payload)
+	eventType := srv.webhook.EventType(req)
+	ev, err := srv.webhook.ParseWebhook(eventType, payload)
+	if err == forge.ErrUnsupportedEvent {
+		rw.WriteHeader(http.StatusOK)
+		log.Printf("warn: Unsupported type events: %v\n", eventType)
+		io.WriteString(rw, "This event type is not supported: "+eventType)
+		return
+	}
 	if err != nil {
 		rw.WriteHeader(http.StatusPreconditionFailed)
 		io.WriteString(rw, err.Error())
 		return
 	}
 
-	ctx := req.Context()
+	repo := ev.Repo()
+	if !srv.isAcceptedRepo(rw, repo.Owner, repo.Name) {
+		return
+	}
 
-	switch event := event.(type) {
-	case *github.IssueCommentEvent:
-		ok, err := srv.processIssueCommentEvent(ctx, event)
-		rw.WriteHeader(http.StatusOK)
-		if ok {
-			io.WriteString(rw, "result: \n")
-		}
+	now := time.Now()
+	id := srv.webhook.DeliveryID(req)
+	if id == "" {
+		// This might be sent by hand. We cannot detect its redelivery.
+		id = fmt.Sprintf("unknown-%v", now.UnixNano())
+	}
 
-		if err != nil {
-			log.Printf("info: %v\n", err)
-			io.WriteString(rw, err.Error())
-		}
-		return
-	case *github.PushEvent:
-		srv.processPushEvent(ctx, event)
-		rw.WriteHeader(http.StatusOK)
-		return
-	case *github.StatusEvent:
-		srv.processStatusEvent(ctx, event)
-		rw.WriteHeader(http.StatusOK)
-		return
-	case *github.CheckSuiteEvent:
-		srv.processCheckSuiteEvent(ctx, event)
-		rw.WriteHeader(http.StatusOK)
+	delivery := &event.Delivery{
+		ID:         id,
+		Type:       eventType,
+		Owner:      repo.Owner,
+		Name:       repo.Name,
+		Payload:    payload,
+		ReceivedAt: now,
+	}
+
+	ok, added := srv.deliveryHistory.Add(delivery)
+	if !ok {
+		rw.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(rw, "error: cannot record the delivery")
 		return
-	case *github.PullRequestEvent:
-		srv.processPullRequestEvent(ctx, event)
+	}
+
+	if !added {
+		log.Printf("info: skip the duplicated delivery %v (%v)\n", delivery.ID, eventType)
 		rw.WriteHeader(http.StatusOK)
+		io.WriteString(rw, "This delivery has been already received: "+delivery.ID)
 		return
-	default:
-		rw.WriteHeader(http.StatusOK)
-		log.Println("warn: Unsupported type events")
-		log.Println(reflect.TypeOf(event))
-		io.WriteString(rw, "This event type is not supported: "+github.WebHookType(req))
+	}
+
+	srv.pushDelivery(rw, delivery)
+}
+
+// isAcceptedRepo replies `403 Forbidden` if the repository is not accepted by this bot.
+// This must be checked before the delivery is recorded or queued.
+func (srv *AppServer) isAcceptedRepo(rw http.ResponseWriter, owner, name string) bool {
+	if srv.acceptRepo(owner, name) {
+		return true
+	}
+
+	n := owner + "/" + name
+	log.Printf("======= error: =======\n This event is from an unaccepted repository: %v\n==============", n)
+	rw.WriteHeader(http.StatusForbidden)
+	io.WriteString(rw, fmt.Sprintf("info: `%v` is not accepted by this bot", n))
+	return false
+}
+
+// pushDelivery adds the recorded delivery to the event queue and replies `202 Accepted`.
+// GitHub gives up a delivery which takes over 10 seconds.
+// So we reply at once and process it in background.
+func (srv *AppServer) pushDelivery(rw http.ResponseWriter, d *event.Delivery) {
+	if ok := srv.eventQueue.Push(d); !ok {
+		// Forget it to accept the redelivery instead of skipping it as a duplicate.
+		srv.deliveryHistory.Remove(d.ID)
+		rw.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(rw, "error: cannot save the delivery")
 		return
 	}
+
+	log.Printf("info: accept the delivery %v (%v) for %v/%v\n", d.ID, d.Type, d.Owner, d.Name)
+	rw.WriteHeader(http.StatusAccepted)
+	io.WriteString(rw, d.ID)
 }
 
-func (srv *AppServer) processIssueCommentEvent(ctx context.Context, ev *github.IssueCommentEvent) (bool, error) {
-	log.Printf("Start: processCommitCommentEvent by %v\n", *ev.Comment.ID)
-	defer log.Printf("End: processCommitCommentEvent by %v\n", *ev.Comment.ID)
+// processDelivery is called by the worker of `event.Queue`.
+func (srv *AppServer) processDelivery(ctx context.Context, d *event.Delivery) error {
+	ev, err := srv.webhook.ParseWebhook(d.Type, d.Payload)
+	if err != nil {
+		log.Printf("error: cannot parse the delivery %v: %v\n", d.ID, err)
+		return err
+	}
 
-	if action := ev.Action; (action == nil) || (*action != "created") {
-		return false, fmt.Errorf("info: accept `action === \"created\"` only")
+	// The delivery saved in the queue may come from the repository which is no longer accepted after restarting.
+	if repo := ev.Repo(); !srv.acceptRepo(repo.Owner, repo.Name) {
+		return fmt.Errorf("%v/%v is not accepted", repo.Owner, repo.Name)
+	}
+
+	switch ev := ev.(type) {
+	case *forge.CommentEvent:
+		_, err = srv.processCommentEvent(ctx, ev)
+	case *forge.PushEvent:
+		srv.processPushEvent(ctx, ev)
+	case *forge.StatusEvent:
+		srv.processStatusEvent(ctx, ev)
+	case *forge.PullRequestEvent:
+		srv.processPullRequestEvent(ctx, ev)
+	case *forge.ReviewEvent:
+		_, err = srv.processReviewEvent(ctx, ev)
+	default:
+		err = fmt.Errorf("warn: the delivery %v has unsupported type: %v", d.ID, reflect.TypeOf(ev))
 	}
 
-	repoOwner := *ev.Repo.Owner.Login
-	repo := *ev.Repo.Name
-	if !srv.setting.AcceptRepo(repoOwner, repo) {
-		n := repoOwner + "/" + repo
-		log.Printf("======= error: =======\n This event is from an unaccepted repository: %v\n==============", n)
-		return false, fmt.Errorf("%v is not accepted", n)
+	if err != nil {
+		log.Printf("info: %v\n", err)
 	}
+	return err
+}
 
-	body := *ev.Comment.Body
-	ok, cmd := input.ParseCommand(body)
+func (srv *AppServer) processCommentEvent(ctx context.Context, ev *forge.CommentEvent) (bool, error) {
+	log.Printf("Start: processCommentEvent by %v\n", ev.CommentID)
+	defer log.Printf("End: processCommentEvent by %v\n", ev.CommentID)
+
+	if ev.Action != "created" {
+		return false, fmt.Errorf("info: accept `action === \"created\"` only")
+	}
+
+	ok, cmd := input.ParseCommand(ev.Body)
 	if !ok {
-		return false, fmt.Errorf("No operations which this bot should handle")
+		if !input.IsAddressedTo(ev.Body, srv.botName) {
+			return false, fmt.Errorf("No operations which this bot should handle")
+		}
+
+		repoInfo := epic.GetRepositoryInfo(ctx, srv.client, ev.Owner, ev.Name, ev.DefaultBranch)
+		if repoInfo == nil {
+			return false, fmt.Errorf("debug: cannot get repositoryInfo")
+		}
+
+		syntaxErr := input.SyntaxError(ev.Body)
+		epic.ReplyToMalformedCommand(ctx, srv.client, ev, repoInfo, srv.botName, syntaxErr)
+		return false, fmt.Errorf("info: the command to this bot is malformed: %v", syntaxErr)
 	}
 
 	if cmd == nil {
 		return false, fmt.Errorf("error: unexpected result of parsing comment body")
 	}
 
-	defaultBranchName := ev.Repo.GetDefaultBranch()
-	repoInfo := epic.GetRepositoryInfo(ctx, srv.githubClient.Repositories, repoOwner, repo, defaultBranchName)
+	repoInfo := epic.GetRepositoryInfo(ctx, srv.client, ev.Owner, ev.Name, ev.DefaultBranch)
 	if repoInfo == nil {
 		return false, fmt.Errorf("debug: cannot get repositoryInfo")
 	}
 
+	ok, err := srv.dispatchCommand(ctx, ev, repoInfo, cmd)
+	epic.ReplyToCommand(ctx, srv.client, ev, repoInfo, ok, err)
+	return ok, err
+}
+
+func (srv *AppServer) dispatchCommand(ctx context.Context, ev *forge.CommentEvent, repoInfo *setting.RepositoryInfo, cmd interface{}) (bool, error) {
+	repoOwner := ev.Owner
+	repo := ev.Name
+
 	switch cmd := cmd.(type) {
 	case *input.AssignReviewerCommand:
-		return epic.AssignReviewer(ctx, srv.githubClient, ev, cmd.Reviewer)
+		epic.LoadAvailability(srv.availabilityStore, repoOwner, repo, repoInfo)
+		return epic.AssignReviewer(ctx, srv.client, ev, cmd.Reviewer, repoInfo)
 	case *input.AcceptChangeByReviewerCommand:
 		commander := epic.AcceptCommand{
 			Owner:         repoOwner,
 			Name:          repo,
-			Client:        srv.githubClient,
-			BotName:       config.BotNameForGithub(),
+			Client:        srv.client,
+			BotName:       srv.botName,
 			Info:          repoInfo,
 			AutoMergeRepo: srv.autoMergeRepo,
+			Notifier:      srv.notifier,
+			Reporter:      srv.reporter,
 		}
 		return commander.AcceptChangesetByReviewer(ctx, ev, cmd)
 	case *input.AcceptChangeByOthersCommand:
 		commander := epic.AcceptCommand{
 			Owner:         repoOwner,
 			Name:          repo,
-			Client:        srv.githubClient,
-			BotName:       config.BotNameForGithub(),
+			Client:        srv.client,
+			BotName:       srv.botName,
 			Info:          repoInfo,
 			AutoMergeRepo: srv.autoMergeRepo,
+			Notifier:      srv.notifier,
+			Reporter:      srv.reporter,
 		}
 		return commander.AcceptChangesetByOthers(ctx, ev, cmd)
 	case *input.CancelApprovedByReviewerCommand:
 		commander := epic.CancelApprovedCommand{
-			BotName:       config.BotNameForGithub(),
-			Client:        srv.githubClient,
+			BotName:       srv.botName,
+			Client:        srv.client,
 			Owner:         repoOwner,
 			Name:          repo,
-			Number:        *ev.Issue.Number,
+			Number:        ev.Number,
 			Cmd:           cmd,
 			Info:          repoInfo,
 			AutoMergeRepo: srv.autoMergeRepo,
+			Reporter:      srv.reporter,
 		}
 		return commander.CancelApprovedChangeSet(ctx, ev)
+	case *input.ProvisionLabelsCommand:
+		commander := epic.ProvisionLabelsCommand{
+			BotName: srv.botName,
+			Client:  srv.client,
+			Owner:   repoOwner,
+			Name:    repo,
+			Cmd:     cmd,
+			Info:    repoInfo,
+		}
+		return commander.ProvisionLabels(ctx, ev)
+	case *input.DependsCommand:
+		commander := epic.DependsCommand{
+			Owner:         repoOwner,
+			Name:          repo,
+			Client:        srv.client,
+			BotName:       srv.botName,
+			Info:          repoInfo,
+			AutoMergeRepo: srv.autoMergeRepo,
+			Notifier:      srv.notifier,
+			Reporter:      srv.reporter,
+		}
+		return commander.DeclareDependencies(ctx, ev, cmd)
 	default:
 		return false, fmt.Errorf("error: unreachable")
 	}
 }
 
-func (srv *AppServer) processPushEvent(ctx context.Context, ev *github.PushEvent) {
+func (srv *AppServer) processReviewEvent(ctx context.Context, ev *forge.ReviewEvent) (bool, error) {
+	log.Printf("Start: processReviewEvent by %v\n", ev.ReviewID)
+	defer log.Printf("End: processReviewEvent by %v\n", ev.ReviewID)
+
+	if ev.Action != "submitted" {
+		return false, fmt.Errorf("info: accept `action === \"submitted\"` only")
+	}
+
+	pr := ev.PullRequest
+	if pr == nil {
+		return false, fmt.Errorf("warn: ev.PullRequest is nil")
+	}
+
+	// We handle the review as same as a normal comment.
+	commentEv := &forge.CommentEvent{
+		Repository:    ev.Repository,
+		Action:        "created",
+		Number:        pr.Number,
+		IsPullRequest: true,
+		IssueUser:     pr.User,
+		Kind:          forge.CommentKindReview,
+		CommentID:     ev.ReviewID,
+		Body:          ev.Body,
+		Sender:        ev.Sender,
+	}
+
+	// A command written in the review body takes precedence over the review state.
+	if ok, _ := input.ParseCommand(ev.Body); ok {
+		return srv.processCommentEvent(ctx, commentEv)
+	}
+
+	var cmd interface{}
+	switch state := strings.ToLower(ev.State); state {
+	case "approved":
+		cmd = input.NewAcceptChangeByReviewerCommand(srv.botName)
+	case "changes_requested":
+		cmd = input.NewCancelApprovedByReviewerCommand(srv.botName)
+	default:
+		return false, fmt.Errorf("info: the review state `%v` is not handled by this bot", state)
+	}
+
+	if ev.CommitID != pr.HeadSHA {
+		return false, fmt.Errorf("info: the review is not for the current head of #%v", pr.Number)
+	}
+
+	repoInfo := epic.GetRepositoryInfo(ctx, srv.client, ev.Owner, ev.Name, ev.DefaultBranch)
+	if repoInfo == nil {
+		return false, fmt.Errorf("debug: cannot get repositoryInfo")
+	}
+
+	if !repoInfo.AcceptGitHubReview {
+		return false, fmt.Errorf("info: %v/%v does not regard a review as a command", ev.Owner, ev.Name)
+	}
+
+	return srv.dispatchCommand(ctx, commentEv, repoInfo, cmd)
+}
+
+func (srv *AppServer) processPushEvent(ctx context.Context, ev *forge.PushEvent) {
 	log.Println("info: Start: processPushEvent by push id")
 	defer log.Println("info: End: processPushEvent by push id")
 
-	repoOwner := *ev.Repo.Owner.Name
-	log.Printf("debug: repository owner is %v\n", repoOwner)
-	repo := *ev.Repo.Name
-	log.Printf("debug: repository name is %v\n", repo)
-	if !srv.setting.AcceptRepo(repoOwner, repo) {
-		n := repoOwner + "/" + repo
-		log.Printf("======= error: =======\n This event is from an unaccepted repository: %v\n==============", n)
-		return
-	}
+	log.Printf("debug: repository owner is %v\n", ev.Owner)
+	log.Printf("debug: repository name is %v\n", ev.Name)
 
-	epic.DetectUnmergeablePR(ctx, srv.githubClient, ev)
+	srv.unmergeableDetector.DetectUnmergeablePR(ctx, ev)
 }
 
-func (srv *AppServer) processStatusEvent(ctx context.Context, ev *github.StatusEvent) {
+func (srv *AppServer) processStatusEvent(ctx context.Context, ev *forge.StatusEvent) {
 	log.Println("info: Start: processStatusEvent")
 	defer log.Println("info: End: processStatusEvent")
 
-	repoOwner := *ev.Repo.Owner.Login
-	log.Printf("debug: repository owner is %v\n", repoOwner)
-	repo := *ev.Repo.Name
-	log.Printf("debug: repository name is %v\n", repo)
-	if !srv.setting.AcceptRepo(repoOwner, repo) {
-		n := repoOwner + "/" + repo
-		log.Printf("======= error: =======\n This event is from an unaccepted repository: %v\n==============", n)
-		return
-	}
+	log.Printf("debug: repository owner is %v\n", ev.Owner)
+	log.Printf("debug: repository name is %v\n", ev.Name)
 
-	epic.CheckAutoBranchWithStatusEvent(ctx, srv.githubClient, srv.autoMergeRepo, ev)
+	epic.CheckAutoBranch(ctx, srv.client, srv.notifier, srv.reporter, srv.autoMergeRepo, ev)
 }
 
-func (srv *AppServer) processCheckSuiteEvent(ctx context.Context, ev *github.CheckSuiteEvent) {
-	log.Println("info: Start: processCheckSuiteEvent")
-	defer log.Println("info: End: processCheckSuiteEvent")
+func (srv *AppServer) processPullRequestEvent(ctx context.Context, ev *forge.PullRequestEvent) {
+	log.Println("info: Start: processPullRequestEvent")
+	defer log.Println("info: End: processPullRequestEvent")
 
-	repoOwner := *ev.Repo.Owner.Login
-	log.Printf("debug: repository owner is %v\n", repoOwner)
-	repo := *ev.Repo.Name
-	log.Printf("debug: repository name is %v\n", repo)
-	if !srv.setting.AcceptRepo(repoOwner, repo) {
-		n := repoOwner + "/" + repo
-		log.Printf("======= error: =======\n This event is from an unaccepted repository: %v\n==============", n)
+	pr := ev.PullRequest
+	if pr == nil {
+		log.Println("warn: ev.PullRequest is nil")
 		return
 	}
 
-	epic.CheckAutoBranchWithCheckSuiteEvent(ctx, srv.githubClient, srv.autoMergeRepo, ev)
+	repoOwner := ev.Owner
+	repoName := ev.Name
+
+	switch action := ev.Action; action {
+	case "opened", "ready_for_review":
+		repoInfo := epic.GetRepositoryInfo(ctx, srv.client, repoOwner, repoName, ev.DefaultBranch)
+		if repoInfo == nil {
+			log.Println("debug: cannot get repositoryInfo")
+			return
+		}
+		epic.LoadAvailability(srv.availabilityStore, repoOwner, repoName, repoInfo)
+
+		commander := epic.AutoAssignCommand{
+			Owner:       repoOwner,
+			Name:        repoName,
+			Client:      srv.client,
+			Info:        repoInfo,
+			AssignStore: srv.assignStore,
+		}
+		commander.AssignReviewerAutomatically(ctx, pr)
+	case "synchronize":
+		epic.DequeueUpdatedPullRequest(ctx, srv.client, srv.notifier, srv.reporter, srv.autoMergeRepo, ev.Repository, pr)
+	case "closed":
+		epic.CleanUpClosedPullRequest(ctx, srv.client, srv.notifier, srv.reporter, srv.autoMergeRepo, ev.Repository, pr)
+
+		repoInfo := epic.GetRepositoryInfo(ctx, srv.client, repoOwner, repoName, ev.DefaultBranch)
+		if repoInfo == nil {
+			log.Println("debug: cannot get repositoryInfo")
+			return
+		}
+		epic.RemoveAllStatusLabel(ctx, srv.client, repoInfo.Labels, ev.Repository, pr)
+	case "reopened":
+		repoInfo := epic.GetRepositoryInfo(ctx, srv.client, repoOwner, repoName, ev.DefaultBranch)
+		if repoInfo == nil {
+			log.Println("debug: cannot get repositoryInfo")
+			return
+		}
+		epic.RestoreAwaitingReviewLabel(ctx, srv.client, repoInfo.Labels, ev.Repository, pr)
+	case "labeled", "unlabeled", "label_updated", "label_cleared":
+		if _, err := srv.processLabelEvent(ctx, ev); err != nil {
+			log.Printf("info: %v\n", err)
+		}
+	default:
+		log.Printf("info: action type is `%v` which is not handled by this bot\n", action)
+	}
 }
 
-func (srv *AppServer) processPullRequestEvent(ctx context.Context, ev *github.PullRequestEvent) {
-	log.Println("info: Start: processPullRequestEvent")
-	defer log.Println("info: End: processPullRequestEvent")
+// processLabelEvent handles the merge label which is added or removed by a human
+// as same as `r+` or `r-` by the sender.
+func (srv *AppServer) processLabelEvent(ctx context.Context, ev *forge.PullRequestEvent) (bool, error) {
+	pr := ev.PullRequest
 
-	if action := *ev.Action; action != "closed" {
-		log.Printf("info: action type is `%v` which is not handled by this bot\n", action)
-		return
+	// This bot changes labels by itself whenever the status of a pull request changes.
+	if ev.Sender == srv.botName {
+		return false, fmt.Errorf("debug: the labels of #%v are changed by this bot", pr.Number)
 	}
 
-	repo := ev.Repo
-	if repo == nil {
-		log.Println("warn: ev.Repo is nil")
-		return
+	repoInfo := epic.GetRepositoryInfo(ctx, srv.client, ev.Owner, ev.Name, ev.DefaultBranch)
+	if repoInfo == nil {
+		return false, fmt.Errorf("debug: cannot get repositoryInfo")
 	}
 
-	pr := ev.PullRequest
-	if pr == nil {
-		log.Println("warn: ev.PullRequest is nil")
-		return
+	merge := repoInfo.Labels.AwaitingMerge.Name
+
+	var added bool
+	switch ev.Action {
+	case "labeled", "unlabeled":
+		if ev.Label != merge {
+			return false, fmt.Errorf("debug: `%v` is not the merge label", ev.Label)
+		}
+		added = ev.Action == "labeled"
+	default:
+		// Gitea does not tell which label is changed. We compare the labels with the queue.
+		if !repoInfo.EnableAutoMerge {
+			return false, fmt.Errorf("info: cannot know whether `%v` is changed without the queue", merge)
+		}
+
+		added = false
+		for _, l := range pr.Labels {
+			if l == merge {
+				added = true
+				break
+			}
+		}
+
+		if added == epic.IsQueued(srv.autoMergeRepo, ev.Owner, ev.Name, pr.Number) {
+			return false, fmt.Errorf("debug: `%v` of #%v is not changed", merge, pr.Number)
+		}
+	}
+
+	// We handle the label as same as a command by the sender.
+	commentEv := &forge.CommentEvent{
+		Repository:    ev.Repository,
+		Action:        "created",
+		Number:        pr.Number,
+		IsPullRequest: true,
+		IssueUser:     pr.User,
+		Sender:        ev.Sender,
 	}
 
-	epic.RemoveAllStatusLabel(ctx, srv.githubClient, repo, pr)
+	var cmd interface{}
+	if added {
+		cmd = input.NewAcceptChangeByReviewerCommand(srv.botName)
+	} else {
+		cmd = input.NewCancelApprovedByReviewerCommand(srv.botName)
+	}
+
+	ok, err := srv.dispatchCommand(ctx, commentEv, repoInfo, cmd)
+	epic.ReplyToLabelChange(ctx, srv.client, commentEv, repoInfo, added, err)
+	return ok, err
 }
 
 func createGithubClient(config *setting.Settings) (*github.Client, error) {
@@ -259,19 +517,316 @@ func createGithubClient(config *setting.Settings) (*github.Client, error) {
 }
 
 const prefixRestAPI = "/api/v0"
+
+// The REST APIs for Gitea are served under this because queues are separated by the forge.
+const prefixGiteaRestAPI = prefixGiteaWebHookPath + prefixRestAPI
 const prefixQueueInfoAPI = "/queue/"
+const prefixAvailabilityAPI = "/availability/"
+const prefixDeliveryAPI = "/deliveries/"
+const prefixLabelsAPI = "/labels/"
 
 func (srv *AppServer) handleRESTApiRequest(rw http.ResponseWriter, req *http.Request) {
-	p := strings.TrimPrefix(req.URL.Path, prefixRestAPI)
+	if !strings.HasPrefix(req.URL.Path, srv.restPrefix) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	p := strings.TrimPrefix(req.URL.Path, srv.restPrefix)
 	if strings.HasPrefix(p, prefixQueueInfoAPI) {
 		repo := strings.TrimPrefix(p, prefixQueueInfoAPI)
 		srv.getQueueInfoForRepository(rw, req, repo)
 		return
 	}
 
+	if strings.HasPrefix(p, prefixAvailabilityAPI) {
+		path := strings.TrimPrefix(p, prefixAvailabilityAPI)
+		srv.handleAvailabilityRequest(rw, req, path)
+		return
+	}
+
+	if strings.HasPrefix(p, prefixDeliveryAPI) {
+		path := strings.TrimPrefix(p, prefixDeliveryAPI)
+		srv.handleDeliveryRequest(rw, req, path)
+		return
+	}
+
+	if strings.HasPrefix(p, prefixLabelsAPI) {
+		path := strings.TrimPrefix(p, prefixLabelsAPI)
+		srv.provisionLabels(rw, req, path)
+		return
+	}
+
 	rw.WriteHeader(http.StatusNotFound)
 }
 
+// isAuthorizedRequest checks `Authorization: Bearer <token>` for APIs which change the state of this bot.
+func (srv *AppServer) isAuthorizedRequest(rw http.ResponseWriter, req *http.Request) bool {
+	token := srv.setting.APIToken()
+	if token == "" {
+		rw.WriteHeader(http.StatusForbidden)
+		io.WriteString(rw, "info: this API is disabled because `api.token` is not set")
+		return false
+	}
+
+	actual := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(actual), []byte(token)) != 1 {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// handleAvailabilityRequest handles:
+//   - `GET /availability/<owner>/<repo>`
+//   - `PUT /availability/<owner>/<repo>/<user>` with the JSON of `setting.Availability`
+//   - `DELETE /availability/<owner>/<repo>/<user>`
+func (srv *AppServer) handleAvailabilityRequest(rw http.ResponseWriter, req *http.Request, path string) {
+	tmp := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	if len(tmp) != 2 && len(tmp) != 3 {
+		rw.WriteHeader(http.StatusNotFound)
+		io.WriteString(rw, "info: the path is invalid")
+		return
+	}
+
+	owner := tmp[0]
+	name := tmp[1]
+	h := srv.availabilityStore.Get(owner, name)
+	if h == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		io.WriteString(rw, fmt.Sprintf("error: cannot get the availability for `%v/%v`", owner, name))
+		return
+	}
+
+	if len(tmp) == 2 {
+		if req.Method != "GET" {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		h.Lock()
+		defer h.Unlock()
+
+		b := h.LoadAsRawByte()
+		if b == nil {
+			b = []byte("{}")
+		}
+
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(b)
+		return
+	}
+
+	user := tmp[2]
+	if req.Method != "PUT" && req.Method != "DELETE" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !srv.isAuthorizedRequest(rw, req) {
+		return
+	}
+
+	var availability *setting.Availability
+	if req.Method == "PUT" {
+		availability = &setting.Availability{}
+		if err := json.NewDecoder(req.Body).Decode(availability); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			io.WriteString(rw, err.Error())
+			return
+		}
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	list := make(map[string]*setting.Availability)
+	h.Load(&list)
+
+	if availability == nil {
+		delete(list, user)
+	} else {
+		list[user] = availability
+	}
+
+	if ok := h.Save(list); !ok {
+		rw.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(rw, fmt.Sprintf("error: cannot save the availability for `%v/%v`", owner, name))
+		return
+	}
+
+	log.Printf("info: update the availability of `%v` for %v/%v\n", user, owner, name)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeliveryRequest handles:
+//   - `GET /deliveries/<id>` returns the recorded delivery and its result.
+//   - `POST /deliveries/<id>/replay` processes the recorded delivery again.
+//   - `POST /deliveries/replay` processes the payload in the body as the event of
+//     the forge's event type header (e.g. `X-GitHub-Event` or `X-Gitea-Event`).
+func (srv *AppServer) handleDeliveryRequest(rw http.ResponseWriter, req *http.Request, path string) {
+	// The payload may contain private information.
+	if !srv.isAuthorizedRequest(rw, req) {
+		return
+	}
+
+	tmp := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	switch {
+	case len(tmp) == 1 && tmp[0] == "replay":
+		if req.Method != "POST" {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		srv.replayPayload(rw, req)
+	case len(tmp) == 1:
+		if req.Method != "GET" {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		record := srv.deliveryHistory.Get(tmp[0])
+		if record == nil {
+			rw.WriteHeader(http.StatusNotFound)
+			io.WriteString(rw, fmt.Sprintf("info: the delivery `%v` is not found", tmp[0]))
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		json.NewEncoder(rw).Encode(record)
+	case len(tmp) == 2 && tmp[1] == "replay":
+		if req.Method != "POST" {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		record := srv.deliveryHistory.Get(tmp[0])
+		if record == nil {
+			rw.WriteHeader(http.StatusNotFound)
+			io.WriteString(rw, fmt.Sprintf("info: the delivery `%v` is not found", tmp[0]))
+			return
+		}
+
+		srv.replayDelivery(rw, record.Type, record.Payload, record.ID)
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+		io.WriteString(rw, "info: the path is invalid")
+	}
+}
+
+func (srv *AppServer) replayPayload(rw http.ResponseWriter, req *http.Request) {
+	eventType := srv.webhook.EventType(req)
+	if eventType == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		io.WriteString(rw, "error: the header of the event type is required")
+		return
+	}
+
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		io.WriteString(rw, err.Error())
+		return
+	}
+
+	srv.replayDelivery(rw, eventType, payload, "")
+}
+
+// replayDelivery processes `payload` through the normal handlers as a new delivery.
+// `original` is the id of the replayed delivery, or empty if the payload is not recorded.
+func (srv *AppServer) replayDelivery(rw http.ResponseWriter, eventType string, payload []byte, original string) {
+	ev, err := srv.webhook.ParseWebhook(eventType, payload)
+	if err == forge.ErrUnsupportedEvent {
+		rw.WriteHeader(http.StatusBadRequest)
+		io.WriteString(rw, "This event type is not supported: "+eventType)
+		return
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		io.WriteString(rw, err.Error())
+		return
+	}
+
+	repo := ev.Repo()
+	if !srv.isAcceptedRepo(rw, repo.Owner, repo.Name) {
+		return
+	}
+
+	now := time.Now()
+	delivery := &event.Delivery{
+		ID:         fmt.Sprintf("replay-%v", now.UnixNano()),
+		Type:       eventType,
+		Owner:      repo.Owner,
+		Name:       repo.Name,
+		Payload:    payload,
+		ReceivedAt: now,
+		ReplayOf:   original,
+	}
+
+	if ok, _ := srv.deliveryHistory.Add(delivery); !ok {
+		rw.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(rw, "error: cannot record the delivery")
+		return
+	}
+
+	log.Printf("info: replay the delivery `%v` as %v\n", original, delivery.ID)
+	srv.pushDelivery(rw, delivery)
+}
+
+// provisionLabels handles `POST /labels/<owner>/<repo>` which creates or updates
+// status labels in the repository by `OWNERS.json`.
+func (srv *AppServer) provisionLabels(rw http.ResponseWriter, req *http.Request, path string) {
+	if req.Method != "POST" {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmp := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	if len(tmp) != 2 {
+		rw.WriteHeader(http.StatusNotFound)
+		io.WriteString(rw, "info: the path is invalid")
+		return
+	}
+
+	if !srv.isAuthorizedRequest(rw, req) {
+		return
+	}
+
+	owner := tmp[0]
+	name := tmp[1]
+	if !srv.acceptRepo(owner, name) {
+		rw.WriteHeader(http.StatusForbidden)
+		io.WriteString(rw, fmt.Sprintf("info: `%v/%v` is not accepted by this bot", owner, name))
+		return
+	}
+
+	ctx := req.Context()
+	repoInfo := epic.GetRepositoryInfo(ctx, srv.client, owner, name, "")
+	if repoInfo == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		io.WriteString(rw, fmt.Sprintf("error: cannot get the repository info for `%v/%v`", owner, name))
+		return
+	}
+
+	created, updated, err := epic.ProvisionLabels(ctx, srv.client, owner, name, repoInfo.Labels)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadGateway)
+		io.WriteString(rw, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	json.NewEncoder(rw).Encode(struct {
+		Created []string `json:"created"`
+		Updated []string `json:"updated"`
+	}{
+		Created: created,
+		Updated: updated,
+	})
+}
+
 func (srv *AppServer) getQueueInfoForRepository(rw http.ResponseWriter, req *http.Request, repo string) {
 	if req.Method != "GET" {
 		rw.WriteHeader(http.StatusMethodNotAllowed)
@@ -0,0 +1,1326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+
+	"github.com/voyagegroup/popuko/epic"
+	"github.com/voyagegroup/popuko/event"
+	"github.com/voyagegroup/popuko/fakegithub"
+	"github.com/voyagegroup/popuko/forge"
+	"github.com/voyagegroup/popuko/queue"
+	"github.com/voyagegroup/popuko/report"
+	"github.com/voyagegroup/popuko/setting"
+	"github.com/voyagegroup/popuko/store"
+)
+
+const (
+	testOwner      = "voyagegroup"
+	testName       = "popuko"
+	testBotName    = "popuko"
+	testHookSecret = "secret"
+	testReviewer   = "nekoya"
+	testAuthor     = "tetsuharuohzeki"
+)
+
+const testOwnersFile = `{
+    "version": 0,
+    "reviewers": ["nekoya"],
+    "auto_merge.enabled": true,
+    "auto_merge.delete_branch": true
+}`
+
+type testServer struct {
+	t      *testing.T
+	srv    *AppServer
+	gh     *fakegithub.Server
+	root   string
+	done   chan string
+	nextID int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	root, err := ioutil.TempDir("", "popuko-e2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config = &setting.Settings{
+		Github: setting.GithubSetting{
+			BotName:    testBotName,
+			HookSecret: testHookSecret,
+		},
+	}
+
+	gh := fakegithub.NewServer()
+	gh.SetLogin(testBotName)
+	gh.AddRepository(testOwner, testName, "master")
+	gh.SetFile(testOwner, testName, "OWNERS.json", testOwnersFile)
+
+	srv := &AppServer{
+		client:            forge.NewGitHubClient(gh.Client()),
+		webhook:           forge.NewGitHubWebhook(),
+		botName:           testBotName,
+		hookSecret:        []byte(testHookSecret),
+		acceptRepo:        config.AcceptRepo,
+		restPrefix:        prefixRestAPI,
+		autoMergeRepo:     queue.NewAutoMergeQRepo(root),
+		assignStore:       store.NewFileStore(root, "assign"),
+		setting:           config,
+		availabilityStore: store.NewFileStore(root, "availability"),
+		deliveryHistory:   event.NewHistory(root),
+	}
+	srv.reporter = report.NewReporter(store.NewFileStore(root, "status"), config.StickyStatusComment, testBotName)
+	// Check only 1 pull request at once to test checks in background.
+	srv.unmergeableDetector = epic.NewUnmergeableDetector(srv.client, nil, srv.reporter, store.NewFileStore(root, "mergeability"), 1, 10*time.Millisecond)
+
+	ts := &testServer{
+		t:    t,
+		srv:  srv,
+		gh:   gh,
+		root: root,
+		done: make(chan string, 16),
+	}
+
+	srv.eventQueue = event.NewQueue(root, func(ctx context.Context, d *event.Delivery) error {
+		err := srv.processDelivery(ctx, d)
+		ts.done <- d.ID
+		return err
+	}, srv.deliveryHistory)
+	srv.eventQueue.Start(2)
+
+	return ts
+}
+
+func (ts *testServer) close() {
+	ts.srv.eventQueue.Close()
+	ts.gh.Close()
+	os.RemoveAll(ts.root)
+
+	if list := ts.gh.Unhandled(); len(list) > 0 {
+		ts.t.Errorf("the fake server received unsupported requests: %v", list)
+	}
+}
+
+// send signs `payload` as GitHub and sends it to the webhook handler.
+// This waits until the delivery has been processed if it is accepted.
+func (ts *testServer) send(eventType string, payload interface{}) *httptest.ResponseRecorder {
+	ts.nextID++
+	return ts.sendWithID(fmt.Sprintf("delivery-%v", ts.nextID), eventType, payload)
+}
+
+func (ts *testServer) sendWithID(id string, eventType string, payload interface{}) *httptest.ResponseRecorder {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	mac := hmac.New(sha1.New, []byte(testHookSecret))
+	mac.Write(body)
+
+	req := httptest.NewRequest("POST", prefixWebHookPath, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-GitHub-Delivery", id)
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+
+	rw := httptest.NewRecorder()
+	ts.srv.handleWebhook(rw, req)
+
+	if rw.Code == http.StatusAccepted {
+		select {
+		case <-ts.done:
+		case <-time.After(10 * time.Second):
+			ts.t.Fatalf("timed out to process the delivery %v", id)
+		}
+	}
+
+	return rw
+}
+
+func (ts *testServer) comment(number int, sender string, body string) *httptest.ResponseRecorder {
+	return ts.send("issue_comment", &github.IssueCommentEvent{
+		Action: github.String("created"),
+		Issue: &github.Issue{
+			Number: github.Int(number),
+			User:   &github.User{Login: github.String(testAuthor)},
+			PullRequestLinks: &github.PullRequestLinks{
+				URL: github.String("https://example.com"),
+			},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Int64(int64(ts.nextID + 1)),
+			Body: github.String(body),
+			User: &github.User{Login: github.String(sender)},
+		},
+		Repo:   testRepository(),
+		Sender: &github.User{Login: github.String(sender)},
+	})
+}
+
+func (ts *testServer) status(sha string, state string) *httptest.ResponseRecorder {
+	ts.gh.SetStatus(testOwner, testName, sha, "ci", state)
+	return ts.send("status", &github.StatusEvent{
+		ID:      github.Int64(int64(ts.nextID + 1)),
+		SHA:     github.String(sha),
+		State:   github.String(state),
+		Context: github.String("ci"),
+		Branches: []*github.Branch{
+			&github.Branch{Name: github.String("auto")},
+		},
+		Repo: testRepository(),
+	})
+}
+
+// push pushes a new commit to the pull request and sends `synchronize` event.
+func (ts *testServer) push(number int) *httptest.ResponseRecorder {
+	ts.gh.PushToPullRequest(testOwner, testName, number)
+	return ts.send("pull_request", &github.PullRequestEvent{
+		Action:      github.String("synchronize"),
+		Number:      github.Int(number),
+		PullRequest: ts.gh.PullRequestPayload(testOwner, testName, number),
+		Repo:        testRepository(),
+	})
+}
+
+// rebase pushes a new commit which has the same patch as the current head
+// to the pull request and sends `synchronize` event.
+func (ts *testServer) rebase(number int) *httptest.ResponseRecorder {
+	old := ts.gh.PullRequest(testOwner, testName, number).HeadSHA
+	ts.gh.SetPatch(testOwner, testName, old, "@@ -1,3 +1,4 @@\n+fix")
+
+	head := ts.gh.PushToPullRequest(testOwner, testName, number)
+	// The line numbers are shifted by the upstream.
+	ts.gh.SetPatch(testOwner, testName, head, "@@ -5,3 +5,4 @@\n+fix")
+
+	return ts.send("pull_request", &github.PullRequestEvent{
+		Action:      github.String("synchronize"),
+		Number:      github.Int(number),
+		PullRequest: ts.gh.PullRequestPayload(testOwner, testName, number),
+		Repo:        testRepository(),
+	})
+}
+
+// setState changes the state of the pull request and sends `closed` or `reopened` event.
+func (ts *testServer) setState(number int, action string) *httptest.ResponseRecorder {
+	state := "open"
+	if action == "closed" {
+		state = "closed"
+	}
+
+	ts.gh.SetPullRequestState(testOwner, testName, number, state)
+	return ts.send("pull_request", &github.PullRequestEvent{
+		Action:      github.String(action),
+		Number:      github.Int(number),
+		PullRequest: ts.gh.PullRequestPayload(testOwner, testName, number),
+		Repo:        testRepository(),
+	})
+}
+
+// label changes labels of the pull request by `sender` and sends `labeled` or `unlabeled` event for `label`.
+func (ts *testServer) label(number int, sender string, action string, label string) *httptest.ResponseRecorder {
+	labels := make([]string, 0)
+	for _, l := range ts.gh.Labels(testOwner, testName, number) {
+		if l != label {
+			labels = append(labels, l)
+		}
+	}
+	if action == "labeled" {
+		labels = append(labels, label)
+	}
+
+	ts.gh.SetLabels(testOwner, testName, number, labels)
+	return ts.send("pull_request", &github.PullRequestEvent{
+		Action:      github.String(action),
+		Number:      github.Int(number),
+		PullRequest: ts.gh.PullRequestPayload(testOwner, testName, number),
+		Label:       &github.Label{Name: github.String(label)},
+		Repo:        testRepository(),
+		Sender:      &github.User{Login: github.String(sender)},
+	})
+}
+
+// pushToMaster sends `push` event for `after` which is the new head of `master`.
+// This waits until checks for unmergeable pull requests have finished.
+func (ts *testServer) pushToMaster(after string) *httptest.ResponseRecorder {
+	rw := ts.send("push", &github.PushEvent{
+		Ref:     github.String("refs/heads/master"),
+		After:   github.String(after),
+		Compare: github.String("https://example.com/compare"),
+		Repo: &github.PushEventRepository{
+			Name:          github.String(testName),
+			DefaultBranch: github.String("master"),
+			Owner:         &github.User{Name: github.String(testOwner)},
+		},
+	})
+	ts.srv.unmergeableDetector.Wait()
+	return rw
+}
+
+func testRepository() *github.Repository {
+	return &github.Repository{
+		Name:          github.String(testName),
+		DefaultBranch: github.String("master"),
+		Owner:         &github.User{Login: github.String(testOwner)},
+	}
+}
+
+func hasComment(list []string, prefix string) bool {
+	for _, c := range list {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestScenarioAcceptAndMerge(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	pr := ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+
+	if rw := ts.comment(1, testReviewer, "@popuko r+"); rw.Code != http.StatusAccepted {
+		t.Errorf("the webhook should be accepted but %v", rw.Code)
+		return
+	}
+
+	if labels := ts.gh.Labels(testOwner, testName, 1); len(labels) != 1 || labels[0] != "S-awaiting-merge" {
+		t.Errorf("#1 should be labeled as S-awaiting-merge but %v", labels)
+		return
+	}
+
+	auto := ts.gh.Ref(testOwner, testName, "heads/auto")
+	if auto != pr.MergeSHA {
+		t.Errorf("the auto branch should point %v but %v", pr.MergeSHA, auto)
+		return
+	}
+
+	comments := ts.gh.Comments(testOwner, testName, 1)
+	if !hasComment(comments, ":pushpin:") || !hasComment(comments, ":hourglass:") {
+		t.Errorf("should comment about the approval and trying: %v", comments)
+		return
+	}
+
+	if reactions := ts.gh.Reactions(testOwner, testName, 1); len(reactions) != 1 || reactions[0] != "+1" {
+		t.Errorf("the command should be reacted as ok but %v", reactions)
+		return
+	}
+
+	ts.status(auto, "success")
+
+	actual := ts.gh.PullRequest(testOwner, testName, 1)
+	if !actual.Merged {
+		t.Errorf("#1 should be merged")
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 1), ":tada:") {
+		t.Errorf("should comment about the success")
+		return
+	}
+
+	if ref := ts.gh.Ref(testOwner, testName, "heads/feature"); ref != "" {
+		t.Errorf("the merged branch should be deleted but it points %v", ref)
+		return
+	}
+}
+
+func TestScenarioRequiresDistinctApprovals(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.SetFile(testOwner, testName, "OWNERS.json", `{
+    "version": 0,
+    "reviewers": ["nekoya", "pipimi"],
+    "mergeable_users": ["tetsuharuohzeki"],
+    "auto_merge.enabled": true,
+    "review.required_approvals": 2
+}`)
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+
+	// Named reviewers are not counted as approvers.
+	ts.comment(1, testAuthor, "@popuko r=nekoya,pipimi")
+	ts.comment(1, testReviewer, "@popuko r=nekoya,pipimi")
+	if ref := ts.gh.Ref(testOwner, testName, "heads/auto"); ref != "" {
+		t.Errorf("#1 should wait for the approval by another reviewer")
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 1), ":bookmark: Commit") {
+		t.Errorf("should tell the rest of approvals: %v", ts.gh.Comments(testOwner, testName, 1))
+		return
+	}
+
+	ts.comment(1, "pipimi", "@popuko r+")
+	if ref := ts.gh.Ref(testOwner, testName, "heads/auto"); ref == "" {
+		t.Errorf("#1 should be tried after 2 reviewers approved it")
+		return
+	}
+}
+
+func TestScenarioStickyStatusComment(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.srv.reporter = report.NewReporter(store.NewFileStore(ts.root, "status"), true, testBotName)
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "feature2")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	ts.comment(2, testReviewer, "@popuko r+")
+
+	if comments := ts.gh.Comments(testOwner, testName, 2); len(comments) != 1 || !hasComment(comments, report.Marker) {
+		t.Errorf("#2 should have only the status comment: %v", comments)
+		return
+	}
+
+	if status := ts.gh.Comments(testOwner, testName, 2)[0]; !strings.Contains(status, ":postbox: Queued") || !strings.Contains(status, "Position in the approved queue: 1") {
+		t.Errorf("the status comment should show the position in the queue: %v", status)
+		return
+	}
+
+	ts.status(ts.gh.Ref(testOwner, testName, "heads/auto"), "failure")
+
+	comments := ts.gh.Comments(testOwner, testName, 1)
+	if len(comments) != 2 || !strings.HasPrefix(comments[0], report.Marker) || !hasComment(comments, ":collision:") {
+		t.Errorf("#1 should have the status comment and the comment about the failure: %v", comments)
+		return
+	}
+
+	if status := comments[0]; !strings.Contains(status, ":collision: Tests failed") || !strings.Contains(status, "Last CI result: `failure`") {
+		t.Errorf("the status comment should be edited to the failure: %v", status)
+		return
+	}
+
+	// #2 is being tested now. Its status comment should be edited instead of a new comment.
+	comments = ts.gh.Comments(testOwner, testName, 2)
+	if len(comments) != 1 || !strings.Contains(comments[0], ":hourglass: Testing") {
+		t.Errorf("the status comment of #2 should be edited to testing: %v", comments)
+		return
+	}
+
+	// The comment which quotes the marker by others must not be edited.
+	ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "feature3")
+	quoted := report.Marker + "\nquoted by someone"
+	ts.gh.AddComment(testOwner, testName, 3, testAuthor, quoted)
+	ts.comment(3, testReviewer, "@popuko r+")
+
+	comments = ts.gh.Comments(testOwner, testName, 3)
+	if len(comments) != 2 || comments[0] != quoted || !strings.Contains(comments[1], ":postbox: Queued") {
+		t.Errorf("#3 should have the status comment posted by this bot: %v", comments)
+		return
+	}
+}
+
+func TestScenarioFailureTriesNextItem(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	second := ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	ts.comment(2, testReviewer, "@popuko r+")
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 2), ":postbox:") {
+		t.Errorf("#2 should be postponed")
+		return
+	}
+
+	ts.status(ts.gh.Ref(testOwner, testName, "heads/auto"), "failure")
+
+	if ts.gh.PullRequest(testOwner, testName, 1).Merged {
+		t.Errorf("#1 should not be merged")
+		return
+	}
+
+	if labels := ts.gh.Labels(testOwner, testName, 1); len(labels) != 1 || labels[0] != "S-fails-tests-with-upstream" {
+		t.Errorf("#1 should be labeled as S-fails-tests-with-upstream but %v", labels)
+		return
+	}
+
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != second.MergeSHA {
+		t.Errorf("#2 should be tried next: the auto branch points %v", auto)
+		return
+	}
+}
+
+func TestScenarioPushDequeuesPullRequest(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	second := ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "third")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	ts.comment(2, testReviewer, "@popuko r+")
+	ts.comment(3, testReviewer, "@popuko r+")
+
+	// The awaiting item.
+	ts.push(3)
+
+	if labels := ts.gh.Labels(testOwner, testName, 3); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+		t.Errorf("#3 should be labeled as S-awaiting-review but %v", labels)
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 3), ":no_entry_sign:") {
+		t.Errorf("#3 should be commented about the changed head")
+		return
+	}
+
+	// The active item.
+	ts.push(1)
+
+	if labels := ts.gh.Labels(testOwner, testName, 1); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+		t.Errorf("#1 should be labeled as S-awaiting-review but %v", labels)
+		return
+	}
+
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != second.MergeSHA {
+		t.Errorf("#2 should be tried next: the auto branch points %v", auto)
+		return
+	}
+
+	q := ts.srv.autoMergeRepo.Get(testOwner, testName)
+	q.Lock()
+	defer q.Unlock()
+
+	if awaiting := q.Load().Awaiting(); len(awaiting) != 0 {
+		t.Errorf("#3 should be removed from the queue but %+v", awaiting)
+		return
+	}
+}
+
+func TestScenarioRebaseKeepsApproval(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "third")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	ts.comment(2, testReviewer, "@popuko r+")
+	ts.comment(3, testReviewer, "@popuko r+")
+
+	// The awaiting item.
+	ts.rebase(2)
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 2), ":arrows_counterclockwise:") {
+		t.Errorf("should note that the approval is kept: %v", ts.gh.Comments(testOwner, testName, 2))
+		return
+	}
+
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 1 || labels[0] != "S-awaiting-merge" {
+		t.Errorf("#2 should be still labeled as S-awaiting-merge but %v", labels)
+		return
+	}
+
+	// The active item.
+	ts.rebase(1)
+
+	first := ts.gh.PullRequest(testOwner, testName, 1)
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != first.MergeSHA {
+		t.Errorf("the auto branch should be rebuilt for the new head of #1 but it points %v", auto)
+		return
+	}
+
+	// A real change requires the review again.
+	ts.push(3)
+
+	second := ts.gh.PullRequest(testOwner, testName, 2)
+
+	q := ts.srv.autoMergeRepo.Get(testOwner, testName)
+	q.Lock()
+	defer q.Unlock()
+
+	current := q.Load()
+	if active := current.GetActive(); active == nil || active.PullRequest != 1 || active.PrHead != first.HeadSHA {
+		t.Errorf("#1 should be active with the new head but %+v", active)
+		return
+	}
+
+	awaiting := current.Awaiting()
+	if len(awaiting) != 1 || awaiting[0].PullRequest != 2 || awaiting[0].PrHead != second.HeadSHA {
+		t.Errorf("only #2 should be awaiting with the new head but %+v", awaiting)
+		return
+	}
+}
+
+func TestScenarioCloseCleansUpQueue(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	third := ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "third")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	ts.comment(2, testReviewer, "@popuko r+")
+	ts.comment(3, testReviewer, "@popuko r+")
+
+	// The awaiting item.
+	ts.setState(2, "closed")
+
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 0 {
+		t.Errorf("#2 should not have any status labels but %v", labels)
+		return
+	}
+
+	// The active item.
+	ts.setState(1, "closed")
+
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != third.MergeSHA {
+		t.Errorf("#3 should be tried next: the auto branch points %v", auto)
+		return
+	}
+
+	ts.setState(2, "reopened")
+
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+		t.Errorf("#2 should be labeled as S-awaiting-review but %v", labels)
+		return
+	}
+
+	q := ts.srv.autoMergeRepo.Get(testOwner, testName)
+	q.Lock()
+	defer q.Unlock()
+
+	if awaiting := q.Load().Awaiting(); len(awaiting) != 0 {
+		t.Errorf("#2 should be removed from the queue but %+v", awaiting)
+		return
+	}
+}
+
+func TestScenarioDetectUnmergeable(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "third")
+	ts.gh.SetMergeable(testOwner, testName, 1, false)
+	ts.gh.SetMergeable(testOwner, testName, 3, false)
+
+	head := ts.gh.PushToBranch(testOwner, testName, "master")
+	ts.pushToMaster(head)
+
+	// #3 is checked in background.
+	for _, number := range []int{1, 3} {
+		if labels := ts.gh.Labels(testOwner, testName, number); len(labels) != 1 || labels[0] != "S-needs-rebase" {
+			t.Errorf("#%v should be labeled as S-needs-rebase but %v", number, labels)
+			return
+		}
+
+		if comments := ts.gh.Comments(testOwner, testName, number); len(comments) != 1 || !hasComment(comments, ":umbrella:") {
+			t.Errorf("#%v should be commented about the conflict: %v", number, comments)
+			return
+		}
+	}
+
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 0 {
+		t.Errorf("#2 should not be labeled but %v", labels)
+		return
+	}
+
+	// The result for the same head is cached.
+	ts.gh.SetLabels(testOwner, testName, 1, nil)
+	ts.pushToMaster(head)
+
+	if comments := ts.gh.Comments(testOwner, testName, 1); len(comments) != 1 {
+		t.Errorf("#1 should not be checked again for the same head: %v", comments)
+		return
+	}
+
+	ts.pushToMaster(ts.gh.PushToBranch(testOwner, testName, "master"))
+
+	if comments := ts.gh.Comments(testOwner, testName, 1); len(comments) != 2 {
+		t.Errorf("#1 should be checked again for the new head: %v", comments)
+		return
+	}
+}
+
+func TestScenarioShutdownUnmergeableDetector(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	// The rest are checked every hour.
+	ts.srv.unmergeableDetector = epic.NewUnmergeableDetector(ts.srv.client, nil, ts.srv.reporter, store.NewFileStore(ts.root, "mergeability"), 1, time.Hour)
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.gh.SetMergeable(testOwner, testName, 2, false)
+
+	ts.send("push", &github.PushEvent{
+		Ref:     github.String("refs/heads/master"),
+		After:   github.String(ts.gh.PushToBranch(testOwner, testName, "master")),
+		Compare: github.String("https://example.com/compare"),
+		Repo: &github.PushEventRepository{
+			Name:          github.String(testName),
+			DefaultBranch: github.String("master"),
+			Owner:         &github.User{Name: github.String(testOwner)},
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ts.srv.unmergeableDetector.Shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("should stop the background check without waiting for it")
+		return
+	}
+
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 0 {
+		t.Errorf("#2 should not be checked after the shutdown but %v", labels)
+		return
+	}
+}
+
+func TestScenarioProvisionLabels(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.SetFile(testOwner, testName, "OWNERS.json", `{
+    "version": 0,
+    "reviewers": ["nekoya"],
+    "auto_merge.enabled": true,
+    "labels": {
+        "prefix": "status/",
+        "awaiting_merge": {"name": "status/queued", "color": "#00FF00"}
+    }
+}`)
+	ts.gh.AddRepositoryLabel(testOwner, testName, "status/awaiting-review", "ffffff", "")
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+
+	ts.comment(1, "stranger", "@popuko labels")
+	if labels := ts.gh.RepositoryLabels(testOwner, testName); len(labels) != 1 {
+		t.Errorf("labels should not be provisioned by non-reviewers: %v", labels)
+		return
+	}
+
+	ts.comment(1, testReviewer, "@popuko labels")
+
+	labels := make(map[string]string)
+	for _, l := range ts.gh.RepositoryLabels(testOwner, testName) {
+		labels[l.GetName()] = l.GetColor()
+	}
+	if len(labels) != 5 || labels["status/queued"] != "00ff00" || labels["status/awaiting-review"] != "fbca04" || labels["status/needs-rebase"] == "" {
+		t.Errorf("status labels should be provisioned: %v", labels)
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 1), ":label:") {
+		t.Errorf("should comment about provisioned labels")
+		return
+	}
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	if actual := ts.gh.Labels(testOwner, testName, 1); len(actual) != 1 || actual[0] != "status/queued" {
+		t.Errorf("#1 should be labeled by the configured name but %v", actual)
+		return
+	}
+
+	config.API.Token = "token"
+	req := httptest.NewRequest("POST", prefixRestAPI+"/labels/"+testOwner+"/"+testName, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rw := httptest.NewRecorder()
+	ts.srv.handleRESTApiRequest(rw, req)
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), `"created":[]`) {
+		t.Errorf("REST API should report that all labels are up to date: %v %v", rw.Code, rw.Body.String())
+		return
+	}
+}
+
+func TestScenarioLabelDrivenWorkflow(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	first := ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+
+	// The change by the bot itself is ignored.
+	ts.label(1, testBotName, "labeled", "S-awaiting-merge")
+	if ref := ts.gh.Ref(testOwner, testName, "heads/auto"); ref != "" {
+		t.Errorf("the label by the bot should not create the auto branch")
+		return
+	}
+
+	ts.label(1, testReviewer, "labeled", "S-awaiting-merge")
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != first.MergeSHA {
+		t.Errorf("#1 should be tried by the label: the auto branch points %v", auto)
+		return
+	}
+
+	ts.comment(2, testReviewer, "@popuko r+")
+	ts.label(2, testReviewer, "unlabeled", "S-awaiting-merge")
+
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+		t.Errorf("#2 should be labeled as S-awaiting-review but %v", labels)
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 2), ":outbox_tray:") {
+		t.Errorf("should comment about the cancellation")
+		return
+	}
+
+	ts.label(1, "stranger", "unlabeled", "S-awaiting-merge")
+
+	if labels := ts.gh.Labels(testOwner, testName, 1); len(labels) != 1 || labels[0] != "S-awaiting-merge" {
+		t.Errorf("the label removed by the stranger should be reverted but %v", labels)
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 1), ":no_entry_sign:") {
+		t.Errorf("should explain the reverted label")
+		return
+	}
+
+	q := ts.srv.autoMergeRepo.Get(testOwner, testName)
+	q.Lock()
+	defer q.Unlock()
+
+	current := q.Load()
+	if active := current.GetActive(); active == nil || active.PullRequest != 1 {
+		t.Errorf("#1 should be still active but %+v", active)
+		return
+	}
+
+	if awaiting := current.Awaiting(); len(awaiting) != 0 {
+		t.Errorf("#2 should be removed from the queue but %+v", awaiting)
+		return
+	}
+}
+
+func TestScenarioBlockingPullRequest(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.SetFile(testOwner, testName, "OWNERS.json", `{
+    "version": 0,
+    "reviewers": ["nekoya"],
+    "auto_merge.enabled": true,
+    "merge.blocking_labels": ["do-not-merge"],
+    "merge.blocking_title_patterns": ["^WIP:"]
+}`)
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.SetTitle(testOwner, testName, 1, "WIP: first")
+	ts.comment(1, testReviewer, "@popuko r+")
+
+	if ref := ts.gh.Ref(testOwner, testName, "heads/auto"); ref != "" {
+		t.Errorf("the auto branch should not be created for the WIP pull request")
+		return
+	}
+
+	if comments := ts.gh.Comments(testOwner, testName, 1); !hasComment(comments, ":no_entry_sign:") {
+		t.Errorf("should explain the rejection: %v", comments)
+		return
+	}
+
+	second := ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	third := ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "third")
+	ts.comment(2, testReviewer, "@popuko r+")
+	ts.comment(3, testReviewer, "@popuko r+")
+
+	// #3 is labeled as blocking after it was approved.
+	ts.gh.SetLabels(testOwner, testName, 3, []string{"S-awaiting-merge", "do-not-merge"})
+	ts.status(second.MergeSHA, "success")
+
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto == third.MergeSHA {
+		t.Errorf("#3 should not be tried")
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 3), ":construction:") {
+		t.Errorf("should explain why #3 is removed from the queue")
+		return
+	}
+
+	if labels := ts.gh.Labels(testOwner, testName, 3); len(labels) != 2 || labels[0] != "do-not-merge" || labels[1] != "S-awaiting-review" {
+		t.Errorf("#3 should be labeled as S-awaiting-review but %v", labels)
+		return
+	}
+}
+
+func TestScenarioRespectsReviewsAndBranchProtection(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	// `codeowner` is not a reviewer in OWNERS.json, but can write to the repository.
+	ts.gh.SetPermission(testOwner, testName, "codeowner", "write")
+
+	ts.gh.SetBranchProtection(testOwner, testName, "master", &github.Protection{
+		RequiredPullRequestReviews: &github.PullRequestReviewsEnforcement{
+			RequiredApprovingReviewCount: 1,
+		},
+	})
+
+	first := ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "third")
+	fourth := ts.gh.AddPullRequest(testOwner, testName, 4, testAuthor, "fourth")
+
+	ts.gh.AddReview(testOwner, testName, 1, testReviewer, "APPROVED")
+	ts.gh.AddReview(testOwner, testName, 2, testReviewer, "APPROVED")
+	ts.gh.AddReview(testOwner, testName, 2, "codeowner", "CHANGES_REQUESTED")
+	ts.gh.AddReview(testOwner, testName, 4, testReviewer, "APPROVED")
+	// Changes requested by someone who cannot write to the repository do not block it.
+	ts.gh.AddReview(testOwner, testName, 4, "passerby", "CHANGES_REQUESTED")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	ts.comment(2, testReviewer, "@popuko r+")
+	ts.comment(3, testReviewer, "@popuko r+")
+	ts.comment(4, testReviewer, "@popuko r+")
+
+	ts.status(first.MergeSHA, "success")
+
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != fourth.MergeSHA {
+		t.Errorf("#4 should be tried next: the auto branch points %v", auto)
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 2), ":construction: This has been removed from the approved queue because it has changes requested by `codeowner`") {
+		t.Errorf("should explain the changes requested: %v", ts.gh.Comments(testOwner, testName, 2))
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 3), ":construction:") {
+		t.Errorf("should explain the branch protection: %v", ts.gh.Comments(testOwner, testName, 3))
+		return
+	}
+
+	for _, number := range []int{2, 3} {
+		if labels := ts.gh.Labels(testOwner, testName, number); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+			t.Errorf("#%v should be labeled as S-awaiting-review but %v", number, labels)
+			return
+		}
+	}
+}
+
+func countComments(list []string, prefix string) int {
+	n := 0
+	for _, c := range list {
+		if strings.HasPrefix(c, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestScenarioMergeFreeze(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	now := time.Now().UTC()
+	frozen := fmt.Sprintf(`{
+    "version": 0,
+    "reviewers": ["nekoya"],
+    "mergeable_users": ["tetsuharuohzeki"],
+    "auto_merge.enabled": true,
+    "merge.freezes": [{"from": "%v", "until": "%v", "reason": "Release"}],
+    "merge.bypass_priority": 10
+}`, now.AddDate(0, 0, -1).Format("2006-01-02"), now.AddDate(0, 0, 1).Format("2006-01-02"))
+	ts.gh.SetFile(testOwner, testName, "OWNERS.json", frozen)
+
+	first := ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	hotfix := ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "hotfix")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	ts.comment(2, testReviewer, "@popuko r+")
+
+	if ref := ts.gh.Ref(testOwner, testName, "heads/auto"); ref != "" {
+		t.Errorf("the auto branch should not be created in the freeze")
+		return
+	}
+
+	if n := countComments(ts.gh.Comments(testOwner, testName, 1), ":zzz:"); n != 1 {
+		t.Errorf("should tell #1 only once that it is waiting but %v times", n)
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 2), ":zzz: This is waiting for the merge window because the merge freeze (Release)") {
+		t.Errorf("should tell #2 that it is waiting: %v", ts.gh.Comments(testOwner, testName, 2))
+		return
+	}
+
+	// Only reviewers can give the priority to bypass the freeze.
+	ts.comment(3, testAuthor, "@popuko r=nekoya p=10")
+	if !hasComment(ts.gh.Comments(testOwner, testName, 3), ":no_entry_sign: @tetsuharuohzeki Your command is rejected: `tetsuharuohzeki` does not have the privilege to give the priority 10") {
+		t.Errorf("should reject the priority by the author: %v", ts.gh.Comments(testOwner, testName, 3))
+		return
+	}
+
+	// The hotfix bypasses the freeze by its priority even if it's behind others.
+	ts.comment(3, testReviewer, "@popuko r+ p=10")
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != hotfix.MergeSHA {
+		t.Errorf("#3 should be tried in the freeze: the auto branch points %v", auto)
+		return
+	}
+
+	ts.status(hotfix.MergeSHA, "success")
+	if !ts.gh.PullRequest(testOwner, testName, 3).Merged {
+		t.Errorf("#3 should be merged")
+		return
+	}
+
+	if n := countComments(ts.gh.Comments(testOwner, testName, 1), ":zzz:"); n != 1 {
+		t.Errorf("should not tell #1 again while the queue is held but %v times", n)
+		return
+	}
+
+	ts.srv.resumeHeldQueues(context.Background())
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != hotfix.MergeSHA {
+		t.Errorf("the queue should not be resumed in the freeze: the auto branch points %v", auto)
+		return
+	}
+
+	ts.gh.SetFile(testOwner, testName, "OWNERS.json", testOwnersFile)
+	ts.srv.resumeHeldQueues(context.Background())
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != first.MergeSHA {
+		t.Errorf("#1 should be tried after the freeze: the auto branch points %v", auto)
+		return
+	}
+
+	// #1 is put back to the queue if the freeze starts while testing it.
+	ts.gh.SetFile(testOwner, testName, "OWNERS.json", frozen)
+	ts.status(first.MergeSHA, "success")
+	if ts.gh.PullRequest(testOwner, testName, 1).Merged {
+		t.Errorf("#1 should not be merged in the freeze")
+		return
+	}
+
+	if n := countComments(ts.gh.Comments(testOwner, testName, 1), ":zzz:"); n != 2 {
+		t.Errorf("should tell #1 that it is waiting again but %v times", n)
+		return
+	}
+
+	ts.gh.SetFile(testOwner, testName, "OWNERS.json", testOwnersFile)
+	ts.srv.resumeHeldQueues(context.Background())
+	ts.status(first.MergeSHA, "success")
+	if !ts.gh.PullRequest(testOwner, testName, 1).Merged {
+		t.Errorf("#1 should be merged after the freeze")
+		return
+	}
+}
+
+func TestScenarioDependencies(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	first := ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	second := ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.gh.SetBody(testOwner, testName, 2, "This uses the API by #1.\n\nDepends-on: #1")
+
+	ts.comment(2, testReviewer, "@popuko r+")
+	if ref := ts.gh.Ref(testOwner, testName, "heads/auto"); ref != "" {
+		t.Errorf("#2 should wait for #1: the auto branch points %v", ref)
+		return
+	}
+
+	req := httptest.NewRequest("GET", prefixRestAPI+"/queue/"+testOwner+"/"+testName, nil)
+	rw := httptest.NewRecorder()
+	ts.srv.handleRESTApiRequest(rw, req)
+	if body := rw.Body.String(); !strings.Contains(body, `"depends_on"`) || !strings.Contains(body, `"number": 1`) {
+		t.Errorf("the queue API should show the dependency of #2: %v", body)
+		return
+	}
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != first.MergeSHA {
+		t.Errorf("#1 should be tried before #2: the auto branch points %v", auto)
+		return
+	}
+
+	ts.status(first.MergeSHA, "success")
+	if auto := ts.gh.Ref(testOwner, testName, "heads/auto"); auto != second.MergeSHA {
+		t.Errorf("#2 should be tried after #1 is merged: the auto branch points %v", auto)
+		return
+	}
+
+	// The dependency declared by the command is closed without being merged.
+	ts.gh.AddPullRequest(testOwner, testName, 3, testAuthor, "third")
+	ts.gh.AddPullRequest(testOwner, testName, 4, "someone", "fourth")
+	if rw := ts.comment(3, testAuthor, "@popuko depends=#4"); rw.Code != http.StatusAccepted {
+		t.Errorf("the webhook should be accepted but %v", rw.Code)
+		return
+	}
+
+	if hasComment(ts.gh.Comments(testOwner, testName, 3), ":no_entry_sign:") {
+		t.Errorf("the command by the author should be accepted: %v", ts.gh.Comments(testOwner, testName, 3))
+		return
+	}
+
+	ts.comment(3, testReviewer, "@popuko r+")
+	ts.setState(4, "closed")
+
+	expected := ":construction: @" + testAuthor + " This has been removed from the approved queue because it depends on voyagegroup/popuko#4"
+	if !hasComment(ts.gh.Comments(testOwner, testName, 3), expected) {
+		t.Errorf("should tell the author that #4 has been closed: %v", ts.gh.Comments(testOwner, testName, 3))
+		return
+	}
+
+	if labels := ts.gh.Labels(testOwner, testName, 3); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+		t.Errorf("#3 should be labeled as S-awaiting-review but %v", labels)
+		return
+	}
+
+	q := ts.srv.autoMergeRepo.Get(testOwner, testName)
+	q.Lock()
+	defer q.Unlock()
+
+	current := q.Load()
+	if deps := current.GetDependencies(3); len(deps) != 1 || deps[0].String() != "voyagegroup/popuko#4" {
+		t.Errorf("#3 should depend on #4 by the command but %v", deps)
+		return
+	}
+
+	if has, _ := current.IsAwaiting(3); has {
+		t.Errorf("#3 should be removed from the queue")
+		return
+	}
+}
+
+func TestScenarioCircularDependencies(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.gh.SetBody(testOwner, testName, 1, "Depends-on: #2")
+	ts.gh.SetBody(testOwner, testName, 2, "Depends-on: #1")
+
+	ts.comment(1, testReviewer, "@popuko r+")
+	if labels := ts.gh.Labels(testOwner, testName, 1); len(labels) != 1 || labels[0] != "S-awaiting-merge" {
+		t.Errorf("#1 should wait for #2 in the queue but %v", labels)
+		return
+	}
+
+	// Both are removed from the queue because neither can be merged before the other.
+	ts.comment(2, testReviewer, "@popuko r+")
+	for _, number := range []int{1, 2} {
+		expected := ":construction: This has been removed from the approved queue because it has circular dependencies: #2 -> #1 -> #2"
+		if !hasComment(ts.gh.Comments(testOwner, testName, number), expected) {
+			t.Errorf("should tell that #%v has circular dependencies: %v", number, ts.gh.Comments(testOwner, testName, number))
+			return
+		}
+
+		if labels := ts.gh.Labels(testOwner, testName, number); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+			t.Errorf("#%v should be labeled as S-awaiting-review but %v", number, labels)
+			return
+		}
+	}
+
+	q := ts.srv.autoMergeRepo.Get(testOwner, testName)
+	q.Lock()
+	defer q.Unlock()
+
+	if awaiting := q.Load().Awaiting(); len(awaiting) != 0 {
+		t.Errorf("the queue should be empty but %v", awaiting)
+		return
+	}
+}
+
+func TestScenarioReconcileLabels(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "first")
+	ts.gh.AddPullRequest(testOwner, testName, 2, testAuthor, "second")
+	ts.comment(1, testReviewer, "@popuko r+")
+
+	// #2 may be being approved now. Its label must be left until it settles.
+	ts.gh.SetLabels(testOwner, testName, 2, []string{"S-awaiting-merge"})
+	ts.srv.reconcileQueues(context.Background())
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 1 || labels[0] != "S-awaiting-merge" {
+		t.Errorf("should not change the label updated recently but %v", labels)
+		return
+	}
+
+	ts.gh.SetUpdatedAt(testOwner, testName, 2, time.Now().Add(-time.Hour))
+	ts.srv.reconcileQueues(context.Background())
+	if labels := ts.gh.Labels(testOwner, testName, 2); len(labels) != 1 || labels[0] != "S-awaiting-review" {
+		t.Errorf("should set back the label of #2 which is not in the queue but %v", labels)
+		return
+	}
+
+	if !hasComment(ts.gh.Comments(testOwner, testName, 2), ":question: This pull request is not in the approved queue.") {
+		t.Errorf("should tell that #2 is not in the queue: %v", ts.gh.Comments(testOwner, testName, 2))
+		return
+	}
+}
+
+func TestScenarioRejectsCommandByNonReviewer(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+	ts.comment(1, "stranger", "@popuko r+")
+
+	if labels := ts.gh.Labels(testOwner, testName, 1); len(labels) != 0 {
+		t.Errorf("#1 should not be labeled but %v", labels)
+		return
+	}
+
+	if ref := ts.gh.Ref(testOwner, testName, "heads/auto"); ref != "" {
+		t.Errorf("the auto branch should not be created")
+		return
+	}
+
+	if reactions := ts.gh.Reactions(testOwner, testName, 1); len(reactions) != 1 || reactions[0] != "-1" {
+		t.Errorf("the command should be reacted as rejected but %v", reactions)
+		return
+	}
+
+	if comments := ts.gh.Comments(testOwner, testName, 1); !hasComment(comments, ":no_entry_sign:") {
+		t.Errorf("should explain the rejection: %v", comments)
+		return
+	}
+}
+
+func TestScenarioReactsToMalformedCommand(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+	ts.comment(1, testReviewer, "@popuko r +")
+
+	if reactions := ts.gh.Reactions(testOwner, testName, 1); len(reactions) != 1 || reactions[0] != "confused" {
+		t.Errorf("the command should be reacted as a parse error but %v", reactions)
+		return
+	}
+
+	if comments := ts.gh.Comments(testOwner, testName, 1); !hasComment(comments, ":grey_question:") {
+		t.Errorf("should explain the syntax: %v", comments)
+		return
+	}
+
+	ts.comment(1, testReviewer, "Thank you, @popuko!")
+	if reactions := ts.gh.Reactions(testOwner, testName, 2); len(reactions) != 0 {
+		t.Errorf("should not react to a comment which is not a command but %v", reactions)
+		return
+	}
+}
+
+func TestScenarioSkipsRedelivery(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+
+	payload := &github.IssueCommentEvent{
+		Action: github.String("created"),
+		Issue: &github.Issue{
+			Number: github.Int(1),
+			User:   &github.User{Login: github.String(testAuthor)},
+			PullRequestLinks: &github.PullRequestLinks{
+				URL: github.String("https://example.com"),
+			},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Int64(1),
+			Body: github.String("r? @nekoya"),
+			User: &github.User{Login: github.String(testAuthor)},
+		},
+		Repo:   testRepository(),
+		Sender: &github.User{Login: github.String(testAuthor)},
+	}
+
+	if rw := ts.sendWithID("same", "issue_comment", payload); rw.Code != http.StatusAccepted {
+		t.Errorf("the first delivery should be accepted but %v", rw.Code)
+		return
+	}
+
+	if rw := ts.sendWithID("same", "issue_comment", payload); rw.Code != http.StatusOK {
+		t.Errorf("the redelivery should be skipped but %v", rw.Code)
+		return
+	}
+
+	if pr := ts.gh.PullRequest(testOwner, testName, 1); len(pr.Assignees) != 1 {
+		t.Errorf("#1 should be assigned only once: %v", pr.Assignees)
+		return
+	}
+}
+
+func TestScenarioRejectsInvalidSignature(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	req := httptest.NewRequest("POST", prefixWebHookPath, strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "issue_comment")
+	req.Header.Set("X-Hub-Signature", "sha1=0000")
+
+	rw := httptest.NewRecorder()
+	ts.srv.handleWebhook(rw, req)
+	if rw.Code != http.StatusPreconditionFailed {
+		t.Errorf("should reject the invalid signature but %v", rw.Code)
+		return
+	}
+}
+
+func TestScenarioRejectsUnacceptedRepository(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+
+	ts.gh.AddPullRequest(testOwner, testName, 1, testAuthor, "feature")
+	ts.srv.acceptRepo = func(owner, name string) bool {
+		return false
+	}
+
+	if rw := ts.sendWithID("unaccepted", "issue_comment", &github.IssueCommentEvent{
+		Action: github.String("created"),
+		Issue: &github.Issue{
+			Number: github.Int(1),
+			User:   &github.User{Login: github.String(testAuthor)},
+			PullRequestLinks: &github.PullRequestLinks{
+				URL: github.String("https://example.com"),
+			},
+		},
+		Comment: &github.IssueComment{
+			ID:   github.Int64(1),
+			Body: github.String("r? @nekoya"),
+			User: &github.User{Login: github.String(testAuthor)},
+		},
+		Repo:   testRepository(),
+		Sender: &github.User{Login: github.String(testAuthor)},
+	}); rw.Code != http.StatusForbidden {
+		t.Errorf("the delivery from the unaccepted repository should be rejected but %v", rw.Code)
+		return
+	}
+
+	if r := ts.srv.deliveryHistory.Get("unaccepted"); r != nil {
+		t.Errorf("the rejected delivery should not be recorded: %+v", r)
+		return
+	}
+
+	if pr := ts.gh.PullRequest(testOwner, testName, 1); len(pr.Assignees) != 0 {
+		t.Errorf("#1 should not be assigned: %v", pr.Assignees)
+		return
+	}
+}
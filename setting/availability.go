@@ -0,0 +1,102 @@
+package setting
+
+import (
+	"log"
+	"time"
+)
+
+// Availability describes whether a reviewer can accept a new review.
+type Availability struct {
+	// The periods while the reviewer is away (e.g. vacation).
+	Away []AwayPeriod `json:"away,omitempty"`
+
+	// The maximum number of opened pull requests assigned to the reviewer.
+	// 0 means no limit.
+	MaxOpenReviews int `json:"max_open_reviews,omitempty"`
+}
+
+type AwayPeriod struct {
+	// RFC3339 (`2006-01-02T15:04:05Z07:00`) or a date (`2006-01-02`, in UTC).
+	From string `json:"from"`
+	// RFC3339 or a date. The date means the end of the day.
+	Until string `json:"until"`
+}
+
+// IsAway returns the period which contains `now` if the reviewer is away.
+func (a *Availability) IsAway(now time.Time) (bool, *AwayPeriod) {
+	if a == nil {
+		return false, nil
+	}
+
+	for i := range a.Away {
+		p := &a.Away[i]
+		ok, from, until := p.parse()
+		if !ok {
+			continue
+		}
+
+		if !now.Before(from) && now.Before(until) {
+			return true, p
+		}
+	}
+
+	return false, nil
+}
+
+func (p *AwayPeriod) parse() (ok bool, from time.Time, until time.Time) {
+	ok, from = parseAvailabilityTime(p.From, false)
+	if !ok {
+		log.Printf("warn: `%v` is invalid as the time\n", p.From)
+		return false, from, until
+	}
+
+	ok, until = parseAvailabilityTime(p.Until, true)
+	if !ok {
+		log.Printf("warn: `%v` is invalid as the time\n", p.Until)
+		return false, from, until
+	}
+
+	return true, from, until
+}
+
+func parseAvailabilityTime(v string, endOfDay bool) (bool, time.Time) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return true, t
+	}
+
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return false, t
+	}
+
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return true, t
+}
+
+// Availability returns the availability of the reviewer. This returns nil if it is not configured.
+func (r *RepositoryInfo) Availability(name string) *Availability {
+	return r.availability[name]
+}
+
+// HasReviewLimit returns true if some reviewers have `max_open_reviews`.
+func (r *RepositoryInfo) HasReviewLimit() bool {
+	for _, a := range r.availability {
+		if a != nil && a.MaxOpenReviews > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// OverrideAvailability overrides the availability by `list` per reviewer.
+func (r *RepositoryInfo) OverrideAvailability(list map[string]*Availability) {
+	if r.availability == nil {
+		r.availability = make(map[string]*Availability)
+	}
+
+	for name, a := range list {
+		r.availability[name] = a
+	}
+}
@@ -0,0 +1,68 @@
+package setting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAvailabilityIsAway(t *testing.T) {
+	a := &Availability{
+		Away: []AwayPeriod{
+			AwayPeriod{
+				From:  "2026-08-01",
+				Until: "2026-08-14",
+			},
+			AwayPeriod{
+				From:  "2026-09-01T09:00:00+09:00",
+				Until: "2026-09-01T18:00:00+09:00",
+			},
+		},
+	}
+
+	type Testcase struct {
+		now      string
+		expected bool
+	}
+	list := []Testcase{
+		Testcase{
+			now:      "2026-07-31T23:59:59Z",
+			expected: false,
+		},
+		Testcase{
+			now:      "2026-08-01T00:00:00Z",
+			expected: true,
+		},
+		Testcase{
+			now:      "2026-08-14T23:59:59Z",
+			expected: true,
+		},
+		Testcase{
+			now:      "2026-08-15T00:00:00Z",
+			expected: false,
+		},
+		Testcase{
+			now:      "2026-09-01T01:00:00Z",
+			expected: true,
+		},
+		Testcase{
+			now:      "2026-09-01T10:00:00Z",
+			expected: false,
+		},
+	}
+
+	for _, test := range list {
+		now, err := time.Parse(time.RFC3339, test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual, _ := a.IsAway(now); actual != test.expected {
+			t.Errorf("%+v should be `%v` but `%v`", test, test.expected, actual)
+		}
+	}
+
+	var none *Availability
+	if actual, _ := none.IsAway(time.Now()); actual {
+		t.Errorf("nil should not be away")
+	}
+}
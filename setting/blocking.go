@@ -0,0 +1,47 @@
+package setting
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+func compileTitlePatterns(list []string) []*regexp.Regexp {
+	result := make([]*regexp.Regexp, 0, len(list))
+	for _, pattern := range list {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("warn: `%v` is invalid as `merge.blocking_title_patterns`: %v\n", pattern, err)
+			continue
+		}
+
+		result = append(result, re)
+	}
+	return result
+}
+
+// BlockingReason returns why a pull request cannot be approved nor merged.
+// This returns the empty string if nothing blocks it.
+func (r *RepositoryInfo) BlockingReason(draft bool, title string, labels []string) string {
+	if draft && !r.AllowDraft {
+		return "is a draft"
+	}
+
+	for _, blocking := range r.blockingLabels {
+		for _, l := range labels {
+			// Label names are case-insensitive on GitHub.
+			if strings.EqualFold(l, blocking) {
+				return fmt.Sprintf("has the blocking label `%v`", l)
+			}
+		}
+	}
+
+	for _, re := range r.blockingTitles {
+		if re.MatchString(title) {
+			return fmt.Sprintf("has the title which matches the blocking pattern `%v`", re)
+		}
+	}
+
+	return ""
+}
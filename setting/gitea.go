@@ -0,0 +1,22 @@
+package setting
+
+type GiteaSetting struct {
+	BotName      string   `toml:"botname"`
+	Token        string   `toml:"api_token"`
+	HookSecret   string   `toml:"webhook_secret"`
+	Repositories []string `toml:"accepted_repositoies"`
+	// The root url of the Gitea instance (e.g. `https://gitea.example.com/`).
+	// This bot does not work with Gitea if this is empty.
+	BaseURL string `toml:"base_url"`
+
+	acceptedRepos map[string]bool
+}
+
+func initGiteaSetting(g *GiteaSetting) {
+	g.acceptedRepos = acceptedRepoSet(g.Repositories)
+	g.Repositories = nil
+}
+
+func (g *GiteaSetting) accept(owner, name string) bool {
+	return acceptRepo(g.acceptedRepos, owner, name)
+}
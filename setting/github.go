@@ -12,12 +12,17 @@ type GithubSetting struct {
 }
 
 func initGithubSetting(g *GithubSetting) {
-	list := g.Repositories
+	g.acceptedRepos = acceptedRepoSet(g.Repositories)
 	g.Repositories = nil
+}
 
+func (g *GithubSetting) accept(owner, name string) bool {
+	return acceptRepo(g.acceptedRepos, owner, name)
+}
+
+func acceptedRepoSet(list []string) map[string]bool {
 	if (list == nil) || (len(list) == 0) {
-		g.acceptedRepos = nil
-		return
+		return nil
 	}
 
 	m := make(map[string]bool)
@@ -25,17 +30,16 @@ func initGithubSetting(g *GithubSetting) {
 		m[r] = true
 	}
 
-	g.acceptedRepos = m
-	return
+	return m
 }
 
-func (g *GithubSetting) accept(owner, name string) bool {
+func acceptRepo(acceptedRepos map[string]bool, owner, name string) bool {
 	// We regards the empty list as "Accept all incoming webhook".
-	if g.acceptedRepos == nil {
+	if acceptedRepos == nil {
 		return true
 	}
 
 	k := owner + "/" + name
-	_, ok := g.acceptedRepos[k]
+	_, ok := acceptedRepos[k]
 	return ok
 }
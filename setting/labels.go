@@ -0,0 +1,162 @@
+package setting
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+const defaultStatusLabelPrefix string = "S-"
+
+// LabelsSetting overrides the status labels in `OWNERS.json`.
+// A label which is not specified here is named as `<prefix><suffix>` (e.g. `S-awaiting-review`).
+type LabelsSetting struct {
+	// This bot removes labels which have this prefix in addition to status labels
+	// when it changes the status of a pull request. The default value is `S-` if this is not set.
+	// `""` means no prefix.
+	Prefix *string `json:"prefix,omitempty"`
+
+	AwaitingReview         *LabelSetting `json:"awaiting_review,omitempty"`
+	AwaitingMerge          *LabelSetting `json:"awaiting_merge,omitempty"`
+	NeedsRebase            *LabelSetting `json:"needs_rebase,omitempty"`
+	FailsTestsWithUpstream *LabelSetting `json:"fails_tests_with_upstream,omitempty"`
+	TimedOutWithUpstream   *LabelSetting `json:"timed_out_with_upstream,omitempty"`
+}
+
+type LabelSetting struct {
+	Name string `json:"name,omitempty"`
+	// The hex color code without `#` (e.g. "0e8a16").
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Label is a status label which this bot uses and provisions to the repository.
+type Label struct {
+	Name        string
+	Color       string
+	Description string
+}
+
+// StatusLabels is the set of status labels for a repository.
+type StatusLabels struct {
+	// This may be empty if the repository does not use the prefix.
+	Prefix string
+
+	AwaitingReview         Label
+	AwaitingMerge          Label
+	NeedsRebase            Label
+	FailsTestsWithUpstream Label
+	TimedOutWithUpstream   Label
+}
+
+var defaultStatusLabels = StatusLabels{
+	Prefix: defaultStatusLabelPrefix,
+	AwaitingReview: Label{
+		Name:        "awaiting-review",
+		Color:       "fbca04",
+		Description: "Waiting for the review",
+	},
+	AwaitingMerge: Label{
+		Name:        "awaiting-merge",
+		Color:       "0e8a16",
+		Description: "Approved and queued to be merged",
+	},
+	NeedsRebase: Label{
+		Name:        "needs-rebase",
+		Color:       "d93f0b",
+		Description: "Has a merge conflict with the upstream",
+	},
+	FailsTestsWithUpstream: Label{
+		Name:        "fails-tests-with-upstream",
+		Color:       "b60205",
+		Description: "Failed CI with the upstream",
+	},
+	TimedOutWithUpstream: Label{
+		Name:        "timed-out-with-upstream",
+		Color:       "c5def5",
+		Description: "CI did not report any result with the upstream",
+	},
+}
+
+var colorPattern = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+
+// DefaultStatusLabels returns the status labels which are used if `OWNERS.json` does not override them.
+func DefaultStatusLabels() *StatusLabels {
+	return (*LabelsSetting)(nil).toStatusLabels()
+}
+
+func (s *LabelsSetting) toStatusLabels() *StatusLabels {
+	if s == nil {
+		s = &LabelsSetting{}
+	}
+
+	prefix := defaultStatusLabelPrefix
+	if s.Prefix != nil {
+		prefix = *s.Prefix
+	}
+
+	d := defaultStatusLabels
+	return &StatusLabels{
+		Prefix:                 prefix,
+		AwaitingReview:         resolveLabel(prefix, d.AwaitingReview, s.AwaitingReview),
+		AwaitingMerge:          resolveLabel(prefix, d.AwaitingMerge, s.AwaitingMerge),
+		NeedsRebase:            resolveLabel(prefix, d.NeedsRebase, s.NeedsRebase),
+		FailsTestsWithUpstream: resolveLabel(prefix, d.FailsTestsWithUpstream, s.FailsTestsWithUpstream),
+		TimedOutWithUpstream:   resolveLabel(prefix, d.TimedOutWithUpstream, s.TimedOutWithUpstream),
+	}
+}
+
+func resolveLabel(prefix string, base Label, override *LabelSetting) Label {
+	label := Label{
+		Name:        prefix + base.Name,
+		Color:       base.Color,
+		Description: base.Description,
+	}
+
+	if override == nil {
+		return label
+	}
+
+	if name := override.Name; name != "" {
+		label.Name = name
+	}
+
+	if color := strings.TrimPrefix(override.Color, "#"); color != "" {
+		if colorPattern.MatchString(color) {
+			label.Color = strings.ToLower(color)
+		} else {
+			log.Printf("warn: `%v` is invalid color for `%v`.\n", override.Color, label.Name)
+		}
+	}
+
+	if override.Description != "" {
+		label.Description = override.Description
+	}
+
+	return label
+}
+
+// All returns all status labels.
+func (l *StatusLabels) All() []Label {
+	return []Label{
+		l.AwaitingReview,
+		l.AwaitingMerge,
+		l.NeedsRebase,
+		l.FailsTestsWithUpstream,
+		l.TimedOutWithUpstream,
+	}
+}
+
+// IsStatusLabel returns true if `name` is regarded as a status label.
+func (l *StatusLabels) IsStatusLabel(name string) bool {
+	if l.Prefix != "" && strings.HasPrefix(name, l.Prefix) {
+		return true
+	}
+
+	for _, label := range l.All() {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
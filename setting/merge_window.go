@@ -0,0 +1,225 @@
+package setting
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// MergeWindow is the period of a day in which this bot may merge pull requests.
+type MergeWindow struct {
+	// The days of the week (e.g. "Mon", "Tuesday"). Every day if this is empty.
+	Days []string `json:"days,omitempty"`
+	// "HH:MM" in `timezone`.
+	Start string `json:"start"`
+	// "HH:MM" in `timezone`. The window continues to the next day if this is not after `start`.
+	End string `json:"end"`
+	// The name of the time zone in the IANA database (e.g. "Asia/Tokyo"). UTC if this is empty.
+	TimeZone string `json:"timezone,omitempty"`
+}
+
+// MergeFreeze is the period in which this bot must not merge pull requests (e.g. a release freeze).
+type MergeFreeze struct {
+	// "2006-01-02T15:04" or a date (`2006-01-02`) in `timezone`.
+	From string `json:"from"`
+	// "2006-01-02T15:04" or a date in `timezone`. The date means the end of the day.
+	Until string `json:"until"`
+	// The name of the time zone in the IANA database. UTC if this is empty.
+	TimeZone string `json:"timezone,omitempty"`
+	// The reason which is told to authors (e.g. "Release 2.0").
+	Reason string `json:"reason,omitempty"`
+}
+
+type mergeWindow struct {
+	days     map[time.Weekday]bool
+	start    int // minutes from the midnight
+	end      int
+	location *time.Location
+}
+
+type mergeFreeze struct {
+	from   time.Time
+	until  time.Time
+	reason string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func compileMergeWindows(list []*MergeWindow) []*mergeWindow {
+	result := make([]*mergeWindow, 0, len(list))
+	for _, w := range list {
+		ok, compiled := w.compile()
+		if !ok {
+			continue
+		}
+		result = append(result, compiled)
+	}
+	return result
+}
+
+func (w *MergeWindow) compile() (bool, *mergeWindow) {
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		log.Printf("warn: `%v` is invalid as the time zone of `merge.windows`: %v\n", w.TimeZone, err)
+		return false, nil
+	}
+
+	ok, start := parseClock(w.Start)
+	if !ok {
+		log.Printf("warn: `%v` is invalid as `start` of `merge.windows`\n", w.Start)
+		return false, nil
+	}
+
+	ok, end := parseClock(w.End)
+	if !ok {
+		log.Printf("warn: `%v` is invalid as `end` of `merge.windows`\n", w.End)
+		return false, nil
+	}
+
+	days := make(map[time.Weekday]bool)
+	for _, d := range w.Days {
+		key := strings.ToLower(d)
+		if len(key) > 3 {
+			key = key[:3]
+		}
+
+		day, ok := weekdays[key]
+		if !ok {
+			log.Printf("warn: `%v` is invalid as the day of `merge.windows`\n", d)
+			return false, nil
+		}
+		days[day] = true
+	}
+
+	return true, &mergeWindow{
+		days:     days,
+		start:    start,
+		end:      end,
+		location: loc,
+	}
+}
+
+func parseClock(v string) (bool, int) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return false, 0
+	}
+
+	return true, t.Hour()*60 + t.Minute()
+}
+
+func (w *mergeWindow) hasDay(day time.Weekday) bool {
+	return len(w.days) == 0 || w.days[day]
+}
+
+func (w *mergeWindow) contains(now time.Time) bool {
+	t := now.In(w.location)
+	minutes := t.Hour()*60 + t.Minute()
+
+	if w.start < w.end {
+		return w.hasDay(t.Weekday()) && w.start <= minutes && minutes < w.end
+	}
+
+	// The window continues to the next day (e.g. 22:00-06:00), or it's the whole day if `start` equals to `end`.
+	if w.hasDay(t.Weekday()) && w.start <= minutes {
+		return true
+	}
+
+	yesterday := t.AddDate(0, 0, -1).Weekday()
+	return w.hasDay(yesterday) && minutes < w.end
+}
+
+func compileMergeFreezes(list []*MergeFreeze) []*mergeFreeze {
+	result := make([]*mergeFreeze, 0, len(list))
+	for _, f := range list {
+		ok, compiled := f.compile()
+		if !ok {
+			continue
+		}
+		result = append(result, compiled)
+	}
+	return result
+}
+
+func (f *MergeFreeze) compile() (bool, *mergeFreeze) {
+	loc, err := time.LoadLocation(f.TimeZone)
+	if err != nil {
+		log.Printf("warn: `%v` is invalid as the time zone of `merge.freezes`: %v\n", f.TimeZone, err)
+		return false, nil
+	}
+
+	ok, from := parseFreezeTime(f.From, loc, false)
+	if !ok {
+		log.Printf("warn: `%v` is invalid as `from` of `merge.freezes`\n", f.From)
+		return false, nil
+	}
+
+	ok, until := parseFreezeTime(f.Until, loc, true)
+	if !ok {
+		log.Printf("warn: `%v` is invalid as `until` of `merge.freezes`\n", f.Until)
+		return false, nil
+	}
+
+	return true, &mergeFreeze{
+		from:   from,
+		until:  until,
+		reason: f.Reason,
+	}
+}
+
+func parseFreezeTime(v string, loc *time.Location, endOfDay bool) (bool, time.Time) {
+	if t, err := time.ParseInLocation("2006-01-02T15:04", v, loc); err == nil {
+		return true, t
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", v, loc)
+	if err != nil {
+		return false, t
+	}
+
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return true, t
+}
+
+// IsTreeClosed returns true with the reason if this bot must not merge any pull request at `now`
+// because of a merge freeze or out of all merge windows.
+func (r *RepositoryInfo) IsTreeClosed(now time.Time) (bool, string) {
+	for _, f := range r.mergeFreezes {
+		if now.Before(f.from) || !now.Before(f.until) {
+			continue
+		}
+
+		reason := "the merge freeze"
+		if f.reason != "" {
+			reason = fmt.Sprintf("the merge freeze (%v)", f.reason)
+		}
+		return true, fmt.Sprintf("%v is in effect until %v", reason, f.until.Format("2006-01-02 15:04 MST"))
+	}
+
+	if len(r.mergeWindows) == 0 {
+		return false, ""
+	}
+
+	for _, w := range r.mergeWindows {
+		if w.contains(now) {
+			return false, ""
+		}
+	}
+	return true, "it is out of the merge windows"
+}
+
+// CanBypassClosedTree returns true if an item of `priority` can be merged even while the tree is closed.
+func (r *RepositoryInfo) CanBypassClosedTree(priority int) bool {
+	return r.freezeBypassPriority > 0 && priority >= r.freezeBypassPriority
+}
@@ -0,0 +1,122 @@
+package setting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepositoryInfoIsTreeClosed(t *testing.T) {
+	o := &OwnersFile{
+		MergeWindows: []*MergeWindow{
+			&MergeWindow{
+				Days:     []string{"Mon", "tue", "Wednesday", "Thu"},
+				Start:    "10:00",
+				End:      "18:00",
+				TimeZone: "Asia/Tokyo",
+			},
+			&MergeWindow{
+				Days:     []string{"Fri"},
+				Start:    "10:00",
+				End:      "15:00",
+				TimeZone: "Asia/Tokyo",
+			},
+			&MergeWindow{
+				Days:  []string{"Sat"},
+				Start: "22:00",
+				End:   "02:00",
+			},
+			// These are invalid and ignored.
+			&MergeWindow{
+				Start:    "00:00",
+				End:      "00:00",
+				TimeZone: "Unknown/Zone",
+			},
+			&MergeWindow{
+				Days:  []string{"Someday"},
+				Start: "00:00",
+				End:   "00:00",
+			},
+		},
+		MergeFreezes: []*MergeFreeze{
+			&MergeFreeze{
+				From:     "2026-12-24",
+				Until:    "2026-12-31",
+				TimeZone: "Asia/Tokyo",
+				Reason:   "Year-end",
+			},
+		},
+		FreezeBypassPriority: 10,
+	}
+
+	ok, info := o.ToRepoInfo()
+	if !ok {
+		t.Errorf("should be ok")
+		return
+	}
+
+	type Testcase struct {
+		now      string
+		expected bool
+	}
+	list := []Testcase{
+		// Monday in Asia/Tokyo
+		Testcase{"2026-10-19T00:59:00Z", true},
+		Testcase{"2026-10-19T01:00:00Z", false},
+		Testcase{"2026-10-19T08:59:00Z", false},
+		Testcase{"2026-10-19T09:00:00Z", true},
+		// Friday in Asia/Tokyo
+		Testcase{"2026-10-16T05:59:00Z", false},
+		Testcase{"2026-10-16T06:00:00Z", true},
+		// From Saturday to Sunday in UTC
+		Testcase{"2026-10-17T21:59:00Z", true},
+		Testcase{"2026-10-17T23:00:00Z", false},
+		Testcase{"2026-10-18T01:59:00Z", false},
+		Testcase{"2026-10-18T02:00:00Z", true},
+		// The freeze
+		Testcase{"2026-12-23T01:00:00Z", false},
+		Testcase{"2026-12-24T01:00:00Z", true},
+		Testcase{"2026-12-31T01:00:00Z", true},
+		Testcase{"2027-01-04T01:00:00Z", false},
+	}
+
+	for _, test := range list {
+		now, err := time.Parse(time.RFC3339, test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual, reason := info.IsTreeClosed(now); actual != test.expected {
+			t.Errorf("%+v should be `%v` but `%v` (%v)", test, test.expected, actual, reason)
+		}
+	}
+
+	now, _ := time.Parse(time.RFC3339, "2026-12-24T01:00:00Z")
+	expected := "the merge freeze (Year-end) is in effect until 2027-01-01 00:00 JST"
+	if _, reason := info.IsTreeClosed(now); reason != expected {
+		t.Errorf("the reason should be `%v` but `%v`", expected, reason)
+		return
+	}
+
+	if info.CanBypassClosedTree(9) || !info.CanBypassClosedTree(10) {
+		t.Errorf("only the priority 10 or more should bypass the closed tree")
+		return
+	}
+}
+
+func TestRepositoryInfoIsTreeClosedWithoutSchedule(t *testing.T) {
+	ok, info := (&OwnersFile{}).ToRepoInfo()
+	if !ok {
+		t.Errorf("should be ok")
+		return
+	}
+
+	if closed, _ := info.IsTreeClosed(time.Now()); closed {
+		t.Errorf("the tree should be always open without any schedule")
+		return
+	}
+
+	if info.CanBypassClosedTree(100) {
+		t.Errorf("the bypass should be disabled by default")
+		return
+	}
+}
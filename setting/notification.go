@@ -0,0 +1,55 @@
+package setting
+
+// NotificationSetting is a destination of notifications (`[[notification]]` in `config.toml`).
+// Filters and the template are per target. Add another target with `repositories`
+// to notify a repository with a different template.
+type NotificationSetting struct {
+	// "slack" (Slack-compatible incoming webhook) or "webhook" (generic JSON webhook).
+	Type string `toml:"type"`
+	URL  string `toml:"url"`
+
+	// Repositories (`owner/name`) to notify. The empty list means all repositories.
+	Repositories []string `toml:"repositories"`
+	// Events to notify (e.g. "merged"). The empty list means all events.
+	Events []string `toml:"events"`
+	// The message template by Go's `text/template`. The default one is used if this is empty.
+	Template string `toml:"template"`
+	// The number of retries for a failed delivery. The default is used if this is not set.
+	// 0 disables retries.
+	Retries *int `toml:"retries"`
+}
+
+const (
+	NotificationTypeSlack   = "slack"
+	NotificationTypeWebhook = "webhook"
+)
+
+const defaultNotificationRetries = 3
+
+func (n *NotificationSetting) RetryCount() int {
+	if n.Retries == nil {
+		return defaultNotificationRetries
+	}
+
+	if *n.Retries < 0 {
+		return 0
+	}
+	return *n.Retries
+}
+
+func (n *NotificationSetting) AcceptRepo(owner, name string) bool {
+	return acceptRepo(acceptedRepoSet(n.Repositories), owner, name)
+}
+
+func (n *NotificationSetting) AcceptEvent(kind string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+
+	for _, e := range n.Events {
+		if e == kind {
+			return true
+		}
+	}
+	return false
+}
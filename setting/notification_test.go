@@ -0,0 +1,80 @@
+package setting
+
+import "testing"
+
+func TestNotificationSettingAccept(t *testing.T) {
+	type Testcase struct {
+		repos  []string
+		events []string
+		owner  string
+		name   string
+		kind   string
+		ok     bool
+	}
+	list := []Testcase{
+		Testcase{
+			owner: "foo",
+			name:  "bar",
+			kind:  "merged",
+			ok:    true,
+		},
+		Testcase{
+			repos:  []string{"foo/bar"},
+			events: []string{"merged"},
+			owner:  "foo",
+			name:   "bar",
+			kind:   "merged",
+			ok:     true,
+		},
+		Testcase{
+			repos: []string{"foo/bar"},
+			owner: "foo",
+			name:  "baz",
+			kind:  "merged",
+			ok:    false,
+		},
+		Testcase{
+			events: []string{"merged"},
+			owner:  "foo",
+			name:   "bar",
+			kind:   "tests_failed",
+			ok:     false,
+		},
+	}
+
+	for i, test := range list {
+		n := NotificationSetting{
+			Repositories: test.repos,
+			Events:       test.events,
+		}
+		if ok := n.AcceptRepo(test.owner, test.name) && n.AcceptEvent(test.kind); ok != test.ok {
+			t.Errorf("#%v should be %v but %v", i, test.ok, ok)
+		}
+	}
+}
+
+func TestNotificationSettingRetryCount(t *testing.T) {
+	retries := func(n int) *int {
+		return &n
+	}
+
+	type Testcase struct {
+		retries  *int
+		expected int
+	}
+	list := []Testcase{
+		Testcase{nil, 3},
+		Testcase{retries(0), 0},
+		Testcase{retries(5), 5},
+		Testcase{retries(-1), 0},
+	}
+
+	for i, test := range list {
+		n := NotificationSetting{
+			Retries: test.retries,
+		}
+		if actual := n.RetryCount(); actual != test.expected {
+			t.Errorf("#%v should be %v but %v", i, test.expected, actual)
+		}
+	}
+}
@@ -2,17 +2,44 @@ package setting
 
 import (
 	"log"
+	"time"
 )
 
 const autoBranchName string = "auto"
 
+const (
+	AssignStrategyRandom      string = "random"
+	AssignStrategyRoundRobin  string = "round_robin"
+	AssignStrategyLeastLoaded string = "least_loaded"
+)
+
+// How much this bot replies to a command.
+const (
+	// Do not react to commands at all.
+	FeedbackNone string = "none"
+	// Add a reaction to the comment only.
+	FeedbackReaction string = "reaction"
+	// Add a reaction and reply the short reason of the rejection.
+	FeedbackReply string = "reply"
+	// Add a reaction and reply the reason of the rejection with its detail.
+	FeedbackVerbose string = "verbose"
+)
+
+const defaultWelcomeMessage string = ":wave: Thank you for the pull request, @{author}! @{reviewer} has been assigned as the reviewer. " +
+	"You can change the reviewer by `r? @<reviewer>`."
+
 type OwnersFile struct {
-	Version      float64       `json:"version"`
+	Version float64 `json:"version"`
+
+	// Users in this list have the reviewer privilege.
+	// You can also specify `@<org>/<team>` to give it all members of the team.
+	// The members are resolved by GitHub Teams API and cached in this bot.
 	RawReviewers []interface{} `json:"reviewers"`
 
 	// Users in this list can merge only a pull request opened by themselves.
 	// They only can command `@<botname> r=<reviewer_name>` and `<reviewer_name>`
 	// must be different from their names.
+	// `@<org>/<team>` is also accepted as same as `reviewers`.
 	RawMergeableUsers []interface{} `json:"mergeable_users,omitempty"`
 
 	// Provide a reviewer privilege for all users whoc can write some comment to
@@ -36,9 +63,89 @@ type OwnersFile struct {
 	// managed by this bot.
 	DeleteAfterAutoMerge bool `json:"auto_merge.delete_branch,omitempty"`
 
+	// Give up the active item of "Auto-Merging" if its CI does not report any result
+	// within this duration (e.g. "1h30m"). This is disabled by default.
+	RawAutoMergeTimeout string `json:"auto_merge.timeout,omitempty"`
+
+	// Rebuild the auto branch and retry CI up to this number of times
+	// if CI fails (e.g. by known flaky tests). This is disabled by default.
+	AutoMergeRetries int `json:"auto_merge.retries,omitempty"`
+
+	// Retry only if some of failed commit statuses' contexts or check runs' names matches
+	// with a pattern (for `path.Match()`) in this list. If this is empty, we retry on any failures.
+	AutoMergeRetryContexts []string `json:"auto_merge.retry_contexts,omitempty"`
+
 	// The name of the branch which is used for "Auto-Merging" to test changesets
 	// before merging it into upstream. The default value is defined as `autoBranchName`.
-	AutoBranchName string `json:"auto_branch.branch_name.auto",omitempty`
+	AutoBranchName string `json:"auto_branch.branch_name.auto,omitempty"`
+
+	// The number of distinct reviewers who must approve a pull request
+	// before this bot queues it. The default value is 1.
+	// Only the reviewer who calls `r+` is counted (`r=<names>` does not count the named reviewers),
+	// so this must not be greater than the number of `reviewers`.
+	RequiredApprovals int `json:"review.required_approvals,omitempty"`
+
+	// Regard a GitHub review by a reviewer as a command for this bot.
+	// An "approved" review for the current head works as `r+`,
+	// and a "changes requested" review works as `r-`.
+	AcceptGitHubReview bool `json:"review.accept_github_review,omitempty"`
+
+	// Assign a reviewer picked from `reviewers` automatically
+	// when a pull request is opened (or is marked as ready for review).
+	EnableAutoAssign bool `json:"auto_assign.enabled,omitempty"`
+
+	// The strategy to pick a reviewer: "random" (default), "round_robin" or "least_loaded".
+	AutoAssignStrategy string `json:"auto_assign.strategy,omitempty"`
+
+	// The comment which this bot posts on assigning a reviewer automatically.
+	// `{author}` and `{reviewer}` are replaced with their login names.
+	WelcomeMessage string `json:"auto_assign.welcome_message,omitempty"`
+
+	// The availability (e.g. vacations) per reviewer.
+	// Assigning a reviewer skips who is not available.
+	// This can be overridden by REST API (`/api/v0/availability/<owner>/<repo>/<user>`).
+	Availability map[string]*Availability `json:"availability,omitempty"`
+
+	// Override `review.required_approvals` for a pull request which changes files matched by the rule.
+	// If some rules are matched, we use the largest number of them.
+	ApprovalRules []ApprovalRule `json:"review.approval_rules,omitempty"`
+
+	// How much this bot replies to a command: "none", "reaction", "reply" (default) or "verbose".
+	// This bot adds a reaction to the comment and explains why the command is rejected.
+	CommandFeedback string `json:"command.feedback,omitempty"`
+
+	// Override the names, the prefix, colors and descriptions of status labels.
+	// They can be created or updated in the repository by `@<botname> labels`.
+	Labels *LabelsSetting `json:"labels,omitempty"`
+
+	// This bot does not approve nor merge a pull request which has one of these labels (e.g. "do-not-merge").
+	BlockingLabels []string `json:"merge.blocking_labels,omitempty"`
+
+	// This bot does not approve nor merge a pull request whose title matches
+	// one of these regular expressions (e.g. "^WIP:").
+	BlockingTitlePatterns []string `json:"merge.blocking_title_patterns,omitempty"`
+
+	// Allow to approve and merge a draft pull request. This is disabled by default.
+	AllowDraft bool `json:"merge.allow_draft,omitempty"`
+
+	// This bot merges pull requests only in one of these windows (e.g. weekdays from 10:00 to 17:00).
+	// Approved pull requests wait in the queue until a window opens. We can merge at any time if this is empty.
+	MergeWindows []*MergeWindow `json:"merge.windows,omitempty"`
+
+	// This bot does not merge pull requests in these periods (e.g. release freezes) even in a merge window.
+	MergeFreezes []*MergeFreeze `json:"merge.freezes,omitempty"`
+
+	// A pull request approved with `p=<n>` where `<n>` is this value or more is merged
+	// even out of the merge windows or in a freeze (e.g. a hotfix). This is disabled by default.
+	FreezeBypassPriority int `json:"merge.bypass_priority,omitempty"`
+}
+
+type ApprovalRule struct {
+	// The pattern of the path from the repository root.
+	// This is a prefix if it ends with `/`, otherwise it's a pattern for `path.Match()`.
+	Path string `json:"path"`
+	// The number of distinct reviewers' approvals.
+	RequiredApprovals int `json:"required_approvals"`
 }
 
 func (o *OwnersFile) reviewers() (ok bool, set *ReviewerSet) {
@@ -79,6 +186,29 @@ func (o *OwnersFile) mergeables() (ok bool, set *ReviewerSet) {
 	return true, set
 }
 
+// satisfiableApprovals returns false if the required approvals are more than `reviewers`.
+// We cannot count members of teams until they are resolved, so we don't check them.
+func (o *OwnersFile) satisfiableApprovals(reviewers *ReviewerSet) bool {
+	if o.RegardAllAsReviewer || len(reviewers.Teams()) > 0 {
+		return true
+	}
+
+	n := len(reviewers.Entries())
+	if o.RequiredApprovals > n {
+		log.Printf("warn: `review.required_approvals` (%v) is greater than the number of reviewers (%v)\n", o.RequiredApprovals, n)
+		return false
+	}
+
+	for _, rule := range o.ApprovalRules {
+		if rule.RequiredApprovals > n {
+			log.Printf("warn: `required_approvals` (%v) for `%v` is greater than the number of reviewers (%v)\n", rule.RequiredApprovals, rule.Path, n)
+			return false
+		}
+	}
+
+	return true
+}
+
 func (o *OwnersFile) ToRepoInfo() (bool, *RepositoryInfo) {
 	ok, r := o.reviewers()
 	if !ok {
@@ -90,18 +220,75 @@ func (o *OwnersFile) ToRepoInfo() (bool, *RepositoryInfo) {
 		return false, nil
 	}
 
+	if !o.satisfiableApprovals(r) {
+		return false, nil
+	}
+
 	autoName := o.AutoBranchName
 	if autoName == "" {
 		autoName = autoBranchName
 	}
 
+	strategy := o.AutoAssignStrategy
+	switch strategy {
+	case AssignStrategyRandom, AssignStrategyRoundRobin, AssignStrategyLeastLoaded:
+	case "":
+		strategy = AssignStrategyRandom
+	default:
+		log.Printf("warn: `%v` is unknown strategy. We use `%v` instead of it.\n", strategy, AssignStrategyRandom)
+		strategy = AssignStrategyRandom
+	}
+
+	var timeout time.Duration
+	if o.RawAutoMergeTimeout != "" {
+		d, err := time.ParseDuration(o.RawAutoMergeTimeout)
+		if err != nil {
+			log.Printf("warn: `%v` is invalid as `auto_merge.timeout`: %v\n", o.RawAutoMergeTimeout, err)
+		} else {
+			timeout = d
+		}
+	}
+
+	feedback := o.CommandFeedback
+	switch feedback {
+	case FeedbackNone, FeedbackReaction, FeedbackReply, FeedbackVerbose:
+	case "":
+		feedback = FeedbackReply
+	default:
+		log.Printf("warn: `%v` is unknown feedback. We use `%v` instead of it.\n", feedback, FeedbackReply)
+		feedback = FeedbackReply
+	}
+
+	welcome := o.WelcomeMessage
+	if welcome == "" {
+		welcome = defaultWelcomeMessage
+	}
+
 	info := RepositoryInfo{
 		reviewers:            r,
 		mergeables:           mergeables,
 		regardAllAsReviewer:  o.RegardAllAsReviewer,
 		EnableAutoMerge:      o.EnableAutoMerge,
 		DeleteAfterAutoMerge: o.DeleteAfterAutoMerge,
+		AutoMergeTimeout:     timeout,
+		AutoMergeRetries:     o.AutoMergeRetries,
+		retryContexts:        o.AutoMergeRetryContexts,
 		AutoBranchName:       autoName,
+		AcceptGitHubReview:   o.AcceptGitHubReview,
+		EnableAutoAssign:     o.EnableAutoAssign,
+		AutoAssignStrategy:   strategy,
+		WelcomeMessage:       welcome,
+		CommandFeedback:      feedback,
+		Labels:               o.Labels.toStatusLabels(),
+		requiredApprovals:    o.RequiredApprovals,
+		approvalRules:        o.ApprovalRules,
+		availability:         o.Availability,
+		AllowDraft:           o.AllowDraft,
+		blockingLabels:       o.BlockingLabels,
+		blockingTitles:       compileTitlePatterns(o.BlockingTitlePatterns),
+		mergeWindows:         compileMergeWindows(o.MergeWindows),
+		mergeFreezes:         compileMergeFreezes(o.MergeFreezes),
+		freezeBypassPriority: o.FreezeBypassPriority,
 	}
 	return true, &info
 }
@@ -49,3 +49,357 @@ func TestOwnersFileToRepoInfo2(t *testing.T) {
 		return
 	}
 }
+
+func TestOwnersFileToRepoInfoWithTeam(t *testing.T) {
+	o := OwnersFile{
+		RawReviewers:      []interface{}{"alice", "@org/reviewers"},
+		RawMergeableUsers: []interface{}{"org/mergeables"},
+	}
+
+	ok, info := o.ToRepoInfo()
+	if !ok {
+		t.Errorf("should be success to convert from OwnersFile")
+		return
+	}
+
+	if actual := info.reviewers.Teams(); len(actual) != 1 || actual[0] != "org/reviewers" {
+		t.Errorf("reviewers.Teams() should be [org/reviewers] but %v", actual)
+		return
+	}
+
+	if info.IsReviewer("bob") {
+		t.Errorf("bob should not be a reviewer before resolving teams")
+		return
+	}
+
+	info.ResolveTeams(func(org, slug string) (bool, []string) {
+		switch org + "/" + slug {
+		case "org/reviewers":
+			return true, []string{"bob"}
+		case "org/mergeables":
+			return true, []string{"carol"}
+		}
+		return false, nil
+	})
+
+	if !info.IsReviewer("alice") || !info.IsReviewer("bob") {
+		t.Errorf("alice and bob should be reviewers")
+		return
+	}
+
+	if !info.IsInMergeableUserList("carol") {
+		t.Errorf("carol should be in the mergeable user list")
+		return
+	}
+}
+
+func TestOwnersFileToRepoInfoWithApprovalRules(t *testing.T) {
+	o := OwnersFile{
+		RawReviewers:      []interface{}{"alice", "bob", "carol", "dave"},
+		RequiredApprovals: 2,
+		ApprovalRules: []ApprovalRule{
+			ApprovalRule{
+				Path:              "db/",
+				RequiredApprovals: 3,
+			},
+			ApprovalRule{
+				Path:              "*.lock",
+				RequiredApprovals: 4,
+			},
+		},
+	}
+
+	ok, info := o.ToRepoInfo()
+	if !ok {
+		t.Errorf("should be success to convert from OwnersFile")
+		return
+	}
+
+	if actual := info.RequiredApprovals([]string{"README.md"}); actual != 2 {
+		t.Errorf("should require 2 approvals but %v", actual)
+		return
+	}
+
+	if actual := info.RequiredApprovals([]string{"README.md", "db/schema.sql"}); actual != 3 {
+		t.Errorf("should require 3 approvals but %v", actual)
+		return
+	}
+
+	if actual := info.RequiredApprovals([]string{"db/schema.sql", "go.lock"}); actual != 4 {
+		t.Errorf("should require 4 approvals but %v", actual)
+		return
+	}
+
+	if actual := (&RepositoryInfo{}).RequiredApprovals(nil); actual != 1 {
+		t.Errorf("the default should be 1 but %v", actual)
+		return
+	}
+}
+
+func TestOwnersFileToRepoInfoWithUnsatisfiableApprovals(t *testing.T) {
+	type Testcase struct {
+		input    OwnersFile
+		expected bool
+	}
+
+	list := []Testcase{
+		Testcase{
+			OwnersFile{
+				RawReviewers:      []interface{}{"alice", "bob"},
+				RequiredApprovals: 2,
+			},
+			true,
+		},
+		Testcase{
+			OwnersFile{
+				RawReviewers:      []interface{}{"alice", "bob"},
+				RequiredApprovals: 3,
+			},
+			false,
+		},
+		Testcase{
+			OwnersFile{
+				RawReviewers: []interface{}{"alice", "bob"},
+				ApprovalRules: []ApprovalRule{
+					ApprovalRule{
+						Path:              "db/",
+						RequiredApprovals: 3,
+					},
+				},
+			},
+			false,
+		},
+		// We cannot count members of the team until it is resolved.
+		Testcase{
+			OwnersFile{
+				RawReviewers:      []interface{}{"alice", "@org/reviewers"},
+				RequiredApprovals: 3,
+			},
+			true,
+		},
+		Testcase{
+			OwnersFile{
+				RegardAllAsReviewer: true,
+				RequiredApprovals:   3,
+			},
+			true,
+		},
+	}
+
+	for i, c := range list {
+		if ok, _ := c.input.ToRepoInfo(); ok != c.expected {
+			t.Errorf("%v: ToRepoInfo() should return %v but %v", i, c.expected, ok)
+			return
+		}
+	}
+}
+
+func TestOwnersFileToRepoInfoWithRetryContexts(t *testing.T) {
+	o := OwnersFile{
+		AutoMergeRetries:       2,
+		AutoMergeRetryContexts: []string{"ci/integration-*", "e2e"},
+	}
+
+	ok, info := o.ToRepoInfo()
+	if !ok {
+		t.Errorf("should be success to convert from OwnersFile")
+		return
+	}
+
+	if info.AutoMergeRetries != 2 {
+		t.Errorf("should retry 2 times but %v", info.AutoMergeRetries)
+		return
+	}
+
+	type Testcase struct {
+		input    string
+		expected bool
+	}
+	list := []Testcase{
+		Testcase{"ci/integration-mysql", true},
+		Testcase{"e2e", true},
+		Testcase{"ci/unit", false},
+		Testcase{"e2e-slow", false},
+	}
+	for _, tc := range list {
+		if actual := info.IsRetriableContext(tc.input); actual != tc.expected {
+			t.Errorf("IsRetriableContext(%v) should be %v but %v", tc.input, tc.expected, actual)
+			return
+		}
+	}
+
+	if !(&RepositoryInfo{}).IsRetriableContext("ci/unit") {
+		t.Errorf("should retry on any failures if there is no retry contexts")
+		return
+	}
+}
+
+func TestOwnersFileToRepoInfoWithCommandFeedback(t *testing.T) {
+	type Testcase struct {
+		input    string
+		expected string
+		reacts   bool
+		replies  bool
+	}
+
+	list := []Testcase{
+		Testcase{"", FeedbackReply, true, true},
+		Testcase{"none", FeedbackNone, false, false},
+		Testcase{"reaction", FeedbackReaction, true, false},
+		Testcase{"verbose", FeedbackVerbose, true, true},
+		Testcase{"unknown", FeedbackReply, true, true},
+	}
+	for _, item := range list {
+		o := OwnersFile{
+			CommandFeedback: item.input,
+		}
+
+		ok, info := o.ToRepoInfo()
+		if !ok {
+			t.Errorf("should be success to convert from OwnersFile")
+			return
+		}
+
+		if info.CommandFeedback != item.expected {
+			t.Errorf("`%v` should be `%v` but `%v`", item.input, item.expected, info.CommandFeedback)
+			return
+		}
+
+		if info.ReactsToCommand() != item.reacts || info.RepliesToCommand() != item.replies {
+			t.Errorf("`%v`: reacts should be %v and replies should be %v", item.input, item.reacts, item.replies)
+			return
+		}
+	}
+}
+
+func TestOwnersFileToRepoInfoWithLabels(t *testing.T) {
+	prefix := func(s string) *string {
+		return &s
+	}
+
+	type Testcase struct {
+		input    *LabelsSetting
+		prefix   string
+		review   Label
+		merge    Label
+		isStatus string
+	}
+
+	list := []Testcase{
+		Testcase{
+			input:    nil,
+			prefix:   "S-",
+			review:   Label{"S-awaiting-review", "fbca04", "Waiting for the review"},
+			merge:    Label{"S-awaiting-merge", "0e8a16", "Approved and queued to be merged"},
+			isStatus: "S-needs-rebase",
+		},
+		Testcase{
+			input: &LabelsSetting{
+				Prefix:        prefix("status/"),
+				AwaitingMerge: &LabelSetting{Name: "status/queued", Color: "#00FF00", Description: "Queued"},
+			},
+			prefix:   "status/",
+			review:   Label{"status/awaiting-review", "fbca04", "Waiting for the review"},
+			merge:    Label{"status/queued", "00ff00", "Queued"},
+			isStatus: "status/anything",
+		},
+		Testcase{
+			// The name does not need to start with the prefix. The invalid color is ignored.
+			input: &LabelsSetting{
+				AwaitingReview: &LabelSetting{Name: "review", Color: "red"},
+			},
+			prefix:   "S-",
+			review:   Label{"review", "fbca04", "Waiting for the review"},
+			merge:    Label{"S-awaiting-merge", "0e8a16", "Approved and queued to be merged"},
+			isStatus: "review",
+		},
+		Testcase{
+			// The empty prefix means no prefix.
+			input: &LabelsSetting{
+				Prefix: prefix(""),
+			},
+			prefix:   "",
+			review:   Label{"awaiting-review", "fbca04", "Waiting for the review"},
+			merge:    Label{"awaiting-merge", "0e8a16", "Approved and queued to be merged"},
+			isStatus: "needs-rebase",
+		},
+	}
+	for _, item := range list {
+		o := OwnersFile{
+			Labels: item.input,
+		}
+
+		ok, info := o.ToRepoInfo()
+		if !ok {
+			t.Errorf("should be success to convert from OwnersFile")
+			return
+		}
+
+		labels := info.Labels
+		if labels.Prefix != item.prefix {
+			t.Errorf("the prefix should be `%v` but `%v`", item.prefix, labels.Prefix)
+			return
+		}
+
+		if labels.AwaitingReview != item.review {
+			t.Errorf("awaiting review should be %+v but %+v", item.review, labels.AwaitingReview)
+			return
+		}
+
+		if labels.AwaitingMerge != item.merge {
+			t.Errorf("awaiting merge should be %+v but %+v", item.merge, labels.AwaitingMerge)
+			return
+		}
+
+		if !labels.IsStatusLabel(item.isStatus) || labels.IsStatusLabel("bug") {
+			t.Errorf("`%v` should be a status label", item.isStatus)
+			return
+		}
+
+		if len(labels.All()) != 5 {
+			t.Errorf("there should be 5 status labels: %+v", labels.All())
+			return
+		}
+	}
+}
+
+func TestOwnersFileToRepoInfoWithBlocking(t *testing.T) {
+	o := OwnersFile{
+		BlockingLabels:        []string{"do-not-merge"},
+		BlockingTitlePatterns: []string{`^(WIP|\[WIP\])`, "("},
+	}
+
+	ok, info := o.ToRepoInfo()
+	if !ok {
+		t.Errorf("should be success to convert from OwnersFile")
+		return
+	}
+
+	type Testcase struct {
+		draft   bool
+		title   string
+		labels  []string
+		blocked bool
+	}
+	list := []Testcase{
+		Testcase{false, "Fix a bug", []string{"bug"}, false},
+		Testcase{true, "Fix a bug", nil, true},
+		Testcase{false, "Fix a bug", []string{"bug", "Do-Not-Merge"}, true},
+		Testcase{false, "WIP: Fix a bug", nil, true},
+		Testcase{false, "[WIP] Fix a bug", nil, true},
+		Testcase{false, "Fix WIP: a bug", nil, false},
+	}
+	for i, tc := range list {
+		if reason := info.BlockingReason(tc.draft, tc.title, tc.labels); (reason != "") != tc.blocked {
+			t.Errorf("#%v should be blocked (%v) but `%v`", i, tc.blocked, reason)
+			return
+		}
+	}
+
+	o.AllowDraft = true
+	_, info = o.ToRepoInfo()
+	if reason := info.BlockingReason(true, "Fix a bug", nil); reason != "" {
+		t.Errorf("a draft should be allowed but `%v`", reason)
+		return
+	}
+}
@@ -1,5 +1,14 @@
 package setting
 
+import (
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
 type RepositoryInfo struct {
 	reviewers           *ReviewerSet
 	regardAllAsReviewer bool
@@ -7,7 +16,27 @@ type RepositoryInfo struct {
 
 	EnableAutoMerge      bool
 	DeleteAfterAutoMerge bool
+	AutoMergeTimeout     time.Duration
+	AutoMergeRetries     int
 	AutoBranchName       string
+	AcceptGitHubReview   bool
+	EnableAutoAssign     bool
+	AutoAssignStrategy   string
+	WelcomeMessage       string
+	CommandFeedback      string
+	Labels               *StatusLabels
+	AllowDraft           bool
+
+	requiredApprovals int
+	approvalRules     []ApprovalRule
+	availability      map[string]*Availability
+	retryContexts     []string
+	blockingLabels    []string
+	blockingTitles    []*regexp.Regexp
+
+	mergeWindows         []*mergeWindow
+	mergeFreezes         []*mergeFreeze
+	freezeBypassPriority int
 }
 
 func (r *RepositoryInfo) IsReviewer(name string) bool {
@@ -18,12 +47,119 @@ func (r *RepositoryInfo) IsReviewer(name string) bool {
 	return r.reviewers.Has(name)
 }
 
+// ReactsToCommand returns true if this bot adds a reaction to a command.
+func (r *RepositoryInfo) ReactsToCommand() bool {
+	return r.CommandFeedback != FeedbackNone
+}
+
+// RepliesToCommand returns true if this bot explains why a command is rejected.
+func (r *RepositoryInfo) RepliesToCommand() bool {
+	return r.CommandFeedback == FeedbackReply || r.CommandFeedback == FeedbackVerbose
+}
+
+// HasRetryContexts returns true if we retry only on failures of some specific contexts.
+func (r *RepositoryInfo) HasRetryContexts() bool {
+	return len(r.retryContexts) > 0
+}
+
+// IsRetriableContext returns true if we can retry on the failure of `context`.
+func (r *RepositoryInfo) IsRetriableContext(context string) bool {
+	if !r.HasRetryContexts() {
+		return true
+	}
+
+	for _, pattern := range r.retryContexts {
+		ok, err := path.Match(pattern, context)
+		if err != nil {
+			log.Printf("warn: `%v` is invalid pattern: %v\n", pattern, err)
+			continue
+		}
+
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reviewers returns the sorted list of reviewers including the resolved team members.
+func (r *RepositoryInfo) Reviewers() []string {
+	list := r.reviewers.Entries()
+	sort.Strings(list)
+	return list
+}
+
 func (r *RepositoryInfo) IsInMergeableUserList(name string) bool {
 	return r.mergeables.Has(name)
 }
 
+// HasApprovalRules returns true if the required approvals depend on the changed files.
+func (r *RepositoryInfo) HasApprovalRules() bool {
+	return len(r.approvalRules) > 0
+}
+
+// RequiredApprovals returns the number of distinct approvals required
+// for a pull request which changes `files`.
+func (r *RepositoryInfo) RequiredApprovals(files []string) int {
+	required := r.requiredApprovals
+	if required < 1 {
+		required = 1
+	}
+
+	for _, rule := range r.approvalRules {
+		if rule.RequiredApprovals <= required {
+			continue
+		}
+
+		for _, f := range files {
+			if rule.match(f) {
+				required = rule.RequiredApprovals
+				break
+			}
+		}
+	}
+
+	return required
+}
+
+func (r *ApprovalRule) match(file string) bool {
+	if strings.HasSuffix(r.Path, "/") {
+		return strings.HasPrefix(file, r.Path)
+	}
+
+	ok, err := path.Match(r.Path, file)
+	if err != nil {
+		log.Printf("warn: `%v` is invalid pattern: %v\n", r.Path, err)
+		return false
+	}
+	return ok
+}
+
+// ResolveTeams expands `@org/team` entries of reviewers and mergeable users
+// into their members by `resolve`.
+func (r *RepositoryInfo) ResolveTeams(resolve TeamResolver) {
+	r.reviewers.resolveTeams(resolve)
+	r.mergeables.resolveTeams(resolve)
+}
+
+// TeamResolver returns the login names of the members of `org/slug` team.
+type TeamResolver func(org, slug string) (ok bool, members []string)
+
+// SplitTeamName splits `org/team` (or `@org/team`) into the organization and the team slug.
+// `ok` is false if `name` does not refer to a team.
+func SplitTeamName(name string) (ok bool, org string, slug string) {
+	name = strings.TrimPrefix(name, "@")
+	tmp := strings.Split(name, "/")
+	if len(tmp) != 2 || tmp[0] == "" || tmp[1] == "" {
+		return false, "", ""
+	}
+
+	return true, tmp[0], tmp[1]
+}
+
 type ReviewerSet struct {
-	set map[string]*interface{}
+	set   map[string]*interface{}
+	teams []string
 }
 
 func (s *ReviewerSet) Has(person string) bool {
@@ -39,13 +175,40 @@ func (s *ReviewerSet) Entries() []string {
 	return list
 }
 
+// Teams returns `org/team` entries which have been included in this set.
+func (s *ReviewerSet) Teams() []string {
+	return s.teams
+}
+
+func (s *ReviewerSet) resolveTeams(resolve TeamResolver) {
+	for _, team := range s.teams {
+		_, org, slug := SplitTeamName(team)
+		ok, members := resolve(org, slug)
+		if !ok {
+			log.Printf("warn: could not resolve the members of `%v`\n", team)
+			continue
+		}
+
+		for _, name := range members {
+			s.set[name] = nil
+		}
+	}
+}
+
 func newReviewerSet(list []string) *ReviewerSet {
 	s := make(map[string]*interface{})
+	teams := make([]string, 0)
 	for _, name := range list {
-		s[name] = nil
+		if ok, org, slug := SplitTeamName(name); ok {
+			teams = append(teams, org+"/"+slug)
+			continue
+		}
+
+		s[strings.TrimPrefix(name, "@")] = nil
 	}
 
 	return &ReviewerSet{
-		s,
+		set:   s,
+		teams: teams,
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"log"
 
@@ -15,6 +16,42 @@ type Settings struct {
 	Version int           `toml:"config_version"`
 	Port    int           `toml:"port"`
 	Github  GithubSetting `toml:"github"`
+	Gitea   GiteaSetting  `toml:"gitea"`
+	API     APISetting    `toml:"api"`
+
+	// The interval to reconcile queues with GitHub (e.g. "10m").
+	// "0" disables it except on starting up.
+	RawReconcileInterval string `toml:"reconcile_interval"`
+
+	// The number of workers to process webhook deliveries.
+	// Deliveries for the same repository are processed one by one regardless of this.
+	WebHookWorkers int `toml:"webhook_workers"`
+
+	// The number of pull requests which we check whether they are unmergeable at once after a push.
+	// The rest are checked one by one every `unmergeable_check_interval` in background.
+	UnmergeableCheckBurst int `toml:"unmergeable_check_burst"`
+	// e.g. "2s"
+	RawUnmergeableCheckInterval string `toml:"unmergeable_check_interval"`
+
+	// Keep one status comment per pull request and edit it in place,
+	// instead of posting a new comment for each transition.
+	StickyStatusComment bool `toml:"sticky_status_comment"`
+
+	Notifications []NotificationSetting `toml:"notification"`
+}
+
+const defaultReconcileInterval = 10 * time.Minute
+
+const defaultWebHookWorkers = 4
+
+const defaultUnmergeableCheckBurst = 30
+
+const defaultUnmergeableCheckInterval = 2 * time.Second
+
+type APISetting struct {
+	// The token to call REST APIs which change the state of this bot.
+	// Those APIs are disabled if this is empty.
+	Token string `toml:"token"`
 }
 
 func (s *Settings) PortStr() string {
@@ -33,10 +70,74 @@ func (s *Settings) WebHookSecret() []byte {
 	return []byte(s.Github.HookSecret)
 }
 
+func (s *Settings) EnableGitea() bool {
+	return s.Gitea.BaseURL != ""
+}
+
+func (s *Settings) BotNameForGitea() string {
+	return s.Gitea.BotName
+}
+
+func (s *Settings) GiteaToken() string {
+	return s.Gitea.Token
+}
+
+func (s *Settings) GiteaWebHookSecret() []byte {
+	return []byte(s.Gitea.HookSecret)
+}
+
+func (s *Settings) APIToken() string {
+	return s.API.Token
+}
+
+func (s *Settings) ReconcileInterval() time.Duration {
+	return parseInterval(s.RawReconcileInterval, defaultReconcileInterval)
+}
+
+func (s *Settings) WebHookWorkerCount() int {
+	if s.WebHookWorkers <= 0 {
+		return defaultWebHookWorkers
+	}
+	return s.WebHookWorkers
+}
+
+func (s *Settings) UnmergeableCheckBurstCount() int {
+	if s.UnmergeableCheckBurst <= 0 {
+		return defaultUnmergeableCheckBurst
+	}
+	return s.UnmergeableCheckBurst
+}
+
+func (s *Settings) UnmergeableCheckInterval() time.Duration {
+	d := parseInterval(s.RawUnmergeableCheckInterval, defaultUnmergeableCheckInterval)
+	if d <= 0 {
+		return defaultUnmergeableCheckInterval
+	}
+	return d
+}
+
+func parseInterval(v string, fallback time.Duration) time.Duration {
+	if v == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("warn: `%v` is invalid as the interval. We use `%v` instead of it.\n", v, fallback)
+		return fallback
+	}
+
+	return d
+}
+
 func (s *Settings) AcceptRepo(owner, name string) bool {
 	return s.Github.accept(owner, name)
 }
 
+func (s *Settings) AcceptGiteaRepo(owner, name string) bool {
+	return s.Gitea.accept(owner, name)
+}
+
 const RootConfigFile = "/config.toml"
 
 func LoadSettings(dir string) *Settings {
@@ -52,6 +153,7 @@ func LoadSettings(dir string) *Settings {
 	}
 
 	initGithubSetting(&s.Github)
+	initGiteaSetting(&s.Gitea)
 	return s
 }
 
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 const kConfigFile = "example.config.toml"
@@ -56,4 +57,19 @@ func TestLoadConfigToml(t *testing.T) {
 	if actual := result.Github.acceptedRepos; actual != nil {
 		t.Fatalf("%v\n", actual)
 	}
+
+	if actual := result.UnmergeableCheckBurstCount(); actual != 30 {
+		t.Errorf("%v\n", actual)
+		return
+	}
+
+	if actual := result.UnmergeableCheckInterval(); actual != 2*time.Second {
+		t.Errorf("%v\n", actual)
+		return
+	}
+
+	if result.StickyStatusComment {
+		t.Errorf("the sticky status comment should be disabled by default\n")
+		return
+	}
 }
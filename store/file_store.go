@@ -0,0 +1,192 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore saves a JSON document per repository into `<root>/<dir>/<owner>/<name>.json`.
+// This is used to persist the small state of this bot other than the merge queue.
+type FileStore struct {
+	rootPath string
+
+	mux     sync.Mutex
+	handles map[string]*Handle
+}
+
+func NewFileStore(root string, dir string) *FileStore {
+	if root == "" || dir == "" {
+		log.Println("error: `root` and `dir` must not be empty string")
+		return nil
+	}
+
+	p, err := filepath.Abs(filepath.Join(root, dir))
+	if err != nil {
+		log.Printf("error: cannot get the path to the storage: %v\n", err)
+		return nil
+	}
+
+	if !exists(p) {
+		if err := os.MkdirAll(p, os.ModePerm); err != nil {
+			log.Printf("error: cannot create the storage dir: %v\n", err)
+			return nil
+		}
+	}
+
+	return &FileStore{
+		rootPath: p,
+		mux:      sync.Mutex{},
+		handles:  make(map[string]*Handle),
+	}
+}
+
+// Get returns the handle to the document for `owner/name`.
+// This returns nil if `owner` or `name` is not safe as a path fragment.
+func (s *FileStore) Get(owner string, name string) *Handle {
+	if !validPathFragment(owner) || !validPathFragment(name) {
+		log.Printf("error: `%v/%v` is invalid as the path\n", owner, name)
+		return nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	k := owner + "/" + name
+	h, ok := s.handles[k]
+	if !ok {
+		h = &Handle{
+			file: filepath.Join(s.rootPath, owner, name+".json"),
+		}
+		s.handles[k] = h
+	}
+
+	return h
+}
+
+// List returns the names of documents for `owner`.
+func (s *FileStore) List(owner string) []string {
+	if !validPathFragment(owner) {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(s.rootPath, owner))
+	if err != nil {
+		return nil
+	}
+
+	list := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		list = append(list, f.Name()[:len(f.Name())-len(".json")])
+	}
+
+	return list
+}
+
+type Handle struct {
+	mux  sync.Mutex
+	file string
+}
+
+func (h *Handle) Lock() {
+	h.mux.Lock()
+}
+
+func (h *Handle) Unlock() {
+	h.mux.Unlock()
+}
+
+// Load decodes the document into `v`.
+// This returns false if there is no document or it's broken.
+func (h *Handle) Load(v interface{}) bool {
+	b := h.LoadAsRawByte()
+	if b == nil {
+		return false
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		log.Printf("error: cannot decode %v: %v\n", h.file, err)
+		return false
+	}
+
+	return true
+}
+
+func (h *Handle) LoadAsRawByte() []byte {
+	if !exists(h.file) {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(h.file)
+	if err != nil {
+		log.Printf("error: cannot read %v: %v\n", h.file, err)
+		return nil
+	}
+
+	return b
+}
+
+func (h *Handle) Save(v interface{}) bool {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("error: cannot encode the data for %v: %v\n", h.file, err)
+		return false
+	}
+
+	dir := path.Dir(h.file)
+	if !exists(dir) {
+		if err := os.MkdirAll(dir, 0775); err != nil {
+			log.Printf("error: cannot create %v: %v\n", dir, err)
+			return false
+		}
+	}
+
+	// Write into the temporary file at first to avoid to break the document on failure.
+	tmp := h.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		log.Printf("error: cannot write the data to %v: %v\n", tmp, err)
+		return false
+	}
+
+	if err := os.Rename(tmp, h.file); err != nil {
+		log.Printf("error: cannot rename %v to %v: %v\n", tmp, h.file, err)
+		return false
+	}
+
+	return true
+}
+
+func (h *Handle) Remove() bool {
+	if !exists(h.file) {
+		return true
+	}
+
+	if err := os.Remove(h.file); err != nil {
+		log.Printf("error: cannot remove %v: %v\n", h.file, err)
+		return false
+	}
+
+	return true
+}
+
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+// Check `p` is secure string as a path fragment.
+// If `p` is `..`, it can access to security path (e.g. `~/.ssh/`).
+func validPathFragment(p string) bool {
+	if p == "" || p == "." || p == ".." {
+		return false
+	}
+
+	return path.Base(p) == p && filepath.Base(p) == p
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileStoreSaveAndLoad(t *testing.T) {
+	root, err := ioutil.TempDir("", "popuko-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	s := NewFileStore(root, "test")
+	if s == nil {
+		t.Errorf("should be success to create the store")
+		return
+	}
+
+	type data struct {
+		Value string `json:"value"`
+	}
+
+	h := s.Get("owner", "name")
+	if h == nil {
+		t.Errorf("should be success to get the handle")
+		return
+	}
+
+	var v data
+	if ok := h.Load(&v); ok {
+		t.Errorf("should not load the document before saving it")
+		return
+	}
+
+	if ok := h.Save(&data{Value: "a"}); !ok {
+		t.Errorf("should be success to save")
+		return
+	}
+
+	if ok := h.Load(&v); !ok || v.Value != "a" {
+		t.Errorf("should load the saved document but %+v", v)
+		return
+	}
+
+	if list := s.List("owner"); len(list) != 1 || list[0] != "name" {
+		t.Errorf("should list the saved document but %v", list)
+		return
+	}
+}
+
+func TestFileStoreGetInvalidPath(t *testing.T) {
+	root, err := ioutil.TempDir("", "popuko-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	s := NewFileStore(root, "test")
+	for _, p := range []string{"", ".", "..", "a/b", "../a"} {
+		if h := s.Get(p, "name"); h != nil {
+			t.Errorf("`%v` should be invalid", p)
+		}
+		if h := s.Get("owner", p); h != nil {
+			t.Errorf("`%v` should be invalid", p)
+		}
+	}
+}